@@ -0,0 +1,80 @@
+// Package analysis derives race information (messages, laps, stints, ...) from the reconstructed states of an event.
+package analysis
+
+import (
+	"fmt"
+	"racelogctl/internal"
+	"strconv"
+)
+
+// columns maps the names of a manifest to their index
+type columns map[string]int
+
+func newColumns(manifest []string) columns {
+	ret := columns{}
+	for i, name := range manifest {
+		ret[name] = i
+	}
+	return ret
+}
+
+// value returns the value of the named column or nil if not available
+func (c columns) value(row []interface{}, name string) interface{} {
+	if idx, ok := c[name]; ok && idx < len(row) {
+		return row[idx]
+	}
+	return nil
+}
+
+func (c columns) float(row []interface{}, name string) float64 {
+	return toFloat(c.value(row, name))
+}
+
+func (c columns) int(row []interface{}, name string) int {
+	return int(toFloat(c.value(row, name)))
+}
+
+func (c columns) string(row []interface{}, name string) string {
+	return toString(c.value(row, name))
+}
+
+// toFloat converts numeric payload values. Some columns (for example last and best)
+// may be sent as [value, marker], in which case the value is used.
+func toFloat(v interface{}) float64 {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case float32:
+		return float64(val)
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case string:
+		f, _ := strconv.ParseFloat(val, 64)
+		return f
+	case []interface{}:
+		if len(val) > 0 {
+			return toFloat(val[0])
+		}
+	}
+	return 0
+}
+
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// sessionTime returns the session time of the state or 0 if not available
+func sessionTime(manifests internal.Manifests, s internal.State) float64 {
+	return newColumns(manifests.Session).float(s.Payload.Session, "sessionTime")
+}
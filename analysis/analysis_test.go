@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"racelogctl/internal"
+	"racelogctl/synth"
+	"testing"
+	"time"
+)
+
+// testRace returns the event and states of a short synthetic race
+func testRace() (*internal.Event, []internal.State) {
+	p := synth.DefaultParams()
+	p.Cars = 4
+	p.Classes = 2
+	p.RaceLength = 10 * time.Minute
+	p.LapTime = 60
+	p.PitStops = 1
+	p.PitDuration = 20
+	p.StartTime = time.Date(2022, 8, 20, 8, 0, 0, 0, time.UTC)
+	g := synth.New(p)
+	states := []internal.State{}
+	for {
+		s, _, ok := g.Next()
+		if !ok {
+			break
+		}
+		states = append(states, s)
+	}
+	return g.Event(), states
+}
+
+func TestToFloat(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want float64
+	}{
+		{1.5, 1.5},
+		{3, 3},
+		{"2.25", 2.25},
+		{[]interface{}{61.2, "ob"}, 61.2},
+		{[]interface{}{}, 0},
+		{nil, 0},
+		{"abc", 0},
+	}
+	for _, tt := range tests {
+		if got := toFloat(tt.in); got != tt.want {
+			t.Errorf("toFloat(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
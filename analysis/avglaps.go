@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"math"
+	"racelogctl/internal"
+	"sort"
+)
+
+// ClassNames maps the car class ids of the car data to their names
+func ClassNames(carData *internal.EventCarMessage) map[int]string {
+	ret := map[int]string{}
+	if carData == nil {
+		return ret
+	}
+	for _, c := range carData.Payload.CarClasses {
+		ret[c.Id] = c.Name
+	}
+	return ret
+}
+
+// ClassIds returns the sorted class ids used in the average lap times
+func ClassIds(avgLaps []*internal.AverageLapTime) []int {
+	found := map[int]bool{}
+	for _, item := range avgLaps {
+		for id := range item.Laptimes {
+			found[id] = true
+		}
+	}
+	ret := make([]int, 0, len(found))
+	for id := range found {
+		ret = append(ret, id)
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+// ClassLapTimes returns the lap times of a class over time. Missing values are 0.
+func ClassLapTimes(avgLaps []*internal.AverageLapTime, classId int) []float64 {
+	ret := make([]float64, len(avgLaps))
+	for i, item := range avgLaps {
+		ret[i] = item.Laptimes[classId]
+	}
+	return ret
+}
+
+// Correlation returns the Pearson correlation coefficient of x and y.
+// Pairs with y <= 0 (no lap time) are ignored. NaN is returned if there is not enough data.
+func Correlation(x, y []float64) float64 {
+	var sumX, sumY, sumXX, sumYY, sumXY, n float64
+	for i := range x {
+		if i >= len(y) || y[i] <= 0 {
+			continue
+		}
+		sumX += x[i]
+		sumY += y[i]
+		sumXX += x[i] * x[i]
+		sumYY += y[i] * y[i]
+		sumXY += x[i] * y[i]
+		n++
+	}
+	if n < 3 {
+		return math.NaN()
+	}
+	cov := sumXY - sumX*sumY/n
+	varX := sumXX - sumX*sumX/n
+	varY := sumYY - sumY*sumY/n
+	if varX <= 0 || varY <= 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
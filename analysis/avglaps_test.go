@@ -0,0 +1,41 @@
+package analysis
+
+import (
+	"math"
+	"racelogctl/internal"
+	"testing"
+)
+
+func TestCorrelation(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5}
+	if got := Correlation(x, []float64{10, 20, 30, 40, 50}); math.Abs(got-1) > 1e-9 {
+		t.Errorf("got %v, want 1", got)
+	}
+	if got := Correlation(x, []float64{50, 40, 30, 20, 10}); math.Abs(got+1) > 1e-9 {
+		t.Errorf("got %v, want -1", got)
+	}
+	// missing lap times are ignored
+	if got := Correlation(x, []float64{10, 0, 30, 0, 50}); math.Abs(got-1) > 1e-9 {
+		t.Errorf("got %v, want 1", got)
+	}
+	if got := Correlation(x, []float64{10, 0, 0, 0, 50}); !math.IsNaN(got) {
+		t.Errorf("got %v, want NaN for too few values", got)
+	}
+}
+
+func TestClassLapTimes(t *testing.T) {
+	avgLaps := []*internal.AverageLapTime{
+		{Laptimes: map[int]float64{2: 61, 5: 70}},
+		{Laptimes: map[int]float64{2: 62}},
+	}
+	if ids := ClassIds(avgLaps); len(ids) != 2 || ids[0] != 2 || ids[1] != 5 {
+		t.Errorf("unexpected class ids %v", ids)
+	}
+	if got := ClassLapTimes(avgLaps, 5); got[0] != 70 || got[1] != 0 {
+		t.Errorf("unexpected lap times %v", got)
+	}
+	names := ClassNames(&internal.EventCarMessage{Payload: internal.EventCars{CarClasses: []internal.CarClass{{Id: 2, Name: "GT3"}}}})
+	if names[2] != "GT3" || ClassNames(nil) == nil {
+		t.Errorf("unexpected class names %v", names)
+	}
+}
@@ -0,0 +1,127 @@
+package analysis
+
+import (
+	"math"
+	"racelogctl/internal"
+	"sort"
+)
+
+// lapOutlierFactor marks laps slower than this factor times the best lap of the driver
+// as outliers (pit stops, incidents). They are not used for average and consistency.
+const lapOutlierFactor = 1.1
+
+// DriverStats contains the statistics of a driver in a car
+type DriverStats struct {
+	Name      string  `json:"name"`
+	IRating   int     `json:"iRating"`
+	License   string  `json:"license"`
+	Laps      int     `json:"laps"`
+	Stints    int     `json:"stints"`
+	BestLap   float64 `json:"bestLap"`
+	AvgLap    float64 `json:"avgLap"` // without outliers
+	MedianLap float64 `json:"medianLap"`
+	StdDev    float64 `json:"stdDev"`    // without outliers
+	TimeInCar float64 `json:"timeInCar"` // seconds, including pit stops done by the driver
+	lapTimes  []float64
+}
+
+// TeamStats groups the driver statistics of a car
+type TeamStats struct {
+	CarIdx   int            `json:"carIdx"`
+	CarNum   string         `json:"carNum"`
+	CarClass string         `json:"carClass"`
+	Team     string         `json:"team"`
+	Pos      int            `json:"pos"` // position at the last completed lap
+	Drivers  []*DriverStats `json:"drivers"`
+}
+
+// DriverStatistics attributes each lap to the driver in the car when the lap was
+// completed (userName column). carData is used for team names, iRating and license (may be nil).
+// The result is ordered by class and position.
+func DriverStatistics(laps []Lap, carData *internal.EventCarMessage) []*TeamStats {
+	teams := map[int]*TeamStats{}
+	drivers := map[int]map[string]*DriverStats{}
+	lastDriver := map[int]string{}
+	lastTime := map[int]float64{}
+	classOrder := map[string]int{}
+	for _, l := range laps {
+		t, ok := teams[l.CarIdx]
+		if !ok {
+			t = &TeamStats{CarIdx: l.CarIdx, CarNum: l.CarNum, CarClass: l.CarClass, Team: l.Team}
+			teams[l.CarIdx] = t
+			drivers[l.CarIdx] = map[string]*DriverStats{}
+			if _, ok := classOrder[l.CarClass]; !ok {
+				classOrder[l.CarClass] = len(classOrder)
+			}
+		}
+		t.Pos = l.Pos
+		d, ok := drivers[l.CarIdx][l.Driver]
+		if !ok {
+			d = &DriverStats{Name: l.Driver}
+			drivers[l.CarIdx][l.Driver] = d
+			t.Drivers = append(t.Drivers, d)
+		}
+		d.Laps++
+		if lastDriver[l.CarIdx] != l.Driver {
+			d.Stints++
+			lastDriver[l.CarIdx] = l.Driver
+		}
+		if prev, ok := lastTime[l.CarIdx]; ok {
+			d.TimeInCar += l.SessionTime - prev
+		} else if l.LapTime > 0 {
+			d.TimeInCar += l.LapTime
+		}
+		lastTime[l.CarIdx] = l.SessionTime
+		if l.LapTime > 0 {
+			d.lapTimes = append(d.lapTimes, l.LapTime)
+		}
+	}
+
+	applyCarData(teams, carData)
+	ret := make([]*TeamStats, 0, len(teams))
+	for _, t := range teams {
+		for _, d := range t.Drivers {
+			d.computeLapStats()
+		}
+		ret = append(ret, t)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].CarClass != ret[j].CarClass {
+			return classOrder[ret[i].CarClass] < classOrder[ret[j].CarClass]
+		}
+		return ret[i].Pos < ret[j].Pos
+	})
+	return ret
+}
+
+func applyCarData(teams map[int]*TeamStats, carData *internal.EventCarMessage) {
+	if carData == nil {
+		return
+	}
+	for _, e := range carData.Payload.Entries {
+		t, ok := teams[e.Car.CarIdx]
+		if !ok {
+			continue
+		}
+		if e.Team.Name != "" {
+			t.Team = e.Team.Name
+		}
+		for _, entryDriver := range e.Drivers {
+			for _, d := range t.Drivers {
+				if d.Name == entryDriver.Name {
+					d.IRating = entryDriver.IRating
+					d.License = entryDriver.LicString
+				}
+			}
+		}
+	}
+}
+
+func (d *DriverStats) computeLapStats() {
+	if len(d.lapTimes) == 0 {
+		return
+	}
+	d.BestLap = minOf(d.lapTimes)
+	d.AvgLap, d.StdDev = meanStdDev(d.lapTimes, d.BestLap*lapOutlierFactor)
+	d.MedianLap = math.Round(median(d.lapTimes)*1000) / 1000
+}
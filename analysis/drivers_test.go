@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"encoding/json"
+	"math"
+	"racelogctl/internal"
+	"testing"
+)
+
+func TestDriverStatistics(t *testing.T) {
+	lap := func(driver string, sessionTime, lapTime float64) Lap {
+		return Lap{CarIdx: 3, CarNum: "7", CarClass: "GT3", Team: "Team", Driver: driver, Pos: 1,
+			SessionTime: sessionTime, LapTime: lapTime}
+	}
+	laps := []Lap{
+		lap("A", 100, 100),
+		lap("A", 200, 100),
+		lap("B", 330, 130), // pit stop and driver change
+		lap("B", 430, 100),
+		lap("B", 532, 102),
+		lap("A", 640, 108),
+		{CarIdx: 4, CarNum: "8", CarClass: "GT3", Driver: "C", Pos: 2, SessionTime: 110, LapTime: 110},
+	}
+	carData := &internal.EventCarMessage{}
+	json.Unmarshal([]byte(`{"payload": {"entries": [{
+		"car": {"carIdx": 3}, "team": {"name": "Racing Team"},
+		"drivers": [{"name": "B", "iRating": 2500, "licString": "A 3.20"}]}]}}`), carData)
+
+	teams := DriverStatistics(laps, carData)
+	if len(teams) != 2 || teams[0].CarNum != "7" {
+		t.Fatalf("unexpected teams %+v", teams)
+	}
+	team := teams[0]
+	if team.Team != "Racing Team" || len(team.Drivers) != 2 {
+		t.Fatalf("unexpected team %+v", team)
+	}
+	a, b := team.Drivers[0], team.Drivers[1]
+	if a.Laps != 3 || a.Stints != 2 || b.Laps != 3 || b.Stints != 1 {
+		t.Errorf("unexpected laps/stints A: %d/%d B: %d/%d", a.Laps, a.Stints, b.Laps, b.Stints)
+	}
+	if a.TimeInCar != 100+100+108 || b.TimeInCar != 130+100+102 {
+		t.Errorf("unexpected time in car A: %v B: %v", a.TimeInCar, b.TimeInCar)
+	}
+	if b.BestLap != 100 || b.MedianLap != 102 || b.AvgLap != 101 || math.Abs(b.StdDev-1) > 1e-9 {
+		t.Errorf("unexpected lap stats for B %+v", b)
+	}
+	if b.IRating != 2500 || b.License != "A 3.20" || a.IRating != 0 {
+		t.Errorf("unexpected car data A: %+v B: %+v", a, b)
+	}
+}
+
+func TestDriverStatisticsFromRace(t *testing.T) {
+	event, states := testRace()
+	laps := Laps(event.Data.Manifests, states)
+	total := 0
+	for _, team := range DriverStatistics(laps, nil) {
+		if len(team.Drivers) < 2 {
+			t.Errorf("car %s: expected a driver change at the pit stop, got %d drivers", team.CarNum, len(team.Drivers))
+		}
+		for _, d := range team.Drivers {
+			total += d.Laps
+		}
+	}
+	if total != len(laps) {
+		t.Errorf("got %d attributed laps, want %d", total, len(laps))
+	}
+}
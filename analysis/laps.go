@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"fmt"
+	"racelogctl/internal"
+)
+
+// Lap describes a completed lap of a car
+type Lap struct {
+	CarIdx      int       `json:"carIdx"`
+	CarNum      string    `json:"carNum"`
+	CarClass    string    `json:"carClass"`
+	Driver      string    `json:"driver"`
+	Team        string    `json:"team"`
+	Lap         int       `json:"lap"`         // number of the completed lap
+	LapTime     float64   `json:"lapTime"`     // seconds, <= 0 if no valid time was recorded
+	Timestamp   float64   `json:"timestamp"`   // timestamp of the state the lap was completed
+	SessionTime float64   `json:"sessionTime"` // session time the lap was completed
+	Pos         int       `json:"pos"`
+	PIC         int       `json:"pic"`      // position in class
+	Gap         float64   `json:"gap"`      // gap to the overall leader
+	ClassGap    float64   `json:"classGap"` // gap to the class leader
+	Pitstops    int       `json:"pitstops"` // number of pit stops done so far
+	StintLap    int       `json:"stintLap"`
+	Sectors     []float64 `json:"sectors"` // sector times of this lap (columns s1..sN), <= 0 if not recorded
+}
+
+// LapCollector detects completed laps in a sequence of states
+type LapCollector struct {
+	manifests internal.Manifests
+	cars      columns
+	sectors   []string    // names of the sector columns
+	lastLc    map[int]int // laps completed by car index
+	lastIdx   map[int]int // index of the last lap by car index
+	laps      []Lap
+}
+
+// NewLapCollector creates a collector for states matching the manifests
+func NewLapCollector(manifests internal.Manifests) *LapCollector {
+	cars := newColumns(manifests.Car)
+	sectors := []string{}
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("s%d", i)
+		if _, ok := cars[name]; !ok {
+			break
+		}
+		sectors = append(sectors, name)
+	}
+	return &LapCollector{
+		manifests: manifests,
+		cars:      cars,
+		sectors:   sectors,
+		lastLc:    map[int]int{},
+		lastIdx:   map[int]int{},
+		laps:      []Lap{},
+	}
+}
+
+// Add processes the next (complete) state.
+// The lap time is taken from the "last" column. Since it may be updated after the
+// lap counter it is tracked until the next lap of the car is completed. The same
+// applies to the time of the last sector.
+func (lc *LapCollector) Add(s internal.State) {
+	c := lc.cars
+	st := sessionTime(lc.manifests, s)
+	classLeaderGap := map[string]float64{}
+	for _, row := range s.Payload.Cars {
+		class := c.string(row, "carClass")
+		gap := c.float(row, "gap")
+		if current, ok := classLeaderGap[class]; !ok || gap < current {
+			classLeaderGap[class] = gap
+		}
+	}
+	for _, row := range s.Payload.Cars {
+		carIdx := c.int(row, "carIdx")
+		completed := c.int(row, "lc")
+		last := c.float(row, "last")
+		prev, known := lc.lastLc[carIdx]
+		lc.lastLc[carIdx] = completed
+		if !known || completed <= prev {
+			if idx, ok := lc.lastIdx[carIdx]; ok && known && completed == prev {
+				if last > 0 {
+					lc.laps[idx].LapTime = last
+				}
+				if n := len(lc.sectors); n > 0 {
+					if v := c.float(row, lc.sectors[n-1]); v > 0 {
+						lc.laps[idx].Sectors[n-1] = v
+					}
+				}
+			}
+			continue
+		}
+		class := c.string(row, "carClass")
+		gap := c.float(row, "gap")
+		sectors := make([]float64, len(lc.sectors))
+		for i, name := range lc.sectors {
+			sectors[i] = c.float(row, name)
+		}
+		lc.lastIdx[carIdx] = len(lc.laps)
+		lc.laps = append(lc.laps, Lap{
+			CarIdx:      carIdx,
+			CarNum:      c.string(row, "carNum"),
+			CarClass:    class,
+			Driver:      c.string(row, "userName"),
+			Team:        c.string(row, "teamName"),
+			Lap:         completed,
+			LapTime:     last,
+			Timestamp:   s.Timestamp,
+			SessionTime: st,
+			Pos:         c.int(row, "pos"),
+			PIC:         c.int(row, "pic"),
+			Gap:         gap,
+			ClassGap:    gap - classLeaderGap[class],
+			Pitstops:    c.int(row, "pitstops"),
+			StintLap:    c.int(row, "stintLap"),
+			Sectors:     sectors,
+		})
+	}
+}
+
+// Laps returns the collected laps in order of completion
+func (lc *LapCollector) Laps() []Lap {
+	return lc.laps
+}
+
+// Laps returns the completed laps of the states
+func Laps(manifests internal.Manifests, states []internal.State) []Lap {
+	lc := NewLapCollector(manifests)
+	for _, s := range states {
+		lc.Add(s)
+	}
+	return lc.Laps()
+}
+
+// ByClass groups the laps by car class. The order of laps is kept.
+func ByClass(laps []Lap) (classes []string, ret map[string][]Lap) {
+	ret = map[string][]Lap{}
+	for _, l := range laps {
+		if _, ok := ret[l.CarClass]; !ok {
+			classes = append(classes, l.CarClass)
+		}
+		ret[l.CarClass] = append(ret[l.CarClass], l)
+	}
+	return classes, ret
+}
@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"racelogctl/internal"
+	"testing"
+)
+
+func TestLaps(t *testing.T) {
+	event, states := testRace()
+	laps := Laps(event.Data.Manifests, states)
+	if len(laps) == 0 {
+		t.Fatal("no laps detected")
+	}
+	next := map[int]int{}
+	for _, l := range laps {
+		if next[l.CarIdx] == 0 {
+			next[l.CarIdx] = 1
+		}
+		if l.Lap != next[l.CarIdx] {
+			t.Errorf("car %s: got lap %d, want %d", l.CarNum, l.Lap, next[l.CarIdx])
+		}
+		next[l.CarIdx]++
+		if l.LapTime < 40 || l.LapTime > 120 {
+			t.Errorf("car %s lap %d: unexpected lap time %v", l.CarNum, l.Lap, l.LapTime)
+		}
+		if l.PIC < 1 || l.ClassGap < 0 || l.ClassGap > l.Gap {
+			t.Errorf("car %s lap %d: inconsistent pic/gap %+v", l.CarNum, l.Lap, l)
+		}
+	}
+	classes, byClass := ByClass(laps)
+	if len(classes) != 2 || len(byClass[classes[0]])+len(byClass[classes[1]]) != len(laps) {
+		t.Errorf("unexpected grouping by class: %v", classes)
+	}
+}
+
+func TestLapTimeUpdatedLate(t *testing.T) {
+	manifests := internal.Manifests{Car: []string{"carIdx", "carNum", "carClass", "lc", "last"}}
+	state := func(lc int, last interface{}) internal.State {
+		return internal.State{Payload: internal.Payload{Cars: [][]interface{}{{1, "7", "GT3", lc, last}}}}
+	}
+	laps := Laps(manifests, []internal.State{
+		state(0, -1.0),
+		state(1, -1.0),
+		state(1, []interface{}{61.5, "pb"}),
+		state(2, 62.0),
+	})
+	if len(laps) != 2 {
+		t.Fatalf("got %d laps, want 2", len(laps))
+	}
+	if laps[0].LapTime != 61.5 || laps[1].LapTime != 62 {
+		t.Errorf("unexpected lap times %v, %v", laps[0].LapTime, laps[1].LapTime)
+	}
+}
@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"racelogctl/internal"
+	"strings"
+)
+
+// RaceMessage is a single race message (see Manifests.Message)
+type RaceMessage struct {
+	Timestamp   float64 `json:"timestamp"`
+	SessionTime float64 `json:"sessionTime"`
+	Type        string  `json:"type"`
+	SubType     string  `json:"subType"`
+	CarIdx      int     `json:"carIdx"`
+	CarNum      string  `json:"carNum"`
+	CarClass    string  `json:"carClass"`
+	Msg         string  `json:"msg"`
+}
+
+// Messages extracts the race messages of a state
+func Messages(manifests internal.Manifests, s internal.State) []RaceMessage {
+	if len(s.Payload.Messages) == 0 {
+		return nil
+	}
+	c := newColumns(manifests.Message)
+	st := sessionTime(manifests, s)
+	ret := make([]RaceMessage, 0, len(s.Payload.Messages))
+	for _, m := range s.Payload.Messages {
+		ret = append(ret, RaceMessage{
+			Timestamp:   s.Timestamp,
+			SessionTime: st,
+			Type:        c.string(m, "type"),
+			SubType:     c.string(m, "subType"),
+			CarIdx:      c.int(m, "carIdx"),
+			CarNum:      c.string(m, "carNum"),
+			CarClass:    c.string(m, "carClass"),
+			Msg:         c.string(m, "msg"),
+		})
+	}
+	return ret
+}
+
+// MessageFilter selects race messages. Empty fields match every message.
+type MessageFilter struct {
+	Types    []string // message types (case insensitive)
+	SubTypes []string // message sub types (case insensitive)
+	Cars     []string // car numbers
+	Classes  []string // car class names (case insensitive)
+	Text     string   // substring of the message text (case insensitive)
+}
+
+// Match returns true if the message passes the filter
+func (f MessageFilter) Match(m RaceMessage) bool {
+	return matchAny(f.Types, m.Type, strings.EqualFold) &&
+		matchAny(f.SubTypes, m.SubType, strings.EqualFold) &&
+		matchAny(f.Cars, m.CarNum, func(a, b string) bool { return a == b }) &&
+		matchAny(f.Classes, m.CarClass, strings.EqualFold) &&
+		(f.Text == "" || strings.Contains(strings.ToLower(m.Msg), strings.ToLower(f.Text)))
+}
+
+func matchAny(list []string, value string, eq func(a, b string) bool) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if eq(item, value) {
+			return true
+		}
+	}
+	return false
+}
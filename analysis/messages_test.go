@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"racelogctl/internal"
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	event, states := testRace()
+	all := []RaceMessage{}
+	for _, s := range states {
+		all = append(all, Messages(event.Data.Manifests, s)...)
+	}
+	pits := 0
+	checkered := 0
+	for _, m := range all {
+		if m.CarNum == "" || m.Msg == "" {
+			t.Errorf("incomplete message %+v", m)
+		}
+		if m.Type == "Pits" {
+			pits++
+		}
+		if m.Msg == "Checkered flag" {
+			checkered++
+		}
+	}
+	if pits == 0 || checkered == 0 {
+		t.Errorf("expected pit and checkered flag messages, got %d pit and %d checkered", pits, checkered)
+	}
+}
+
+func TestMessagesColumnsByName(t *testing.T) {
+	manifests := internal.Manifests{
+		Message: []string{"msg", "carNum", "type"},
+		Session: []string{"sessionNum", "sessionTime"},
+	}
+	s := internal.State{Timestamp: 10, Payload: internal.Payload{
+		Session:  []interface{}{0.0, 123.0},
+		Messages: [][]interface{}{{"hello", "7", "Timing"}},
+	}}
+	got := Messages(manifests, s)
+	want := RaceMessage{Timestamp: 10, SessionTime: 123, Type: "Timing", CarNum: "7", Msg: "hello"}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestMessageFilter(t *testing.T) {
+	m := RaceMessage{Type: "Pits", SubType: "Enter", CarNum: "12", CarClass: "GT3", Msg: "#12 entered the pits"}
+	tests := []struct {
+		name   string
+		filter MessageFilter
+		want   bool
+	}{
+		{"empty", MessageFilter{}, true},
+		{"type", MessageFilter{Types: []string{"pits"}}, true},
+		{"other type", MessageFilter{Types: []string{"Timing"}}, false},
+		{"subType", MessageFilter{SubTypes: []string{"Exit", "Enter"}}, true},
+		{"car", MessageFilter{Cars: []string{"12"}}, true},
+		{"other car", MessageFilter{Cars: []string{"1"}}, false},
+		{"class", MessageFilter{Classes: []string{"gt3"}}, true},
+		{"text", MessageFilter{Text: "ENTERED"}, true},
+		{"other text", MessageFilter{Text: "exited"}, false},
+		{"combined", MessageFilter{Types: []string{"Pits"}, Cars: []string{"1"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(m); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"math"
+	"racelogctl/internal"
+	"sort"
+)
+
+// ClassPace summarizes the lap times of a car class
+type ClassPace struct {
+	Class  string  `json:"class"`
+	Cars   int     `json:"cars"`
+	Laps   int     `json:"laps"`   // laps with valid lap time
+	Best   float64 `json:"best"`   // best lap of the class
+	Avg    float64 `json:"avg"`    // average of laps not slower than 1.1 times the class best
+	Median float64 `json:"median"` // median of all laps
+}
+
+// Pace computes the lap time summary per car class
+func Pace(laps []Lap) []ClassPace {
+	classes, byClass := ByClass(laps)
+	ret := make([]ClassPace, 0, len(classes))
+	for _, class := range classes {
+		p := ClassPace{Class: class}
+		cars := map[int]bool{}
+		times := []float64{}
+		for _, l := range byClass[class] {
+			cars[l.CarIdx] = true
+			if l.LapTime > 0 {
+				times = append(times, l.LapTime)
+			}
+		}
+		p.Cars = len(cars)
+		p.Laps = len(times)
+		if len(times) > 0 {
+			p.Best = minOf(times)
+			p.Avg, _ = meanStdDev(times, p.Best*lapOutlierFactor)
+			p.Median = median(times)
+		}
+		ret = append(ret, p)
+	}
+	return ret
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// nonWeatherColumns are numeric session columns which don't describe conditions
+var nonWeatherColumns = map[string]bool{
+	"sessionNum": true, "sessionTime": true, "timeRemain": true, "lapsRemain": true, "timeOfDay": true,
+}
+
+// ValueStats contains min, average and max of a value
+type ValueStats struct {
+	Name string  `json:"name"`
+	Min  float64 `json:"min"`
+	Avg  float64 `json:"avg"`
+	Max  float64 `json:"max"`
+	n    int
+}
+
+// WeatherCollector collects statistics of the numeric session columns describing the
+// conditions (air and track temperature, wind, ...)
+type WeatherCollector struct {
+	manifest []string
+	stats    []*ValueStats
+}
+
+// NewWeatherCollector creates a collector for states matching the manifests
+func NewWeatherCollector(manifests internal.Manifests) *WeatherCollector {
+	ret := &WeatherCollector{manifest: manifests.Session, stats: make([]*ValueStats, len(manifests.Session))}
+	for i, name := range manifests.Session {
+		if !nonWeatherColumns[name] {
+			ret.stats[i] = &ValueStats{Name: name, Min: math.Inf(1), Max: math.Inf(-1)}
+		}
+	}
+	return ret
+}
+
+// Add processes the next (complete) state
+func (w *WeatherCollector) Add(s internal.State) {
+	for i, v := range s.Payload.Session {
+		if i >= len(w.stats) || w.stats[i] == nil {
+			continue
+		}
+		f, ok := numeric(v)
+		if !ok {
+			continue
+		}
+		st := w.stats[i]
+		st.Min, st.Max = math.Min(st.Min, f), math.Max(st.Max, f)
+		st.Avg += (f - st.Avg) / float64(st.n+1)
+		st.n++
+	}
+}
+
+// Stats returns the statistics of the numeric columns in manifest order
+func (w *WeatherCollector) Stats() []ValueStats {
+	ret := []ValueStats{}
+	for _, st := range w.stats {
+		if st != nil && st.n > 0 {
+			ret = append(ret, *st)
+		}
+	}
+	return ret
+}
+
+func numeric(v interface{}) (float64, bool) {
+	switch v.(type) {
+	case float64, float32, int, int64:
+		return toFloat(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,40 @@
+package analysis
+
+import (
+	"math"
+	"racelogctl/internal"
+	"testing"
+)
+
+func TestPace(t *testing.T) {
+	laps := []Lap{
+		{CarIdx: 1, CarClass: "GT3", LapTime: 100},
+		{CarIdx: 1, CarClass: "GT3", LapTime: 104},
+		{CarIdx: 2, CarClass: "GT3", LapTime: 102},
+		{CarIdx: 2, CarClass: "GT3", LapTime: 140}, // pit lap
+		{CarIdx: 2, CarClass: "GT3", LapTime: -1},
+		{CarIdx: 3, CarClass: "GT4", LapTime: 110},
+	}
+	pace := Pace(laps)
+	if len(pace) != 2 {
+		t.Fatalf("got %d classes", len(pace))
+	}
+	gt3 := pace[0]
+	if gt3.Cars != 2 || gt3.Laps != 4 || gt3.Best != 100 || gt3.Avg != 102 || gt3.Median != 103 {
+		t.Errorf("unexpected pace %+v", gt3)
+	}
+}
+
+func TestWeatherCollector(t *testing.T) {
+	manifests := internal.Manifests{Session: []string{"sessionTime", "flagState", "airTemp", "trackTemp"}}
+	w := NewWeatherCollector(manifests)
+	w.Add(internal.State{Payload: internal.Payload{Session: []interface{}{1.0, "GREEN", 20.0, 30.0}}})
+	w.Add(internal.State{Payload: internal.Payload{Session: []interface{}{2.0, "GREEN", 22.0, 34}}})
+	stats := w.Stats()
+	if len(stats) != 2 || stats[0].Name != "airTemp" || stats[1].Name != "trackTemp" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats[0].Min != 20 || stats[0].Max != 22 || math.Abs(stats[0].Avg-21) > 1e-9 || stats[1].Avg != 32 {
+		t.Errorf("unexpected values %+v", stats)
+	}
+}
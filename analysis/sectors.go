@@ -0,0 +1,195 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// outlierFactor marks sector times slower than this factor times the best sector
+// of the car as outliers (pit stops, incidents). They are not used for consistency.
+const outlierFactor = 1.5
+
+// CarSectors contains the sector analysis of a car
+type CarSectors struct {
+	CarIdx          int              `json:"carIdx"`
+	CarNum          string           `json:"carNum"`
+	CarClass        string           `json:"carClass"`
+	Drivers         []*DriverSectors `json:"drivers"`         // ordered by first lap in the car
+	Laps            int              `json:"laps"`            // laps with complete sector times
+	BestLap         float64          `json:"bestLap"`         // best recorded lap time
+	TheoreticalBest float64          `json:"theoreticalBest"` // sum of the best sectors
+	Best            []float64        `json:"best"`            // best time per sector
+	Mean            []float64        `json:"mean"`            // mean time per sector (without outliers)
+	StdDev          []float64        `json:"stdDev"`          // standard deviation per sector (without outliers)
+	Loss            []float64        `json:"loss"`            // best sector minus class best sector
+}
+
+// DriverSectors contains the best sectors of a driver in a car.
+// Laps are attributed to the driver in the car when the lap was completed.
+type DriverSectors struct {
+	Name string    `json:"name"`
+	Laps int       `json:"laps"` // laps with complete sector times
+	Best []float64 `json:"best"` // best time per sector
+	Loss []float64 `json:"loss"` // best sector minus class best sector
+}
+
+// ClassSectors contains the best sectors of a car class
+type ClassSectors struct {
+	Class           string        `json:"class"`
+	Best            []float64     `json:"best"`
+	BestCar         []string      `json:"bestCar"` // car number holding the best sector
+	TheoreticalBest float64       `json:"theoreticalBest"`
+	Cars            []*CarSectors `json:"cars"` // ordered by theoretical best
+}
+
+// Sectors analyzes the sector times of the laps grouped by car class
+func Sectors(laps []Lap) []*ClassSectors {
+	classes, byClass := ByClass(laps)
+	ret := make([]*ClassSectors, 0, len(classes))
+	for _, class := range classes {
+		ret = append(ret, classSectors(class, byClass[class]))
+	}
+	return ret
+}
+
+func classSectors(class string, laps []Lap) *ClassSectors {
+	cars := map[int]*CarSectors{}
+	times := map[int][][]float64{} // sector times by car and sector
+	drivers := map[int]map[string]*DriverSectors{}
+	driverTimes := map[*DriverSectors][][]float64{}
+	order := []int{}
+	numSectors := 0
+	for _, l := range laps {
+		c, ok := cars[l.CarIdx]
+		if !ok {
+			c = &CarSectors{CarIdx: l.CarIdx, CarNum: l.CarNum, CarClass: l.CarClass}
+			cars[l.CarIdx] = c
+			times[l.CarIdx] = make([][]float64, len(l.Sectors))
+			drivers[l.CarIdx] = map[string]*DriverSectors{}
+			order = append(order, l.CarIdx)
+		}
+		var d *DriverSectors
+		if l.Driver != "" {
+			if d, ok = drivers[l.CarIdx][l.Driver]; !ok {
+				d = &DriverSectors{Name: l.Driver}
+				drivers[l.CarIdx][l.Driver] = d
+				driverTimes[d] = make([][]float64, len(times[l.CarIdx]))
+				c.Drivers = append(c.Drivers, d)
+			}
+		}
+		if l.LapTime > 0 && (c.BestLap == 0 || l.LapTime < c.BestLap) {
+			c.BestLap = l.LapTime
+		}
+		if len(l.Sectors) == 0 || !allPositive(l.Sectors) {
+			continue
+		}
+		numSectors = len(l.Sectors)
+		c.Laps++
+		if d != nil {
+			d.Laps++
+		}
+		for i, v := range l.Sectors {
+			if i < len(times[l.CarIdx]) {
+				times[l.CarIdx][i] = append(times[l.CarIdx][i], v)
+				if d != nil {
+					driverTimes[d][i] = append(driverTimes[d][i], v)
+				}
+			}
+		}
+	}
+
+	ret := &ClassSectors{Class: class, Best: make([]float64, numSectors), BestCar: make([]string, numSectors)}
+	for _, idx := range order {
+		c := cars[idx]
+		c.Best = make([]float64, numSectors)
+		c.Mean = make([]float64, numSectors)
+		c.StdDev = make([]float64, numSectors)
+		for i := 0; i < numSectors && i < len(times[idx]); i++ {
+			values := times[idx][i]
+			if len(values) == 0 {
+				continue
+			}
+			c.Best[i] = minOf(values)
+			c.Mean[i], c.StdDev[i] = meanStdDev(values, c.Best[i]*outlierFactor)
+			c.TheoreticalBest += c.Best[i]
+			if ret.Best[i] == 0 || c.Best[i] < ret.Best[i] {
+				ret.Best[i] = c.Best[i]
+				ret.BestCar[i] = c.CarNum
+			}
+		}
+		ret.Cars = append(ret.Cars, c)
+	}
+	for _, b := range ret.Best {
+		ret.TheoreticalBest += b
+	}
+	for _, c := range ret.Cars {
+		c.Loss = sectorLoss(c.Best, ret.Best)
+		for _, d := range c.Drivers {
+			d.Best = make([]float64, numSectors)
+			for i := 0; i < numSectors && i < len(driverTimes[d]); i++ {
+				if len(driverTimes[d][i]) > 0 {
+					d.Best[i] = minOf(driverTimes[d][i])
+				}
+			}
+			d.Loss = sectorLoss(d.Best, ret.Best)
+		}
+	}
+	sort.SliceStable(ret.Cars, func(i, j int) bool {
+		a, b := ret.Cars[i].TheoreticalBest, ret.Cars[j].TheoreticalBest
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		return a < b
+	})
+	return ret
+}
+
+func allPositive(values []float64) bool {
+	for _, v := range values {
+		if v <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sectorLoss returns the difference of the recorded best sectors to the class best sectors
+func sectorLoss(best, classBest []float64) []float64 {
+	ret := make([]float64, len(classBest))
+	for i := range ret {
+		if i < len(best) && best[i] > 0 {
+			ret[i] = best[i] - classBest[i]
+		}
+	}
+	return ret
+}
+
+func minOf(values []float64) float64 {
+	ret := math.Inf(1)
+	for _, v := range values {
+		ret = math.Min(ret, v)
+	}
+	return ret
+}
+
+// meanStdDev returns the mean and standard deviation of the values not greater than limit
+func meanStdDev(values []float64, limit float64) (mean, stdDev float64) {
+	sum, n := 0.0, 0
+	for _, v := range values {
+		if v <= limit {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	mean = sum / float64(n)
+	sq := 0.0
+	for _, v := range values {
+		if v <= limit {
+			sq += (v - mean) * (v - mean)
+		}
+	}
+	return mean, math.Sqrt(sq / float64(n))
+}
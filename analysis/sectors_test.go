@@ -0,0 +1,81 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSectors(t *testing.T) {
+	lap := func(carIdx int, carNum string, sectors ...float64) Lap {
+		sum := 0.0
+		for _, s := range sectors {
+			sum += s
+		}
+		return Lap{CarIdx: carIdx, CarNum: carNum, CarClass: "GT3", Driver: "D" + carNum, LapTime: sum, Sectors: sectors}
+	}
+	driverChange := lap(1, "1", 31, 39, 60) // pit entry: outlier in sector 3
+	driverChange.Driver = "E1"
+	laps := []Lap{
+		lap(1, "1", 0, 0, 0), // no sector times recorded (first lap)
+		lap(1, "1", 30, 40, 31),
+		lap(2, "2", 29, 42, 30),
+		driverChange,
+		lap(2, "2", 30, 40, 31),
+	}
+	classes := Sectors(laps)
+	if len(classes) != 1 {
+		t.Fatalf("got %d classes", len(classes))
+	}
+	c := classes[0]
+	if c.Best[0] != 29 || c.Best[1] != 39 || c.Best[2] != 30 || c.TheoreticalBest != 98 {
+		t.Errorf("unexpected class best %v (%v)", c.Best, c.TheoreticalBest)
+	}
+	if c.BestCar[1] != "1" || c.BestCar[2] != "2" {
+		t.Errorf("unexpected best cars %v", c.BestCar)
+	}
+	if c.Cars[0].CarNum != "2" || c.Cars[0].TheoreticalBest != 29+40+30 {
+		t.Errorf("expected car 2 first, got %+v", c.Cars[0])
+	}
+	car1 := c.Cars[1]
+	if car1.Laps != 2 || car1.TheoreticalBest != 30+39+31 {
+		t.Errorf("unexpected car 1 %+v", car1)
+	}
+	if car1.Loss[0] != 1 || car1.Loss[1] != 0 || car1.Loss[2] != 1 {
+		t.Errorf("unexpected loss %v", car1.Loss)
+	}
+	if len(car1.Drivers) != 2 || car1.Drivers[0].Name != "D1" || car1.Drivers[1].Name != "E1" {
+		t.Fatalf("unexpected drivers %+v", car1.Drivers)
+	}
+	d1, e1 := car1.Drivers[0], car1.Drivers[1]
+	if d1.Laps != 1 || d1.Loss[0] != 1 || d1.Loss[1] != 1 || d1.Loss[2] != 1 {
+		t.Errorf("unexpected driver D1 %+v", d1)
+	}
+	if e1.Laps != 1 || e1.Loss[0] != 2 || e1.Loss[1] != 0 || e1.Loss[2] != 30 {
+		t.Errorf("unexpected driver E1 %+v", e1)
+	}
+	if car1.Mean[2] != 31 || car1.StdDev[2] != 0 {
+		t.Errorf("outlier should be ignored: mean %v stddev %v", car1.Mean[2], car1.StdDev[2])
+	}
+	if math.Abs(car1.StdDev[0]-0.5) > 1e-9 {
+		t.Errorf("unexpected std dev %v", car1.StdDev[0])
+	}
+}
+
+func TestSectorsFromRace(t *testing.T) {
+	event, states := testRace()
+	laps := Laps(event.Data.Manifests, states)
+	for _, c := range Sectors(laps) {
+		if len(c.Best) != len(event.Data.Info.Sectors) {
+			t.Fatalf("class %s: got %d sectors, want %d", c.Class, len(c.Best), len(event.Data.Info.Sectors))
+		}
+		for _, car := range c.Cars {
+			if car.Laps == 0 {
+				t.Errorf("car %s has no laps with sector times", car.CarNum)
+			}
+			// the sum of the sectors of a lap is the lap time, so the theoretical best can't be slower
+			if car.TheoreticalBest > car.BestLap+0.01 {
+				t.Errorf("car %s: theoretical best %v slower than best lap %v", car.CarNum, car.TheoreticalBest, car.BestLap)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"fmt"
+	"racelogctl/internal"
+	"sort"
+)
+
+// SpeedmapFilter selects the speedmap messages of a time window.
+// Zero values don't restrict the selection.
+type SpeedmapFilter struct {
+	Class        string  // car class (required if the event has more than one class)
+	From, To     float64 // session time range (seconds)
+	TodFrom      float64 // time of day range (seconds since midnight). The range may wrap around midnight.
+	TodTo        float64
+	MinTrackTemp float64
+	MaxTrackTemp float64
+}
+
+// Match returns true if the speedmap belongs to the window
+func (f SpeedmapFilter) Match(p internal.SpeedmapPayload) bool {
+	if p.SessionTime < f.From || (f.To > 0 && p.SessionTime > f.To) {
+		return false
+	}
+	if f.TodFrom != f.TodTo {
+		if f.TodFrom < f.TodTo && (p.TimeOfDay < f.TodFrom || p.TimeOfDay > f.TodTo) {
+			return false
+		}
+		if f.TodFrom > f.TodTo && p.TimeOfDay < f.TodFrom && p.TimeOfDay > f.TodTo {
+			return false
+		}
+	}
+	if (f.MinTrackTemp != 0 && p.TrackTemp < f.MinTrackTemp) || (f.MaxTrackTemp != 0 && p.TrackTemp > f.MaxTrackTemp) {
+		return false
+	}
+	return true
+}
+
+// SpeedHeatmap contains the average chunk speeds of a car class per time bucket
+type SpeedHeatmap struct {
+	Class       string
+	ChunkSize   int
+	TrackLength float64
+	Times       []float64   // session time of the start of each row
+	Speeds      [][]float64 // average speed by row and chunk (0: no data)
+}
+
+// SpeedmapAggregator aggregates the chunk speeds of a car class
+type SpeedmapAggregator struct {
+	filter  SpeedmapFilter
+	bucket  float64 // seconds per heatmap row
+	heatmap SpeedHeatmap
+	times   map[int]float64 // session time of each row
+	sums    map[int][]float64
+	counts  map[int][]int
+}
+
+// NewSpeedmapAggregator creates an aggregator using rows of bucket seconds (<= 0: one row per message)
+func NewSpeedmapAggregator(filter SpeedmapFilter, bucket float64) *SpeedmapAggregator {
+	return &SpeedmapAggregator{
+		filter:  filter,
+		bucket:  bucket,
+		heatmap: SpeedHeatmap{Class: filter.Class},
+		times:   map[int]float64{},
+		sums:    map[int][]float64{},
+		counts:  map[int][]int{},
+	}
+}
+
+// Add processes the next speedmap message
+func (a *SpeedmapAggregator) Add(s *internal.SpeedmapMessage) {
+	p := s.Payload
+	if !a.filter.Match(p) {
+		return
+	}
+	data, ok := p.Data[a.filter.Class]
+	if !ok {
+		return
+	}
+	a.heatmap.ChunkSize = p.ChunkSize
+	a.heatmap.TrackLength = p.TrackLength
+	row := len(a.times)
+	rowTime := p.SessionTime
+	if a.bucket > 0 {
+		row = int((p.SessionTime - a.filter.From) / a.bucket)
+		rowTime = a.filter.From + float64(row)*a.bucket
+	}
+	if _, ok := a.sums[row]; !ok {
+		a.times[row] = rowTime
+		a.sums[row] = make([]float64, len(data.ChunkSpeeds))
+		a.counts[row] = make([]int, len(data.ChunkSpeeds))
+	}
+	for i, v := range data.ChunkSpeeds {
+		if v <= 0 || i >= len(a.sums[row]) {
+			continue
+		}
+		a.sums[row][i] += v
+		a.counts[row][i]++
+	}
+}
+
+// Heatmap returns the aggregated speeds ordered by time
+func (a *SpeedmapAggregator) Heatmap() SpeedHeatmap {
+	rows := make([]int, 0, len(a.sums))
+	for row := range a.sums {
+		rows = append(rows, row)
+	}
+	sort.Ints(rows)
+	ret := a.heatmap
+	ret.Times = make([]float64, len(rows))
+	ret.Speeds = make([][]float64, len(rows))
+	for i, row := range rows {
+		ret.Times[i] = a.times[row]
+		ret.Speeds[i] = average(a.sums[row], a.counts[row])
+	}
+	return ret
+}
+
+// Profile returns the average speed per chunk over the whole window
+func (h SpeedHeatmap) Profile() []float64 {
+	if len(h.Speeds) == 0 {
+		return []float64{}
+	}
+	sums := make([]float64, len(h.Speeds[0]))
+	counts := make([]int, len(sums))
+	for _, row := range h.Speeds {
+		for i, v := range row {
+			if v > 0 && i < len(sums) {
+				sums[i] += v
+				counts[i]++
+			}
+		}
+	}
+	return average(sums, counts)
+}
+
+// SpeedDiff returns b-a per chunk. Chunks without data in one of the profiles are 0.
+func SpeedDiff(a, b []float64) ([]float64, error) {
+	if len(a) != len(b) {
+		return nil, fmt.Errorf("profiles have different number of chunks (%d, %d)", len(a), len(b))
+	}
+	ret := make([]float64, len(a))
+	for i := range a {
+		if a[i] > 0 && b[i] > 0 {
+			ret[i] = b[i] - a[i]
+		}
+	}
+	return ret, nil
+}
+
+// SpeedmapClasses returns the car classes contained in the speedmaps
+func SpeedmapClasses(speedmaps []*internal.SpeedmapMessage) []string {
+	found := map[string]bool{}
+	for _, s := range speedmaps {
+		for c := range s.Payload.Data {
+			found[c] = true
+		}
+	}
+	ret := make([]string, 0, len(found))
+	for c := range found {
+		ret = append(ret, c)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func average(sums []float64, counts []int) []float64 {
+	ret := make([]float64, len(sums))
+	for i := range sums {
+		if counts[i] > 0 {
+			ret[i] = sums[i] / float64(counts[i])
+		}
+	}
+	return ret
+}
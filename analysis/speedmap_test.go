@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"racelogctl/internal"
+	"racelogctl/synth"
+	"testing"
+	"time"
+)
+
+func testSpeedmaps() []*internal.SpeedmapMessage {
+	p := synth.DefaultParams()
+	p.Classes = 2
+	p.RaceLength = 20 * time.Minute
+	p.LapTime = 60
+	p.SpeedmapInterval = 30
+	p.StartTime = time.Date(2022, 8, 20, 23, 50, 0, 0, time.UTC)
+	g := synth.New(p)
+	ret := []*internal.SpeedmapMessage{}
+	for {
+		_, sm, ok := g.Next()
+		if !ok {
+			break
+		}
+		if sm != nil {
+			ret = append(ret, sm)
+		}
+	}
+	return ret
+}
+
+func speedmap(sessionTime, tod, trackTemp float64) internal.SpeedmapPayload {
+	return internal.SpeedmapPayload{SessionTime: sessionTime, TimeOfDay: tod, TrackTemp: trackTemp}
+}
+
+func TestSpeedmapFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter SpeedmapFilter
+		p      internal.SpeedmapPayload
+		want   bool
+	}{
+		{"empty", SpeedmapFilter{}, speedmap(100, 3600, 30), true},
+		{"session time", SpeedmapFilter{From: 200}, speedmap(100, 3600, 30), false},
+		{"session time to", SpeedmapFilter{To: 50}, speedmap(100, 3600, 30), false},
+		{"day", SpeedmapFilter{TodFrom: 8 * 3600, TodTo: 18 * 3600}, speedmap(0, 12*3600, 30), true},
+		{"not day", SpeedmapFilter{TodFrom: 8 * 3600, TodTo: 18 * 3600}, speedmap(0, 2*3600, 30), false},
+		{"night wraps", SpeedmapFilter{TodFrom: 20 * 3600, TodTo: 6 * 3600}, speedmap(0, 2*3600, 30), true},
+		{"not night", SpeedmapFilter{TodFrom: 20 * 3600, TodTo: 6 * 3600}, speedmap(0, 12*3600, 30), false},
+		{"track temp", SpeedmapFilter{MinTrackTemp: 35}, speedmap(0, 0, 30), false},
+		{"track temp max", SpeedmapFilter{MaxTrackTemp: 35}, speedmap(0, 0, 30), true},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.p); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSpeedHeatmap(t *testing.T) {
+	speedmaps := testSpeedmaps()
+	classes := SpeedmapClasses(speedmaps)
+	if len(classes) != 2 {
+		t.Fatalf("expected 2 classes, got %v", classes)
+	}
+	agg := NewSpeedmapAggregator(SpeedmapFilter{Class: classes[0]}, 120)
+	for _, s := range speedmaps {
+		agg.Add(s)
+	}
+	h := agg.Heatmap()
+	if len(h.Times) < 5 || len(h.Times) != len(h.Speeds) {
+		t.Fatalf("unexpected number of rows %d", len(h.Times))
+	}
+	for i := 1; i < len(h.Times); i++ {
+		if h.Times[i]-h.Times[i-1] < 120 {
+			t.Errorf("rows %d and %d closer than bucket size", i-1, i)
+		}
+	}
+	profile := h.Profile()
+	if len(profile) == 0 || profile[0] <= 0 {
+		t.Errorf("unexpected profile %v", profile)
+	}
+
+	other := NewSpeedmapAggregator(SpeedmapFilter{Class: classes[1]}, 0)
+	for _, s := range speedmaps {
+		other.Add(s)
+	}
+	diff, err := SpeedDiff(profile, other.Heatmap().Profile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonZero := 0
+	for _, d := range diff {
+		if d != 0 {
+			nonZero++
+		}
+	}
+	if nonZero == 0 {
+		t.Errorf("expected speed differences between classes")
+	}
+	if _, err := SpeedDiff(profile, profile[1:]); err == nil {
+		t.Errorf("expected error for profiles of different length")
+	}
+}
@@ -0,0 +1,395 @@
+// Package cache stores archived event data on the local disk.
+//
+// Finished events are immutable, so their data can be kept forever. The cache is keyed by
+// the server url and the event id. Each event is stored in its own directory:
+//
+//	<dir>/<server>/events/<eventId>/meta.json       url, event id, name, last access
+//	<dir>/<server>/events/<eventId>/event.json      event info
+//	<dir>/<server>/events/<eventId>/cars.json       car data
+//	<dir>/<server>/events/<eventId>/states.jsonl.gz states (one json object per line)
+//	<dir>/<server>/events/<eventId>/speedmaps.jsonl.gz
+//	<dir>/<server>/tracks/<trackId>.json            track info
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// names of the cached items of an event
+const (
+	ItemEvent     = "event.json"
+	ItemCars      = "cars.json"
+	ItemStates    = "states.jsonl.gz"
+	ItemSpeedmaps = "speedmaps.jsonl.gz"
+	itemMeta      = "meta.json"
+)
+
+// DefaultTrackTTL is the time after which cached track info is refreshed from the server
+const DefaultTrackTTL = 24 * time.Hour
+
+// Cache is a local on-disk cache of archived event data
+type Cache struct {
+	dir      string
+	trackTTL time.Duration
+}
+
+// Meta describes a cached event
+type Meta struct {
+	Url        string    `json:"url"`
+	EventId    int       `json:"eventId"`
+	Name       string    `json:"name"`
+	Created    time.Time `json:"created"`
+	LastAccess time.Time `json:"lastAccess"`
+}
+
+// Entry is a cached event as listed by List
+type Entry struct {
+	Meta
+	Items []string `json:"items"`
+	Size  int64    `json:"size"` // bytes used on disk
+	dir   string
+}
+
+// DefaultDir returns the default cache directory (racelogctl in the user cache directory)
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "racelogctl")
+}
+
+// New creates a cache in the directory
+func New(dir string) *Cache {
+	return &Cache{dir: dir, trackTTL: DefaultTrackTTL}
+}
+
+// SetTrackTTL sets the time after which cached track info expires (0: never)
+func (c *Cache) SetTrackTTL(ttl time.Duration) *Cache {
+	c.trackTTL = ttl
+	return c
+}
+
+// Dir returns the directory of the cache
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// serverDir returns the directory for the server url. The name is readable and unique.
+func (c *Cache) serverDir(url string) string {
+	h := sha1.Sum([]byte(url))
+	return filepath.Join(c.dir, fmt.Sprintf("%s-%x", unsafeChars.ReplaceAllString(url, "_"), h[:4]))
+}
+
+func (c *Cache) eventDir(url string, eventId int) string {
+	return filepath.Join(c.serverDir(url), "events", strconv.Itoa(eventId))
+}
+
+// Has returns true if the item of the event is cached
+func (c *Cache) Has(url string, eventId int, item string) bool {
+	_, err := os.Stat(filepath.Join(c.eventDir(url, eventId), item))
+	return err == nil
+}
+
+// Get reads a json item of the event into v. Returns false if the item is not cached.
+func (c *Cache) Get(url string, eventId int, item string, v interface{}) (bool, error) {
+	ok, err := readJSON(filepath.Join(c.eventDir(url, eventId), item), v)
+	if ok {
+		c.touch(url, eventId)
+	}
+	return ok, err
+}
+
+// Put stores a json item of the event
+func (c *Cache) Put(url string, eventId int, item string, v interface{}) error {
+	if err := c.ensureMeta(url, eventId); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(c.eventDir(url, eventId), item), v)
+}
+
+func (c *Cache) trackFile(url string, trackId int) string {
+	return filepath.Join(c.serverDir(url), "tracks", fmt.Sprintf("%d.json", trackId))
+}
+
+// GetTrack reads the cached track info into v. Returns false if the track is not cached.
+// Unlike event data track info may change on the server, so expired is true if the
+// entry was stored longer than the track TTL ago.
+func (c *Cache) GetTrack(url string, trackId int, v interface{}) (ok bool, expired bool, err error) {
+	filename := c.trackFile(url, trackId)
+	if ok, err = readJSON(filename, v); !ok || err != nil {
+		return ok, false, err
+	}
+	if info, err := os.Stat(filename); err == nil && c.trackTTL > 0 {
+		expired = time.Since(info.ModTime()) > c.trackTTL
+	}
+	return true, expired, nil
+}
+
+// PutTrack stores the track info
+func (c *Cache) PutTrack(url string, trackId int, v interface{}) error {
+	return writeJSON(c.trackFile(url, trackId), v)
+}
+
+// GetItems reads a list item (states, speedmaps) of the event. Returns false if the item is not cached.
+func GetItems[T any](c *Cache, url string, eventId int, item string) ([]T, bool, error) {
+	f, err := os.Open(filepath.Join(c.eventDir(url, eventId), item))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, false, err
+	}
+	defer zr.Close()
+	ret := []T{}
+	dec := json.NewDecoder(bufio.NewReader(zr))
+	for {
+		var v T
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", item, err)
+		}
+		ret = append(ret, v)
+	}
+	c.touch(url, eventId)
+	return ret, true, nil
+}
+
+// PutItems stores a list item (states, speedmaps) of the event
+func PutItems[T any](c *Cache, url string, eventId int, item string, items []T) error {
+	w, err := NewItemWriter[T](c, url, eventId, item)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(items...); err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Commit()
+}
+
+// ItemWriter stores a list item (states, speedmaps) of an event incrementally, so the
+// items don't have to be kept in memory. Readers see the item after Commit.
+type ItemWriter[T any] struct {
+	filename string
+	tmp      *os.File
+	bw       *bufio.Writer
+	zw       *gzip.Writer
+	enc      *json.Encoder
+}
+
+// NewItemWriter starts writing a list item of the event. Either Commit or Abort must be called.
+func NewItemWriter[T any](c *Cache, url string, eventId int, item string) (*ItemWriter[T], error) {
+	if err := c.ensureMeta(url, eventId); err != nil {
+		return nil, err
+	}
+	filename := filepath.Join(c.eventDir(url, eventId), item)
+	tmp, err := createTemp(filename)
+	if err != nil {
+		return nil, err
+	}
+	w := &ItemWriter[T]{filename: filename, tmp: tmp, bw: bufio.NewWriter(tmp)}
+	w.zw = gzip.NewWriter(w.bw)
+	w.enc = json.NewEncoder(w.zw)
+	return w, nil
+}
+
+// Write appends the items
+func (w *ItemWriter[T]) Write(items ...T) error {
+	for _, v := range items {
+		if err := w.enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit finishes the item and replaces a previously stored version
+func (w *ItemWriter[T]) Commit() error {
+	defer os.Remove(w.tmp.Name())
+	if err := w.zw.Close(); err != nil {
+		w.tmp.Close()
+		return err
+	}
+	if err := w.bw.Flush(); err != nil {
+		w.tmp.Close()
+		return err
+	}
+	if err := w.tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(w.tmp.Name(), w.filename)
+}
+
+// Abort discards the written items
+func (w *ItemWriter[T]) Abort() {
+	w.tmp.Close()
+	os.Remove(w.tmp.Name())
+}
+
+// ensureMeta creates the meta data of the event if missing
+func (c *Cache) ensureMeta(url string, eventId int) error {
+	filename := filepath.Join(c.eventDir(url, eventId), itemMeta)
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+	return writeJSON(filename, Meta{Url: url, EventId: eventId, Created: time.Now(), LastAccess: time.Now()})
+}
+
+// SetName sets the name of the cached event shown by List
+func (c *Cache) SetName(url string, eventId int, name string) error {
+	if err := c.ensureMeta(url, eventId); err != nil {
+		return err
+	}
+	filename := filepath.Join(c.eventDir(url, eventId), itemMeta)
+	meta := Meta{}
+	if _, err := readJSON(filename, &meta); err != nil {
+		return err
+	}
+	meta.Name = name
+	return writeJSON(filename, meta)
+}
+
+// touch updates the last access time of the event
+func (c *Cache) touch(url string, eventId int) {
+	filename := filepath.Join(c.eventDir(url, eventId), itemMeta)
+	meta := Meta{}
+	if ok, _ := readJSON(filename, &meta); ok {
+		meta.LastAccess = time.Now()
+		writeJSON(filename, meta)
+	}
+}
+
+// List returns the cached events. If url is not empty only events of this server are returned.
+func (c *Cache) List(url string) ([]Entry, error) {
+	metas, err := filepath.Glob(filepath.Join(c.dir, "*", "events", "*", itemMeta))
+	if err != nil {
+		return nil, err
+	}
+	ret := []Entry{}
+	for _, m := range metas {
+		e := Entry{dir: filepath.Dir(m)}
+		if ok, err := readJSON(m, &e.Meta); !ok || err != nil {
+			continue
+		}
+		if url != "" && e.Url != url {
+			continue
+		}
+		files, _ := os.ReadDir(e.dir)
+		for _, f := range files {
+			if info, err := f.Info(); err == nil {
+				e.Size += info.Size()
+			}
+			if f.Name() != itemMeta {
+				e.Items = append(e.Items, f.Name())
+			}
+		}
+		ret = append(ret, e)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Url != ret[j].Url {
+			return ret[i].Url < ret[j].Url
+		}
+		return ret[i].EventId < ret[j].EventId
+	})
+	return ret, nil
+}
+
+// Remove deletes the cached event
+func (c *Cache) Remove(e Entry) error {
+	if e.dir == "" {
+		e.dir = c.eventDir(e.Url, e.EventId)
+	}
+	return os.RemoveAll(e.dir)
+}
+
+// Prune removes the cached events which were not accessed within maxAge (0: remove all).
+// If url is not empty only events of this server are removed. Returns the removed entries.
+func (c *Cache) Prune(url string, maxAge time.Duration) ([]Entry, error) {
+	entries, err := c.List(url)
+	if err != nil {
+		return nil, err
+	}
+	ret := []Entry{}
+	for _, e := range entries {
+		if maxAge > 0 && time.Since(e.LastAccess) < maxAge {
+			continue
+		}
+		if err := c.Remove(e); err != nil {
+			return ret, err
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
+func readJSON(filename string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("%s: %w", filename, err)
+	}
+	return true, nil
+}
+
+func writeJSON(filename string, v interface{}) error {
+	return writeAtomic(filename, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+}
+
+// writeAtomic writes the file via a temporary file, so readers never see partial data
+func writeAtomic(filename string, write func(w io.Writer) error) error {
+	tmp, err := createTemp(filename)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	bw := bufio.NewWriter(tmp)
+	if err := write(bw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// createTemp creates a temporary file in the directory of filename
+func createTemp(filename string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(filepath.Dir(filename), ".tmp-*")
+}
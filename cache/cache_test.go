@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type item struct {
+	Timestamp float64 `json:"timestamp"`
+	Name      string  `json:"name"`
+}
+
+func TestGetPut(t *testing.T) {
+	c := New(t.TempDir())
+	url := "wss://example.com/ws"
+	var got item
+	if ok, err := c.Get(url, 1, ItemEvent, &got); ok || err != nil {
+		t.Fatalf("Get() on empty cache = %v, %v", ok, err)
+	}
+	want := item{Timestamp: 1.5, Name: "Test"}
+	if err := c.Put(url, 1, ItemEvent, want); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.Get(url, 1, ItemEvent, &got); !ok || err != nil || got != want {
+		t.Errorf("Get() = %v, %v, %v", got, ok, err)
+	}
+	if c.Has("wss://other.com/ws", 1, ItemEvent) {
+		t.Errorf("event cached for a different server")
+	}
+
+	var track item
+	if err := c.PutTrack(url, 7, want); err != nil {
+		t.Fatal(err)
+	}
+	if ok, expired, err := c.GetTrack(url, 7, &track); !ok || expired || err != nil || track != want {
+		t.Errorf("GetTrack() = %v, %v, %v, %v", track, ok, expired, err)
+	}
+	old := time.Now().Add(-2 * DefaultTrackTTL)
+	os.Chtimes(c.trackFile(url, 7), old, old)
+	if ok, expired, _ := c.GetTrack(url, 7, &track); !ok || !expired {
+		t.Errorf("GetTrack() of old entry = %v, %v, want expired", ok, expired)
+	}
+	if ok, expired, _ := c.SetTrackTTL(0).GetTrack(url, 7, &track); !ok || expired {
+		t.Errorf("GetTrack() without ttl = %v, %v", ok, expired)
+	}
+}
+
+func TestItems(t *testing.T) {
+	c := New(t.TempDir())
+	url := "wss://example.com/ws"
+	if _, ok, err := GetItems[item](c, url, 1, ItemStates); ok || err != nil {
+		t.Fatalf("GetItems() on empty cache = %v, %v", ok, err)
+	}
+	want := []item{{Timestamp: 1}, {Timestamp: 2, Name: "x"}}
+	if err := PutItems(c, url, 1, ItemStates, want); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := GetItems[item](c, url, 1, ItemStates)
+	if !ok || err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetItems() = %v, %v, %v", got, ok, err)
+	}
+}
+
+func TestItemWriter(t *testing.T) {
+	c := New(t.TempDir())
+	url := "wss://example.com/ws"
+	w, err := NewItemWriter[item](c, url, 1, ItemStates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(item{Timestamp: 1})
+	w.Write(item{Timestamp: 2}, item{Timestamp: 3})
+	if c.Has(url, 1, ItemStates) {
+		t.Errorf("item visible before Commit")
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := GetItems[item](c, url, 1, ItemStates)
+	if !ok || err != nil || len(got) != 3 {
+		t.Errorf("GetItems() = %v, %v, %v", got, ok, err)
+	}
+
+	w, err = NewItemWriter[item](c, url, 1, ItemSpeedmaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(item{Timestamp: 1})
+	w.Abort()
+	if c.Has(url, 1, ItemSpeedmaps) {
+		t.Errorf("aborted item is cached")
+	}
+	tmp, _ := filepath.Glob(filepath.Join(c.eventDir(url, 1), ".tmp-*"))
+	if len(tmp) != 0 {
+		t.Errorf("temporary files left: %v", tmp)
+	}
+}
+
+func TestListPrune(t *testing.T) {
+	c := New(t.TempDir())
+	c.Put("wss://a/ws", 1, ItemEvent, item{})
+	c.SetName("wss://a/ws", 1, "Race A")
+	c.Put("wss://a/ws", 2, ItemEvent, item{})
+	c.Put("wss://b/ws", 1, ItemEvent, item{})
+
+	entries, err := c.List("")
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("List() = %v, %v", entries, err)
+	}
+	if entries[0].Name != "Race A" || entries[0].Items[0] != ItemEvent || entries[0].Size == 0 {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+	if entries, _ := c.List("wss://b/ws"); len(entries) != 1 {
+		t.Errorf("List(url) = %v", entries)
+	}
+
+	if removed, err := c.Prune("", time.Hour); err != nil || len(removed) != 0 {
+		t.Errorf("Prune(1h) = %v, %v", removed, err)
+	}
+	if removed, err := c.Prune("wss://a/ws", 0); err != nil || len(removed) != 2 {
+		t.Errorf("Prune(url) = %v, %v", removed, err)
+	}
+	if entries, _ := c.List(""); len(entries) != 1 || c.Has("wss://a/ws", 1, ItemEvent) {
+		t.Errorf("List() after prune = %v", entries)
+	}
+}
@@ -0,0 +1,213 @@
+// Package chart renders simple line and scatter charts as standalone SVG or HTML.
+//
+// The output contains no scripts or references to external resources.
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Point is a single data point
+type Point struct {
+	X, Y float64
+}
+
+// Series is a named list of points drawn in one color
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Chart describes a single chart
+type Chart struct {
+	Title   string
+	XLabel  string
+	YLabel  string
+	Series  []Series
+	InvertY bool                 // smaller values on top (for example positions)
+	Scatter bool                 // draw points instead of lines
+	XFormat func(float64) string // formats the x axis labels (optional)
+	YFormat func(float64) string // formats the y axis labels (optional)
+	Width   int                  // default 960
+	Height  int                  // default 540
+}
+
+const (
+	marginLeft   = 70
+	marginRight  = 150
+	marginTop    = 40
+	marginBottom = 50
+)
+
+var palette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f",
+	"#bcbd22", "#17becf", "#393b79", "#637939", "#8c6d31", "#843c39", "#7b4173", "#3182bd",
+}
+
+// Color returns the color used for the series with index i
+func Color(i int) string {
+	return palette[i%len(palette)]
+}
+
+func (c *Chart) size() (int, int) {
+	w, h := c.Width, c.Height
+	if w <= 0 {
+		w = 960
+	}
+	if h <= 0 {
+		h = 540
+	}
+	return w, h
+}
+
+// bounds returns the value range of all points
+func (c *Chart) bounds() (minX, maxX, minY, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 1, 0, 1
+	}
+	if minX == maxX {
+		maxX = minX + 1
+	}
+	if minY == maxY {
+		maxY = minY + 1
+	}
+	return
+}
+
+// ticks returns about n "nice" values covering [min,max]
+func ticks(min, max float64, n int) []float64 {
+	step := niceStep((max - min) / float64(n))
+	ret := []float64{}
+	for v := math.Ceil(min/step) * step; v <= max+step*1e-9; v += step {
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func niceStep(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, f := range []float64{1, 2, 2.5, 5, 10} {
+		if raw <= f*exp {
+			return f * exp
+		}
+	}
+	return 10 * exp
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func escape(s string) string {
+	buf := bytes.Buffer{}
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// WriteSVG writes the chart as standalone SVG document
+func (c *Chart) WriteSVG(w io.Writer) error {
+	width, height := c.size()
+	minX, maxX, minY, maxY := c.bounds()
+	plotW := float64(width - marginLeft - marginRight)
+	plotH := float64(height - marginTop - marginBottom)
+	px := func(x float64) float64 { return marginLeft + (x-minX)/(maxX-minX)*plotW }
+	py := func(y float64) float64 {
+		f := (y - minY) / (maxY - minY)
+		if !c.InvertY {
+			f = 1 - f
+		}
+		return marginTop + f*plotH
+	}
+	xFormat, yFormat := c.XFormat, c.YFormat
+	if xFormat == nil {
+		xFormat = formatNumber
+	}
+	if yFormat == nil {
+		yFormat = formatNumber
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	fmt.Fprintf(b, `<text x="%d" y="24" font-size="16" font-weight="bold">%s</text>`+"\n", marginLeft, escape(c.Title))
+
+	// grid and axis labels
+	for _, v := range ticks(minX, maxX, 10) {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`+"\n", px(v), marginTop, px(v), marginTop+plotH)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", px(v), marginTop+plotH+16, escape(xFormat(v)))
+	}
+	for _, v := range ticks(minY, maxY, 8) {
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`+"\n", marginLeft, py(v), marginLeft+plotW, py(v))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", marginLeft-6, py(v)+4, escape(yFormat(v)))
+	}
+	fmt.Fprintf(b, `<rect x="%d" y="%d" width="%.1f" height="%.1f" fill="none" stroke="#808080"/>`+"\n", marginLeft, marginTop, plotW, plotH)
+	fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", marginLeft+plotW/2, height-10, escape(c.XLabel))
+	fmt.Fprintf(b, `<text x="16" y="%.1f" text-anchor="middle" transform="rotate(-90 16 %.1f)">%s</text>`+"\n",
+		marginTop+plotH/2, marginTop+plotH/2, escape(c.YLabel))
+
+	// data
+	for i, s := range c.Series {
+		color := Color(i)
+		fmt.Fprintf(b, `<g><title>%s</title>`+"\n", escape(s.Name))
+		if c.Scatter {
+			for _, p := range s.Points {
+				fmt.Fprintf(b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"/>`+"\n", px(p.X), py(p.Y), color)
+			}
+		} else if len(s.Points) > 0 {
+			fmt.Fprintf(b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="`, color)
+			for j, p := range s.Points {
+				if j > 0 {
+					b.WriteString(" ")
+				}
+				fmt.Fprintf(b, "%.1f,%.1f", px(p.X), py(p.Y))
+			}
+			b.WriteString(`"/>` + "\n")
+		}
+		b.WriteString("</g>\n")
+		// legend
+		ly := marginTop + 8 + i*16
+		fmt.Fprintf(b, `<rect x="%.1f" y="%d" width="10" height="10" fill="%s"/>`+"\n", marginLeft+plotW+12, ly-9, color)
+		fmt.Fprintf(b, `<text x="%.1f" y="%d">%s</text>`+"\n", marginLeft+plotW+26, ly, escape(s.Name))
+	}
+	b.WriteString("</svg>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Drawable is implemented by Chart and Heatmap
+type Drawable interface {
+	WriteSVG(w io.Writer) error
+}
+
+// WriteHTML writes a standalone HTML page containing the charts
+func WriteHTML(w io.Writer, title string, charts []Drawable) error {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", escape(title))
+	b.WriteString("<style>body { font-family: sans-serif; margin: 20px; } svg { display: block; margin-bottom: 24px; }</style>\n")
+	fmt.Fprintf(b, "</head>\n<body>\n<h1>%s</h1>\n", escape(title))
+	for _, c := range charts {
+		if err := c.WriteSVG(b); err != nil {
+			return err
+		}
+	}
+	b.WriteString("</body>\n</html>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,79 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testChart() *Chart {
+	return &Chart{
+		Title:  "Positions <GT3>",
+		XLabel: "Lap",
+		YLabel: "Position",
+		Series: []Series{
+			{Name: "#1 A&B", Points: []Point{{1, 1}, {2, 2}, {3, 1}}},
+			{Name: "#2", Points: []Point{{1, 2}, {2, 1}, {3, 2}}},
+		},
+		InvertY: true,
+	}
+}
+
+func TestWriteSVGWellFormed(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := testChart().WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	d := xml.NewDecoder(&buf)
+	polylines := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err != io.EOF {
+				t.Fatalf("invalid svg: %v", err)
+			}
+			break
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "polyline" {
+			polylines++
+		}
+	}
+	if polylines != 2 {
+		t.Errorf("got %d polylines, want 2", polylines)
+	}
+}
+
+func TestInvertY(t *testing.T) {
+	c := &Chart{Series: []Series{{Name: "a", Points: []Point{{0, 1}, {1, 2}}}}, InvertY: true}
+	buf := bytes.Buffer{}
+	c.WriteSVG(&buf)
+	// position 1 is drawn at the top of the plot area
+	if !strings.Contains(buf.String(), `points="70.0,40.0 810.0,490.0"`) {
+		t.Errorf("unexpected polyline in %s", buf.String())
+	}
+}
+
+func TestWriteHTMLStandalone(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := WriteHTML(&buf, "Report", []Drawable{testChart(), testHeatmap()}); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	if strings.Count(html, "<svg") != 2 {
+		t.Errorf("expected two charts")
+	}
+	for _, external := range []string{"<script", "src=", "href="} {
+		if strings.Contains(html, external) {
+			t.Errorf("html should not contain %s", external)
+		}
+	}
+}
+
+func TestTicks(t *testing.T) {
+	got := ticks(0, 57, 10)
+	if len(got) != 6 || got[1] != 10 || got[5] != 50 {
+		t.Errorf("unexpected ticks %v", got)
+	}
+}
@@ -0,0 +1,254 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Heatmap renders a matrix of values. Rows are drawn top to bottom, columns left to right.
+type Heatmap struct {
+	Title     string
+	XLabel    string
+	YLabel    string
+	Rows      [][]float64
+	RowLabels []string             // one label per row (optional)
+	XFormat   func(float64) string // formats the column axis labels, receives the column position in [0,1] (optional)
+	Diverging bool                 // values are differences: negative blue, positive red, 0 white
+	NoData    float64              // value indicating missing data (ignored if Diverging is set)
+	Width     int                  // default 960
+	Height    int                  // default 540
+}
+
+var (
+	sequentialStops = []color.RGBA{{68, 1, 84, 255}, {59, 82, 139, 255}, {33, 145, 140, 255}, {94, 201, 98, 255}, {253, 231, 37, 255}}
+	negativeColor   = color.RGBA{33, 102, 172, 255}
+	positiveColor   = color.RGBA{178, 24, 43, 255}
+	noDataColor     = color.RGBA{224, 224, 224, 255}
+	white           = color.RGBA{255, 255, 255, 255}
+)
+
+func lerp(a, b color.RGBA, f float64) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f)) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// Range returns the value range used for the color scale
+func (h *Heatmap) Range() (min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, row := range h.Rows {
+		for _, v := range row {
+			if !h.Diverging && v == h.NoData {
+				continue
+			}
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+	}
+	if math.IsInf(min, 1) {
+		return 0, 1
+	}
+	if h.Diverging {
+		m := math.Max(math.Abs(min), math.Abs(max))
+		if m == 0 {
+			m = 1
+		}
+		return -m, m
+	}
+	if min == max {
+		max = min + 1
+	}
+	return min, max
+}
+
+// Color returns the color of the value for the range
+func (h *Heatmap) Color(v, min, max float64) color.RGBA {
+	if h.Diverging {
+		if v < 0 {
+			return lerp(white, negativeColor, math.Min(1, v/min))
+		}
+		return lerp(white, positiveColor, math.Min(1, v/max))
+	}
+	if v == h.NoData {
+		return noDataColor
+	}
+	f := math.Max(0, math.Min(1, (v-min)/(max-min))) * float64(len(sequentialStops)-1)
+	i := int(f)
+	if i >= len(sequentialStops)-1 {
+		return sequentialStops[len(sequentialStops)-1]
+	}
+	return lerp(sequentialStops[i], sequentialStops[i+1], f-float64(i))
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (h *Heatmap) columns() int {
+	ret := 0
+	for _, row := range h.Rows {
+		if len(row) > ret {
+			ret = len(row)
+		}
+	}
+	return ret
+}
+
+// WriteSVG writes the heatmap as standalone SVG document
+func (h *Heatmap) WriteSVG(w io.Writer) error {
+	width, height := h.Width, h.Height
+	if width <= 0 {
+		width = 960
+	}
+	if height <= 0 {
+		height = 540
+	}
+	cols := h.columns()
+	min, max := h.Range()
+	plotW := float64(width - marginLeft - marginRight)
+	plotH := float64(height - marginTop - marginBottom)
+	cellW := plotW / math.Max(1, float64(cols))
+	cellH := plotH / math.Max(1, float64(len(h.Rows)))
+	xFormat := h.XFormat
+	if xFormat == nil {
+		xFormat = func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) }
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	fmt.Fprintf(b, `<text x="%d" y="24" font-size="16" font-weight="bold">%s</text>`+"\n", marginLeft, escape(h.Title))
+	for r, row := range h.Rows {
+		for c, v := range row {
+			fmt.Fprintf(b, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`+"\n",
+				marginLeft+float64(c)*cellW, marginTop+float64(r)*cellH, cellW+0.1, cellH+0.1, hex(h.Color(v, min, max)))
+		}
+	}
+	fmt.Fprintf(b, `<rect x="%d" y="%d" width="%.1f" height="%.1f" fill="none" stroke="#808080"/>`+"\n", marginLeft, marginTop, plotW, plotH)
+	for _, f := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", marginLeft+f*plotW, marginTop+plotH+16, escape(xFormat(f)))
+	}
+	if len(h.RowLabels) > 0 {
+		step := int(math.Ceil(float64(len(h.RowLabels)) * 14 / plotH))
+		for r := 0; r < len(h.RowLabels); r += step {
+			fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n",
+				marginLeft-6, marginTop+(float64(r)+0.5)*cellH+4, escape(h.RowLabels[r]))
+		}
+	}
+	fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", marginLeft+plotW/2, height-10, escape(h.XLabel))
+	fmt.Fprintf(b, `<text x="16" y="%.1f" text-anchor="middle" transform="rotate(-90 16 %.1f)">%s</text>`+"\n",
+		marginTop+plotH/2, marginTop+plotH/2, escape(h.YLabel))
+
+	// color scale
+	scaleX := marginLeft + plotW + 30
+	steps := 50
+	for i := 0; i < steps; i++ {
+		v := max - (max-min)*float64(i)/float64(steps-1)
+		fmt.Fprintf(b, `<rect x="%.1f" y="%.2f" width="16" height="%.2f" fill="%s"/>`+"\n",
+			scaleX, marginTop+float64(i)*plotH/float64(steps), plotH/float64(steps)+0.1, hex(h.Color(v, min, max)))
+	}
+	fmt.Fprintf(b, `<text x="%.1f" y="%d">%.1f</text>`+"\n", scaleX+22, marginTop+10, max)
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f">%.1f</text>`+"\n", scaleX+22, marginTop+plotH, min)
+	b.WriteString("</svg>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WritePNG writes the heatmap as PNG image using cell pixels per value
+func (h *Heatmap) WritePNG(w io.Writer, cell int) error {
+	if cell <= 0 {
+		cell = 4
+	}
+	cols := h.columns()
+	min, max := h.Range()
+	img := image.NewRGBA(image.Rect(0, 0, cols*cell, len(h.Rows)*cell))
+	for r, row := range h.Rows {
+		for c := 0; c < cols; c++ {
+			col := noDataColor
+			if c < len(row) {
+				col = h.Color(row[c], min, max)
+			}
+			for y := r * cell; y < (r+1)*cell; y++ {
+				for x := c * cell; x < (c+1)*cell; x++ {
+					img.SetRGBA(x, y, col)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// WriteANSI writes the heatmap for terminals supporting 24 bit colors.
+// Columns are averaged to fit into width characters.
+func (h *Heatmap) WriteANSI(w io.Writer, width int) error {
+	cols := h.columns()
+	if width <= 0 || width > cols {
+		width = cols
+	}
+	min, max := h.Range()
+	labelWidth := 0
+	for _, l := range h.RowLabels {
+		if len(l) > labelWidth {
+			labelWidth = len(l)
+		}
+	}
+	b := &bytes.Buffer{}
+	if h.Title != "" {
+		fmt.Fprintf(b, "%s\n", h.Title)
+	}
+	for r, row := range h.Rows {
+		label := ""
+		if r < len(h.RowLabels) {
+			label = h.RowLabels[r]
+		}
+		fmt.Fprintf(b, "%*s ", labelWidth, label)
+		for x := 0; x < width; x++ {
+			from, to := x*cols/width, (x+1)*cols/width
+			sum, n := 0.0, 0
+			for c := from; c < to && c < len(row); c++ {
+				if h.Diverging || row[c] != h.NoData {
+					sum += row[c]
+					n++
+				}
+			}
+			v := h.NoData
+			if n > 0 {
+				v = sum / float64(n)
+			}
+			c := h.Color(v, min, max)
+			fmt.Fprintf(b, "\x1b[48;2;%d;%d;%dm ", c.R, c.G, c.B)
+		}
+		b.WriteString("\x1b[0m\n")
+	}
+	fmt.Fprintf(b, "%*s range: %.1f .. %.1f\n", labelWidth, "", min, max)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline returns the values as a line of block characters. Values equal to noData are shown as space.
+func Sparkline(values []float64, noData float64) string {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if v != noData {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+	}
+	ret := make([]rune, len(values))
+	for i, v := range values {
+		switch {
+		case v == noData:
+			ret[i] = ' '
+		case max == min:
+			ret[i] = sparks[len(sparks)/2]
+		default:
+			ret[i] = sparks[int(math.Round((v-min)/(max-min)*float64(len(sparks)-1)))]
+		}
+	}
+	return string(ret)
+}
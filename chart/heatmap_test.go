@@ -0,0 +1,82 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testHeatmap() *Heatmap {
+	return &Heatmap{
+		Title:     "Speeds",
+		Rows:      [][]float64{{100, 150, 0}, {120, 200, 180}},
+		RowLabels: []string{"0:00", "0:05"},
+	}
+}
+
+func TestHeatmapRange(t *testing.T) {
+	h := testHeatmap()
+	if min, max := h.Range(); min != 100 || max != 200 {
+		t.Errorf("got range %v..%v, want 100..200 (no data ignored)", min, max)
+	}
+	h.Diverging = true
+	h.Rows = [][]float64{{-5, 2}}
+	if min, max := h.Range(); min != -5 || max != 5 {
+		t.Errorf("got range %v..%v, want symmetric -5..5", min, max)
+	}
+	if c := h.Color(0, -5, 5); c != white {
+		t.Errorf("0 should be white in diverging scale, got %v", c)
+	}
+}
+
+func TestHeatmapPNG(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := testHeatmap().WritePNG(&buf, 3); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 9 || b.Dy() != 6 {
+		t.Errorf("unexpected image size %v", b)
+	}
+	r, g, b, _ := img.At(7, 1).RGBA()
+	if uint8(r>>8) != noDataColor.R || uint8(g>>8) != noDataColor.G || uint8(b>>8) != noDataColor.B {
+		t.Errorf("missing data should use no data color")
+	}
+}
+
+func TestHeatmapANSI(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := testHeatmap().WriteANSI(&buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected title, 2 rows and range, got %q", lines)
+	}
+	if strings.Count(lines[1], "\x1b[48;2;") != 2 {
+		t.Errorf("expected 2 colored cells per row: %q", lines[1])
+	}
+}
+
+func TestHeatmapSVG(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := testHeatmap().WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), hex(noDataColor)) {
+		t.Errorf("expected no data cell")
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]float64{1, 0, 8, 4.5}, 0); got != "▁ █▅" {
+		t.Errorf("got %q", got)
+	}
+	if got := Sparkline([]float64{3, 3}, 0); got != "▅▅" {
+		t.Errorf("got %q", got)
+	}
+}
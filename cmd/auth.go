@@ -0,0 +1,111 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+/*
+Credentials for admin and dataprovider commands are taken from (first match wins)
+  - the command line (--admin-password/--dataprovider-password, --authid, --authmethod, --password-file, --password-cmd)
+  - the environment (RACELOG_ADMIN_PASSWORD, RACELOG_DATAPROVIDER_PASSWORD)
+  - the admin/dataprovider section of the selected profile in the config file
+  - the admin/dataprovider section of the config file
+
+The global flags --authid, --authmethod, --password-file and --password-cmd apply to
+admin and dataprovider clients alike. Commands using both roles (e.g. the stress commands)
+therefore send the same authid and secret twice; use the admin/dataprovider sections of
+the config file to configure different credentials per role.
+
+Example config:
+
+	profile: local
+	admin:
+	  password_cmd: pass show racelog/admin
+	profiles:
+	  local:
+	    url: tcp://localhost:8090
+	    dataprovider:
+	      authmethod: wampcra
+	      password_file: ~/.racelog/dataprovider.pw
+*/
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&internal.Profile, "profile", "", "use the settings of this profile of the config file")
+	rootCmd.PersistentFlags().StringVar(&internal.AuthId, "authid", "", "authid for admin and dataprovider clients, applies to both roles (default: admin|dataprovider)")
+	rootCmd.PersistentFlags().StringVar(&internal.AuthMethod, "authmethod", "", "authentication method for admin and dataprovider clients (applies to both roles): "+strings.Join(wamp.AuthMethods, "|")+" (default: ticket)")
+	rootCmd.PersistentFlags().StringVar(&internal.PasswordFile, "password-file", "", "read the password for admin and dataprovider clients from this file (applies to both roles)")
+	rootCmd.PersistentFlags().StringVar(&internal.PasswordCmd, "password-cmd", "", "read the password for admin and dataprovider clients from the output of this command (applies to both roles)")
+	connectionFlags = append(connectionFlags, "authid", "authmethod", "password-file", "password-cmd")
+}
+
+// applyProfile merges the selected profile of the config file into the top level configuration
+func applyProfile() {
+	profile := internal.Profile
+	if profile == "" {
+		profile = viper.GetString("profile")
+	}
+	if profile == "" {
+		return
+	}
+	key := "profiles." + profile
+	if !viper.IsSet(key) {
+		log.Fatalf("Profile %s not found in %s\n", profile, viper.ConfigFileUsed())
+	}
+	if err := viper.MergeConfigMap(viper.GetStringMap(key)); err != nil {
+		log.Fatalf("Error applying profile %s: %v\n", profile, err)
+	}
+}
+
+// credentials returns the credentials for the role (admin or dataprovider).
+// password is the value of the command specific password flag.
+func credentials(role string, password string) wamp.Credentials {
+	ret := wamp.Credentials{
+		AuthId:       internal.AuthId,
+		AuthMethod:   internal.AuthMethod,
+		Password:     password,
+		PasswordFile: internal.PasswordFile,
+		PasswordCmd:  internal.PasswordCmd,
+	}
+	ret = ret.Merge(wamp.Credentials{Password: os.Getenv(fmt.Sprintf("%s_%s_PASSWORD", envPrefix, strings.ToUpper(role)))})
+	var cfg wamp.Credentials
+	if err := viper.UnmarshalKey(role, &cfg); err != nil {
+		log.Fatalf("Invalid %s section in config: %v\n", role, err)
+	}
+	return ret.Merge(cfg)
+}
+
+func adminCredentials() wamp.Credentials {
+	return credentials("admin", internal.AdminPassword)
+}
+
+func dataproviderCredentials() wamp.Credentials {
+	return credentials("dataprovider", internal.DataproviderPassword)
+}
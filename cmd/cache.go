@@ -0,0 +1,149 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"racelogctl/cache"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheDir = cache.DefaultDir()         // directory of the local event data cache
+var noCache = false                       // if true, the cache is not used
+var offlineMode = false                   // if true, data is served from the cache only
+var cacheAllServers = false               // if true, cache commands apply to all servers (not just --url)
+var pruneOlderThan = ""                   // prune entries not accessed within this duration
+var trackCacheTTL = cache.DefaultTrackTTL // cached track info older than this is refreshed
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local cache of archived event data",
+	Long: `Manage the local cache of archived event data.
+
+Event info, track info, car data, states and speedmaps of finished events are stored 
+in the cache when they are fetched and used by subsequent commands. 
+Use --offline to work with cached data only and --no-cache to bypass the cache.
+Track info may change on the server, it is refreshed after --track-cache-ttl.
+Stress commands never use the cache.`,
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "Lists the cached events",
+	Run: func(cmd *cobra.Command, args []string) {
+		listCache()
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Removes cached events",
+	Long: `Removes cached events.
+
+Without --older-than all cached events of the server are removed.
+Example:
+racelogctl cache prune --older-than 720h --all-servers`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pruneCache()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", cacheDir, "directory of the local event data cache")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", noCache, "do not use the local event data cache")
+	rootCmd.PersistentFlags().BoolVar(&offlineMode, "offline", offlineMode, "do not connect to the server. Use cached data only")
+	cacheCmd.PersistentFlags().BoolVar(&cacheAllServers, "all-servers", cacheAllServers, "apply to the cached events of all servers")
+	cachePruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", pruneOlderThan, "remove only events not accessed within this duration (for example 720h)")
+	rootCmd.PersistentFlags().DurationVar(&trackCacheTTL, "track-cache-ttl", trackCacheTTL, "refresh cached track info older than this (0: never)")
+}
+
+// setupCache configures the cache for the wamp clients.
+// Called after the config file was read.
+func setupCache() {
+	applyConfig("cache-dir", "no-cache", "offline", "track-cache-ttl")
+	if noCache {
+		if offlineMode {
+			log.Fatalf("--offline requires the cache")
+		}
+		return
+	}
+	wamp.UseCache(cache.New(cacheDir).SetTrackTTL(trackCacheTTL), offlineMode)
+}
+
+func cacheServer() string {
+	if cacheAllServers {
+		return ""
+	}
+	return internal.Url
+}
+
+func listCache() {
+	entries, err := cache.New(cacheDir).List(cacheServer())
+	if err != nil {
+		log.Fatalf("Error reading cache: %v", err)
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+		fmt.Printf("%-40s %5d %-40s %9s %s %v\n", e.Url, e.EventId, e.Name, formatBytes(e.Size), e.LastAccess.Format("2006-01-02 15:04"), e.Items)
+	}
+	fmt.Printf("%d events, %s in %s\n", len(entries), formatBytes(total), cacheDir)
+}
+
+func pruneCache() {
+	var maxAge time.Duration
+	if pruneOlderThan != "" {
+		var err error
+		if maxAge, err = time.ParseDuration(pruneOlderThan); err != nil {
+			log.Fatalf("Invalid duration %v: %v", pruneOlderThan, err)
+		}
+	}
+	removed, err := cache.New(cacheDir).Prune(cacheServer(), maxAge)
+	for _, e := range removed {
+		fmt.Printf("removed %s %d %s\n", e.Url, e.EventId, e.Name)
+	}
+	if err != nil {
+		log.Fatalf("Error pruning cache: %v", err)
+	}
+	fmt.Printf("%d events removed\n", len(removed))
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&internal.Serializer, "serializer", "json",
+		"WAMP serializer: "+strings.Join(wamp.SerializerNames, "|")+". msgpack and cbor reduce the size of bulk transfers")
+	rootCmd.PersistentFlags().StringVar(&internal.TlsCaFile, "tls-ca", "", "CA certificates (PEM) to verify the server (wss:// and tcps:// only)")
+	rootCmd.PersistentFlags().StringVar(&internal.TlsCertFile, "tls-cert", "", "client certificate (PEM) (wss:// and tcps:// only)")
+	rootCmd.PersistentFlags().StringVar(&internal.TlsKeyFile, "tls-key", "", "key of the client certificate (PEM)")
+	rootCmd.PersistentFlags().BoolVar(&internal.TlsInsecure, "tls-insecure", false, "do not verify the server certificate (wss:// and tcps:// only)")
+}
+
+// connectionFlags are applied from the config file or environment when not given on the command line
+var connectionFlags = []string{"serializer", "tls-ca", "tls-cert", "tls-key", "tls-insecure"}
+
+// setupConnection configures the connection settings for the wamp clients
+func setupConnection() {
+	applyConfig(connectionFlags...)
+	if err := wamp.UseSerializer(internal.Serializer); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	wamp.UseTLS(wamp.TLSOptions{
+		CAFile:             internal.TlsCaFile,
+		CertFile:           internal.TlsCertFile,
+		KeyFile:            internal.TlsKeyFile,
+		InsecureSkipVerify: internal.TlsInsecure,
+	})
+}
+
+// applyConfig sets the global flags from the config file or environment (RACELOG_<NAME>)
+// when not given on the command line
+func applyConfig(names ...string) {
+	for _, name := range names {
+		viper.BindEnv(name, fmt.Sprintf("%s_%s", envPrefix, strings.ToUpper(strings.ReplaceAll(name, "-", "_"))))
+		if f := rootCmd.PersistentFlags().Lookup(name); !f.Changed && viper.IsSet(name) {
+			f.Value.Set(viper.GetString(name))
+		}
+	}
+}
@@ -22,17 +22,29 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"racelogctl/analysis"
+	"racelogctl/chart"
 	"racelogctl/internal"
 	"racelogctl/wamp"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var (
+	avgLapsFormat string // output format: text|csv|json|html|svg
+	avgLapsOutput string // output filename for html|svg
+)
+
 // avgLapsCmd represents the avgLaps command
 var avgLapsCmd = &cobra.Command{
 	Use:   "avgLaps <eventId>",
@@ -64,16 +76,29 @@ func init() {
 	eventCmd.AddCommand(avgLapsCmd)
 
 	avgLapsCmd.Flags().IntVar(&internal.Interval, "interval", 300, "Interval in seconds")
+	avgLapsCmd.Flags().StringVarP(&avgLapsFormat, "format", "f", "text", "Output format: text|csv|json|html|svg")
+	avgLapsCmd.Flags().BoolVarP(&internal.JsonPretty, "pretty", "p", false, "use pretty json format. (Default: false)")
+	avgLapsCmd.Flags().StringVar(&avgLapsOutput, "output", "", "Output filename for html|svg. (Default: event-<eventId>-avglaps.<format>)")
+}
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// avgLapsCmd.PersistentFlags().String("foo", "", "A help for foo")
+// avgLapClass summarizes the average lap times of a car class
+type avgLapClass struct {
+	Id                   int      `json:"id"`
+	Name                 string   `json:"name"`
+	Min                  float64  `json:"min"`
+	Max                  float64  `json:"max"`
+	TrackTempCorrelation *float64 `json:"trackTempCorrelation"`
+	TimeOfDayCorrelation *float64 `json:"timeOfDayCorrelation"`
+	laptimes             []float64
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// avgLapsCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// avgLapEntry is an entry of the json output
+type avgLapEntry struct {
+	Timestamp   float64            `json:"timestamp"`
+	SessionTime float64            `json:"sessionTime"`
+	TimeOfDay   float64            `json:"timeOfDay"`
+	TrackTemp   float64            `json:"trackTemp"`
+	Laptimes    map[string]float64 `json:"laptimes"`
 }
 
 func eventAvgLaps(id int) {
@@ -84,7 +109,186 @@ func eventAvgLaps(id int) {
 	if err != nil {
 		log.Fatalf("Error reading avgLaps: %v", err)
 	}
+	carData, err := pc.GetCarData(id)
+	if err != nil {
+		log.Printf("No car data for event %d, using class ids: %v\n", id, err)
+	}
+	names := analysis.ClassNames(carData)
+	trackTemps := make([]float64, len(avgLaps))
+	timeOfDays := make([]float64, len(avgLaps))
+	for i, item := range avgLaps {
+		trackTemps[i] = item.TrackTemp
+		timeOfDays[i] = item.TimeOfDay
+	}
+	classes := []*avgLapClass{}
+	for _, classId := range analysis.ClassIds(avgLaps) {
+		c := &avgLapClass{Id: classId, Name: names[classId], laptimes: analysis.ClassLapTimes(avgLaps, classId)}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("class %d", classId)
+		}
+		c.Min, c.Max = math.Inf(1), 0
+		for _, v := range c.laptimes {
+			if v > 0 {
+				c.Min, c.Max = math.Min(c.Min, v), math.Max(c.Max, v)
+			}
+		}
+		if c.Max == 0 {
+			c.Min = 0
+		}
+		c.TrackTempCorrelation = optionalFloat(analysis.Correlation(trackTemps, c.laptimes))
+		c.TimeOfDayCorrelation = optionalFloat(analysis.Correlation(timeOfDays, c.laptimes))
+		classes = append(classes, c)
+	}
+
+	switch avgLapsFormat {
+	case "json":
+		printAvgLapsJson(avgLaps, classes)
+	case "csv":
+		printAvgLapsCsv(avgLaps, classes)
+	case "html", "svg":
+		writeAvgLapsChart(id, avgLaps, classes)
+	default:
+		printAvgLapsText(avgLaps, classes)
+	}
+}
+
+func optionalFloat(v float64) *float64 {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return &v
+}
+
+func formatOptionalLapTime(v float64) string {
+	if v <= 0 {
+		return "-"
+	}
+	return formatLapTime(v)
+}
+
+func formatCorrelation(v *float64) string {
+	if v == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f", *v)
+}
+
+func printAvgLapsText(avgLaps []*internal.AverageLapTime, classes []*avgLapClass) {
+	fmt.Printf("%-9s %-6s %6s", "Session", "ToD", "Track")
+	for _, c := range classes {
+		fmt.Printf(" %10s", c.Name)
+	}
+	fmt.Println()
 	for _, item := range avgLaps {
-		fmt.Printf("%.0f (%s): track: %.0f %+v\n", item.Timestamp, time.Unix(int64(item.Timestamp), 0), item.TrackTemp, item.Laptimes)
+		fmt.Printf("%-9s %-6s %6.1f", formatSessionTime(item.SessionTime), formatTimeOfDay(item.TimeOfDay), item.TrackTemp)
+		for _, c := range classes {
+			fmt.Printf(" %10s", formatOptionalLapTime(item.Laptimes[c.Id]))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	for _, c := range classes {
+		fmt.Printf("%s\n  %s\n  min %s  max %s  correlation track temp %s  time of day %s\n",
+			c.Name, chart.Sparkline(c.laptimes, 0), formatOptionalLapTime(c.Min), formatOptionalLapTime(c.Max),
+			formatCorrelation(c.TrackTempCorrelation), formatCorrelation(c.TimeOfDayCorrelation))
 	}
 }
+
+func printAvgLapsCsv(avgLaps []*internal.AverageLapTime, classes []*avgLapClass) {
+	w := csv.NewWriter(os.Stdout)
+	header := []string{"timestamp", "sessionTime", "timeOfDay", "trackTemp"}
+	for _, c := range classes {
+		header = append(header, c.Name)
+	}
+	w.Write(header)
+	for _, item := range avgLaps {
+		row := []string{
+			strconv.FormatFloat(item.Timestamp, 'f', 3, 64),
+			strconv.FormatFloat(item.SessionTime, 'f', 3, 64),
+			strconv.FormatFloat(item.TimeOfDay, 'f', 0, 64),
+			strconv.FormatFloat(item.TrackTemp, 'f', 1, 64),
+		}
+		for _, c := range classes {
+			if v := item.Laptimes[c.Id]; v > 0 {
+				row = append(row, strconv.FormatFloat(v, 'f', 3, 64))
+			} else {
+				row = append(row, "")
+			}
+		}
+		w.Write(row)
+	}
+	w.Flush()
+}
+
+func printAvgLapsJson(avgLaps []*internal.AverageLapTime, classes []*avgLapClass) {
+	entries := make([]avgLapEntry, 0, len(avgLaps))
+	for _, item := range avgLaps {
+		e := avgLapEntry{Timestamp: item.Timestamp, SessionTime: item.SessionTime, TimeOfDay: item.TimeOfDay,
+			TrackTemp: item.TrackTemp, Laptimes: map[string]float64{}}
+		for _, c := range classes {
+			if v := item.Laptimes[c.Id]; v > 0 {
+				e.Laptimes[c.Name] = v
+			}
+		}
+		entries = append(entries, e)
+	}
+	data := map[string]interface{}{"entries": entries, "classes": classes}
+	var jsonData []byte
+	if internal.JsonPretty {
+		jsonData, _ = json.MarshalIndent(data, "", "  ")
+	} else {
+		jsonData, _ = json.Marshal(data)
+	}
+	fmt.Printf("%s\n", jsonData)
+}
+
+func writeAvgLapsChart(id int, avgLaps []*internal.AverageLapTime, classes []*avgLapClass) {
+	items := []chart.Drawable{}
+	for _, c := range classes {
+		s := chart.Series{Name: c.Name}
+		for i, item := range avgLaps {
+			if c.laptimes[i] > 0 {
+				s.Points = append(s.Points, chart.Point{X: item.SessionTime, Y: c.laptimes[i]})
+			}
+		}
+		items = append(items, &chart.Chart{
+			Title:   fmt.Sprintf("Average lap time - %s (track temp correlation %s)", c.Name, formatCorrelation(c.TrackTempCorrelation)),
+			XLabel:  "Session time",
+			YLabel:  "Lap time",
+			Series:  []chart.Series{s},
+			XFormat: formatSessionTime,
+			YFormat: formatLapTime,
+		})
+	}
+	output := avgLapsOutput
+	if output == "" {
+		output = fmt.Sprintf("event-%d-avglaps.%s", id, avgLapsFormat)
+	}
+	if avgLapsFormat == "html" {
+		err := writeFile(output, func(f *os.File) error {
+			return chart.WriteHTML(f, fmt.Sprintf("Average lap times event %d", id), items)
+		})
+		if err != nil {
+			log.Fatalf("Error writing chart: %v", err)
+		}
+		fmt.Printf("Created %s\n", output)
+		return
+	}
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+	for i, item := range items {
+		filename := output
+		if len(items) > 1 {
+			filename = fmt.Sprintf("%s-%s.svg", base, fileSafe(classes[i].Name))
+		}
+		if err := writeFile(filename, func(f *os.File) error { return item.WriteSVG(f) }); err != nil {
+			log.Fatalf("Error writing chart: %v", err)
+		}
+		fmt.Printf("Created %s\n", filename)
+	}
+}
+
+// formatTimeOfDay formats seconds since midnight as hh:mm
+func formatTimeOfDay(seconds float64) string {
+	s := int(seconds) % 86400
+	return fmt.Sprintf("%02d:%02d", s/3600, s%3600/60)
+}
@@ -0,0 +1,224 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"racelogctl/analysis"
+	"racelogctl/chart"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	chartKind   string // the kind of chart to render
+	chartFormat string // output format: html|svg
+	chartOutput string // output filename
+	chartNum    int    // page size for states
+)
+
+// chartCmd represents the event chart command
+var chartCmd = &cobra.Command{
+	Use:   "chart <eventId>",
+	Short: "Renders race charts of an event as SVG or HTML",
+	Long: `Renders race charts of an event as SVG or HTML.
+
+Kinds:
+  positions  position in class by lap
+  gaps       gap to the class leader over session time
+  laptimes   lap times by lap
+
+One chart per car class is created. HTML output (default) contains all charts
+in one file, SVG output creates one file per class (<output>-<class>.svg).
+The files don't have any network dependencies.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		eventId, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("Invalid event id %v: %v", args[0], err)
+		}
+		eventChart(eventId)
+	},
+}
+
+func init() {
+	eventCmd.AddCommand(chartCmd)
+
+	chartCmd.Flags().StringVar(&chartKind, "kind", "positions", "Kind of chart: positions|gaps|laptimes")
+	chartCmd.Flags().StringVarP(&chartFormat, "format", "f", "html", "Output format: html|svg")
+	chartCmd.Flags().StringVar(&chartOutput, "output", "", "Output filename. (Default: event-<eventId>-<kind>.<format>)")
+	chartCmd.Flags().IntVar(&chartNum, "num", 100, "How many states should be fetched in one request")
+}
+
+// eventLaps fetches all states of the event (pageSize per request) and returns the completed laps
+func eventLaps(pc *wamp.PublicClient, event *internal.Event, pageSize int) []analysis.Lap {
+	collector := analysis.NewLapCollector(event.Data.Manifests)
+	states := pc.StateIterator(int(event.Id), event.Data.ReplayInfo.MinTimestamp, pageSize)
+	defer states.Close()
+	for states.Next() {
+		collector.Add(states.Value())
+	}
+	if err := states.Err(); err != nil {
+		log.Fatalf("Error fetching states: %v\n", err)
+	}
+	return collector.Laps()
+}
+
+func eventChart(eventId int) {
+	if chartFormat != "html" && chartFormat != "svg" {
+		log.Fatalf("Unknown output format %s", chartFormat)
+	}
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	event, err := pc.GetEvent(eventId)
+	if err != nil {
+		log.Fatalf("Error getting event: %v\n", err)
+	}
+	classes, byClass := analysis.ByClass(eventLaps(pc, event, chartNum))
+	charts := make([]*chart.Chart, 0, len(classes))
+	items := make([]chart.Drawable, 0, len(classes))
+	for _, class := range classes {
+		c, err := lapChart(chartKind, byClass[class])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if class != "" {
+			c.Title = fmt.Sprintf("%s - %s", c.Title, class)
+		}
+		charts = append(charts, c)
+		items = append(items, c)
+	}
+
+	output := chartOutput
+	if output == "" {
+		output = fmt.Sprintf("event-%d-%s.%s", eventId, chartKind, chartFormat)
+	}
+	switch chartFormat {
+	case "html":
+		err = writeFile(output, func(f *os.File) error {
+			return chart.WriteHTML(f, fmt.Sprintf("%s - %s", event.Name, event.Data.Info.TrackDisplayName), items)
+		})
+		if err == nil {
+			fmt.Printf("Created %s\n", output)
+		}
+	case "svg":
+		base := strings.TrimSuffix(output, filepath.Ext(output))
+		for i, c := range charts {
+			filename := fmt.Sprintf("%s.svg", base)
+			if len(charts) > 1 {
+				filename = fmt.Sprintf("%s-%s.svg", base, fileSafe(classes[i]))
+			}
+			if err = writeFile(filename, func(f *os.File) error { return c.WriteSVG(f) }); err != nil {
+				break
+			}
+			fmt.Printf("Created %s\n", filename)
+		}
+	default:
+		log.Fatalf("Unknown output format %s", chartFormat)
+	}
+	if err != nil {
+		log.Fatalf("Error writing chart: %v\n", err)
+	}
+}
+
+// lapChart creates the chart of the given kind for the laps of one class
+func lapChart(kind string, laps []analysis.Lap) (*chart.Chart, error) {
+	c := &chart.Chart{}
+	var value func(l analysis.Lap) (chart.Point, bool)
+	switch kind {
+	case "positions":
+		c.Title, c.XLabel, c.YLabel, c.InvertY = "Positions", "Lap", "Position", true
+		value = func(l analysis.Lap) (chart.Point, bool) {
+			return chart.Point{X: float64(l.Lap), Y: float64(l.PIC)}, true
+		}
+	case "gaps":
+		c.Title, c.XLabel, c.YLabel, c.InvertY = "Gap to leader", "Session time", "Gap (s)", true
+		c.XFormat = formatSessionTime
+		value = func(l analysis.Lap) (chart.Point, bool) { return chart.Point{X: l.SessionTime, Y: l.ClassGap}, true }
+	case "laptimes":
+		c.Title, c.XLabel, c.YLabel, c.Scatter = "Lap times", "Lap", "Lap time", true
+		c.YFormat = formatLapTime
+		value = func(l analysis.Lap) (chart.Point, bool) {
+			return chart.Point{X: float64(l.Lap), Y: l.LapTime}, l.LapTime > 0
+		}
+	default:
+		return nil, fmt.Errorf("unknown chart kind %s", kind)
+	}
+	series := map[string]*chart.Series{}
+	order := []string{}
+	for _, l := range laps {
+		s, ok := series[l.CarNum]
+		if !ok {
+			s = &chart.Series{Name: "#" + l.CarNum}
+			series[l.CarNum] = s
+			order = append(order, l.CarNum)
+		}
+		if p, ok := value(l); ok {
+			s.Points = append(s.Points, p)
+		}
+	}
+	// legend ordered by the final position in class
+	final := map[string]int{}
+	for _, l := range laps {
+		final[l.CarNum] = l.PIC
+	}
+	sort.SliceStable(order, func(i, j int) bool { return final[order[i]] < final[order[j]] })
+	for _, carNum := range order {
+		c.Series = append(c.Series, *series[carNum])
+	}
+	return c, nil
+}
+
+// formatLapTime formats seconds as m:ss.sss
+func formatLapTime(seconds float64) string {
+	return fmt.Sprintf("%d:%06.3f", int(seconds)/60, seconds-float64(int(seconds)/60*60))
+}
+
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' || r == ':' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func writeFile(filename string, write func(f *os.File) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
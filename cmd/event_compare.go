@@ -0,0 +1,360 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"racelogctl/analysis"
+	"racelogctl/chart"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareForce  bool   // compare events on different tracks
+	compareFormat string // output format: text|json|html
+	compareOutput string // output filename for html
+	compareNum    int    // page size for states and speedmaps
+)
+
+// eventCompareCmd represents the event compare command
+var eventCompareCmd = &cobra.Command{
+	Use:   "compare <eventIdA> <eventIdB>",
+	Short: "Compares two events on the same track",
+	Long: `Compares two events on the same track.
+
+The report contains per class best, average and median lap times, the average
+lap times over the race, the conditions (session columns like air and track 
+temperature, wind) and the average speeds by track position (speedmaps).
+Differences are shown as B-A.
+`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := make([]int, len(args))
+		for i, arg := range args {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				log.Fatalf("Invalid event id %v: %v", arg, err)
+			}
+			ids[i] = id
+		}
+		compareEvents(ids[0], ids[1])
+	},
+}
+
+func init() {
+	eventCmd.AddCommand(eventCompareCmd)
+
+	eventCompareCmd.Flags().StringVarP(&compareFormat, "format", "f", "text", "Output format: text|json|html.")
+	eventCompareCmd.Flags().BoolVarP(&internal.JsonPretty, "pretty", "p", false, "use pretty json format. (Default: false)")
+	eventCompareCmd.Flags().StringVar(&compareOutput, "output", "", "Output filename for html. (Default: compare-<idA>-<idB>.html)")
+	eventCompareCmd.Flags().IntVar(&compareNum, "num", 100, "How many states should be fetched in one request")
+	eventCompareCmd.Flags().IntVar(&internal.Interval, "interval", 300, "Interval in seconds for average lap times")
+	eventCompareCmd.Flags().BoolVar(&compareForce, "force", false, "Compare events even if they were recorded on different tracks")
+}
+
+// comparedEvent holds the data of an event used for comparison
+type comparedEvent struct {
+	Id        int                    `json:"id"`
+	Name      string                 `json:"name"`
+	Recorded  string                 `json:"recorded"`
+	Track     string                 `json:"track"`
+	Pace      []analysis.ClassPace   `json:"pace"`
+	Weather   []analysis.ValueStats  `json:"weather"`
+	AvgLaps   map[string][]avgLapRow `json:"avgLaps"`  // by class name
+	Speedmaps map[string][]float64   `json:"speedmap"` // average chunk speeds by class name
+	event     *internal.Event
+}
+
+type avgLapRow struct {
+	SessionTime float64 `json:"sessionTime"`
+	Laptime     float64 `json:"laptime"`
+}
+
+func loadComparedEvent(pc *wamp.PublicClient, id int) *comparedEvent {
+	event, err := pc.GetEvent(id)
+	if err != nil {
+		log.Fatalf("Error getting event %d: %v\n", id, err)
+	}
+	ret := &comparedEvent{
+		Id: id, Name: event.Name, Recorded: event.RecordDate, Track: event.Data.Info.TrackDisplayName,
+		AvgLaps: map[string][]avgLapRow{}, Speedmaps: map[string][]float64{}, event: event,
+	}
+
+	laps := analysis.NewLapCollector(event.Data.Manifests)
+	weather := analysis.NewWeatherCollector(event.Data.Manifests)
+	states := pc.StateIterator(id, event.Data.ReplayInfo.MinTimestamp, compareNum)
+	defer states.Close()
+	for states.Next() {
+		laps.Add(states.Value())
+		weather.Add(states.Value())
+	}
+	if err := states.Err(); err != nil {
+		log.Fatalf("Error fetching states of event %d: %v\n", id, err)
+	}
+	ret.Pace = analysis.Pace(laps.Laps())
+	ret.Weather = weather.Stats()
+
+	carData, _ := pc.GetCarData(id)
+	names := analysis.ClassNames(carData)
+	if avgLaps, err := pc.GetEventAvgLaps(id, internal.Interval); err != nil {
+		log.Printf("No average lap times for event %d: %v\n", id, err)
+	} else {
+		for _, classId := range analysis.ClassIds(avgLaps) {
+			name := names[classId]
+			if name == "" {
+				name = fmt.Sprintf("class %d", classId)
+			}
+			for _, item := range avgLaps {
+				if v := item.Laptimes[classId]; v > 0 {
+					ret.AvgLaps[name] = append(ret.AvgLaps[name], avgLapRow{SessionTime: item.SessionTime, Laptime: v})
+				}
+			}
+		}
+	}
+
+	speedmaps := []*internal.SpeedmapMessage{}
+	it := pc.SpeedmapIterator(id, event.Data.ReplayInfo.MinTimestamp, compareNum)
+	defer it.Close()
+	for it.Next() {
+		speedmaps = append(speedmaps, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		log.Fatalf("Error fetching speedmaps of event %d: %v\n", id, err)
+	}
+	for _, class := range analysis.SpeedmapClasses(speedmaps) {
+		agg := analysis.NewSpeedmapAggregator(analysis.SpeedmapFilter{Class: class}, 0)
+		for _, s := range speedmaps {
+			agg.Add(s)
+		}
+		ret.Speedmaps[class] = agg.Heatmap().Profile()
+	}
+	return ret
+}
+
+func compareEvents(idA, idB int) {
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	a := loadComparedEvent(pc, idA)
+	b := loadComparedEvent(pc, idB)
+	if a.event.Data.Info.TrackId != b.event.Data.Info.TrackId {
+		msg := fmt.Sprintf("Events were recorded on different tracks (%s, %s)", a.Track, b.Track)
+		if !compareForce {
+			log.Fatalf("%s. Use --force to compare anyway.\n", msg)
+		}
+		log.Println(msg)
+	}
+
+	switch compareFormat {
+	case "json":
+		var jsonData []byte
+		data := map[string]*comparedEvent{"a": a, "b": b}
+		if internal.JsonPretty {
+			jsonData, _ = json.MarshalIndent(data, "", "  ")
+		} else {
+			jsonData, _ = json.Marshal(data)
+		}
+		fmt.Printf("%s\n", jsonData)
+	case "html":
+		writeCompareHtml(a, b)
+	default:
+		printCompare(a, b)
+	}
+}
+
+func paceByClass(pace []analysis.ClassPace) map[string]analysis.ClassPace {
+	ret := map[string]analysis.ClassPace{}
+	for _, p := range pace {
+		ret[p.Class] = p
+	}
+	return ret
+}
+
+// unionKeys returns the keys of a followed by the keys only in b
+func unionKeys(a, b []string) []string {
+	seen := map[string]bool{}
+	ret := []string{}
+	for _, list := range [][]string{a, b} {
+		for _, k := range list {
+			if !seen[k] {
+				seen[k] = true
+				ret = append(ret, k)
+			}
+		}
+	}
+	return ret
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func formatLapDelta(a, b float64) string {
+	if a <= 0 || b <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.3f", b-a)
+}
+
+func printCompare(a, b *comparedEvent) {
+	for _, e := range []struct {
+		label string
+		data  *comparedEvent
+	}{{"A", a}, {"B", b}} {
+		fmt.Printf("%s: %d %s (%s) %s\n", e.label, e.data.Id, e.data.Name, e.data.Recorded, e.data.Track)
+	}
+
+	fmt.Printf("\nPace\n%-12s %10s %10s %8s %10s %10s %8s %10s %10s %8s %7s\n",
+		"Class", "Best A", "Best B", "Δ", "Avg A", "Avg B", "Δ", "Median A", "Median B", "Δ", "Cars")
+	paceA, paceB := paceByClass(a.Pace), paceByClass(b.Pace)
+	classes := []string{}
+	for _, list := range [][]analysis.ClassPace{a.Pace, b.Pace} {
+		names := []string{}
+		for _, p := range list {
+			names = append(names, p.Class)
+		}
+		classes = unionKeys(classes, names)
+	}
+	for _, class := range classes {
+		pa, pb := paceA[class], paceB[class]
+		fmt.Printf("%-12s %10s %10s %8s %10s %10s %8s %10s %10s %8s %7s\n", truncate(class, 12),
+			formatOptionalLapTime(pa.Best), formatOptionalLapTime(pb.Best), formatLapDelta(pa.Best, pb.Best),
+			formatOptionalLapTime(pa.Avg), formatOptionalLapTime(pb.Avg), formatLapDelta(pa.Avg, pb.Avg),
+			formatOptionalLapTime(pa.Median), formatOptionalLapTime(pb.Median), formatLapDelta(pa.Median, pb.Median),
+			fmt.Sprintf("%d/%d", pa.Cars, pb.Cars))
+	}
+
+	fmt.Printf("\nConditions (min/avg/max)\n")
+	weatherB := map[string]analysis.ValueStats{}
+	for _, w := range b.Weather {
+		weatherB[w.Name] = w
+	}
+	for _, wa := range a.Weather {
+		wb, ok := weatherB[wa.Name]
+		if !ok {
+			continue
+		}
+		fmt.Printf("%-14s A %8.1f %8.1f %8.1f   B %8.1f %8.1f %8.1f   Δavg %+.1f\n",
+			wa.Name, wa.Min, wa.Avg, wa.Max, wb.Min, wb.Avg, wb.Max, wb.Avg-wa.Avg)
+	}
+
+	fmt.Printf("\nAverage lap times over the race\n")
+	for _, class := range unionKeys(sortedKeys(a.AvgLaps), sortedKeys(b.AvgLaps)) {
+		fmt.Printf("%s\n  A %s\n  B %s\n", class, chart.Sparkline(avgLapValues(a.AvgLaps[class]), 0),
+			chart.Sparkline(avgLapValues(b.AvgLaps[class]), 0))
+	}
+
+	fmt.Printf("\nSpeedmaps\n")
+	for _, class := range sortedKeys(a.Speedmaps) {
+		sb, ok := b.Speedmaps[class]
+		if !ok {
+			continue
+		}
+		diff, err := analysis.SpeedDiff(a.Speedmaps[class], sb)
+		if err != nil {
+			fmt.Printf("%s: %v\n", class, err)
+			continue
+		}
+		fmt.Printf("%s: average speed A %.1f km/h, B %.1f km/h\n", class, averageSpeed(a.Speedmaps[class]), averageSpeed(sb))
+		printSpeedDifferences(diff, a.event.Data.Info.TrackLength)
+	}
+}
+
+func avgLapValues(rows []avgLapRow) []float64 {
+	ret := make([]float64, len(rows))
+	for i, r := range rows {
+		ret[i] = r.Laptime
+	}
+	return ret
+}
+
+func averageSpeed(profile []float64) float64 {
+	sum, n := 0.0, 0
+	for _, v := range profile {
+		if v > 0 {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func writeCompareHtml(a, b *comparedEvent) {
+	items := []chart.Drawable{}
+	for _, class := range unionKeys(sortedKeys(a.AvgLaps), sortedKeys(b.AvgLaps)) {
+		c := &chart.Chart{
+			Title: fmt.Sprintf("Average lap time - %s", class), XLabel: "Session time", YLabel: "Lap time",
+			XFormat: formatSessionTime, YFormat: formatLapTime,
+		}
+		for _, e := range []*comparedEvent{a, b} {
+			s := chart.Series{Name: fmt.Sprintf("%d %s", e.Id, e.Name)}
+			for _, r := range e.AvgLaps[class] {
+				s.Points = append(s.Points, chart.Point{X: r.SessionTime, Y: r.Laptime})
+			}
+			c.Series = append(c.Series, s)
+		}
+		items = append(items, c)
+	}
+	for _, class := range sortedKeys(a.Speedmaps) {
+		if _, ok := b.Speedmaps[class]; !ok {
+			continue
+		}
+		c := &chart.Chart{Title: fmt.Sprintf("Average speed - %s", class), XLabel: "Track position (%)", YLabel: "Speed (km/h)"}
+		for _, e := range []*comparedEvent{a, b} {
+			s := chart.Series{Name: fmt.Sprintf("%d %s", e.Id, e.Name)}
+			profile := e.Speedmaps[class]
+			for i, v := range profile {
+				if v > 0 {
+					s.Points = append(s.Points, chart.Point{X: 100 * float64(i) / float64(len(profile)), Y: v})
+				}
+			}
+			c.Series = append(c.Series, s)
+		}
+		items = append(items, c)
+	}
+	output := compareOutput
+	if output == "" {
+		output = fmt.Sprintf("compare-%d-%d.html", a.Id, b.Id)
+	}
+	err := writeFile(output, func(f *os.File) error {
+		return chart.WriteHTML(f, fmt.Sprintf("%s vs %s (%s)", a.Name, b.Name, a.Track), items)
+	})
+	if err != nil {
+		log.Fatalf("Error writing report: %v\n", err)
+	}
+	fmt.Printf("Created %s\n", output)
+}
@@ -46,7 +46,7 @@ var copyCmd = &cobra.Command{
 Example: This copies the event with id 42 from the server running at crossbar.mydomain.com 
 racelogctl event copy 42 \
    --source-url wss://crossbar.mydomain.com/ws \
-   --dataprovider-password verySecret
+   --password-file ~/.racelog/dataprovider.pw
 `,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// You can bind cobra and viper in a few locations, but PersistencePreRunE on the root command works well
@@ -70,7 +70,7 @@ racelogctl event copy 42 \
 
 func init() {
 	eventCmd.AddCommand(copyCmd)
-	copyCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action")
+	copyCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action (prefer RACELOG_DATAPROVIDER_PASSWORD, --password-file or --password-cmd)")
 
 	copyCmd.Flags().StringVar(&internal.SourceUrl, "source-url", "", "sets the url of the source server")
 
@@ -121,7 +121,7 @@ func eventCopy(eventId int) {
 		log.Fatalf("Track not found")
 	}
 
-	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, internal.DataproviderPassword)
+	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
 	uuid, _ := uuid.NewRandom()
 	md5 := md5.New()
 	md5.Write([]byte(uuid.String()))
@@ -163,30 +163,24 @@ func eventCopy(eventId int) {
 func copyStandardData(param copyParam) {
 	log.Println("begin copy states")
 
-	fetches := 0
 	numPackets := 0
 
 	sender := make(chan internal.State)
+	senderDone := param.target.PublishStateFromChannel(param.targetEventKey, sender)
 
-	param.target.PublishStateFromChannel(param.targetEventKey, sender)
-
-	from := 0.0
-	for goon := true; goon; {
-		// fmt.Printf("Fetching %d states beginning at %d\n", numStates, int64(from))
-		states := param.source.GetStates(param.sourceEventId, from, 100)
-		fetches += 1
-		numPackets += len(states)
-		// fmt.Printf("Got %v states\n", len(states))
-		goon = len(states) > 0 // == numStates
-		if goon {
-			for _, state := range states {
-				sender <- state
-			}
-			from = states[len(states)-1].Timestamp + 0.0001
-		}
-
+	states := param.source.StateIterator(param.sourceEventId, 0, 100)
+	for states.Next() {
+		sender <- states.Value()
+		numPackets++
+	}
+	close(sender)
+	if err := <-senderDone; err != nil {
+		log.Fatalf("Error publishing states: %v", err)
+	}
+	if states.Err() != nil {
+		log.Fatalf("Error fetching states: %v", states.Err())
 	}
-	log.Printf("done copy states: fetches %d packets: %d", fetches, numPackets)
+	log.Printf("done copy states: fetches %d packets: %d", states.Pages(), numPackets)
 
 }
 
@@ -194,7 +188,9 @@ func copyCarData(param copyParam) {
 	log.Println("begin copy car data")
 
 	carData, _ := param.source.GetCarData(param.sourceEventId)
-	param.target.PublishCarData(param.targetEventKey, carData)
+	if err := param.target.PublishCarData(param.targetEventKey, carData); err != nil {
+		log.Fatalf("Error publishing car data: %v", err)
+	}
 
 	log.Println("done copy car data")
 
@@ -203,27 +199,21 @@ func copyCarData(param copyParam) {
 func copySpeedData(param copyParam) {
 	log.Println("begin copy speedmap data")
 	sender := make(chan internal.SpeedmapMessage)
-	fetches := 0
 	numPackets := 0
 
-	param.target.PublishSpeedmapDataFromChannel(param.targetEventKey, sender)
-
-	from := 0.0
-	for goon := true; goon; {
-		// fmt.Printf("Fetching %d speedmaps beginning at %d\n", numStates, int64(from))
-		speedmaps, _ := param.source.GetSpeedmaps(param.sourceEventId, from, 100)
-
-		fetches += 1
-		numPackets += len(speedmaps)
-		// fmt.Printf("Got %v states\n", len(speedmaps))
-		goon = len(speedmaps) > 0 // == numStates
-		if goon {
-			for _, speedmap := range speedmaps {
-				sender <- *speedmap
-			}
-			from = speedmaps[len(speedmaps)-1].Timestamp + 0.0001
-		}
+	senderDone := param.target.PublishSpeedmapDataFromChannel(param.targetEventKey, sender)
 
+	speedmaps := param.source.SpeedmapIterator(param.sourceEventId, 0, 100)
+	for speedmaps.Next() {
+		sender <- *speedmaps.Value()
+		numPackets++
+	}
+	close(sender)
+	if err := <-senderDone; err != nil {
+		log.Fatalf("Error publishing speedmaps: %v", err)
+	}
+	if speedmaps.Err() != nil {
+		log.Fatalf("Error fetching speedmaps: %v", speedmaps.Err())
 	}
-	log.Printf("done copy speedmaps: fetches %d packets: %d", fetches, numPackets)
+	log.Printf("done copy speedmaps: fetches %d packets: %d", speedmaps.Pages(), numPackets)
 }
@@ -62,8 +62,8 @@ func init() {
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
-	deleteCmd.Flags().StringVarP(&internal.AdminPassword, "admin-password", "p", "", "sets the admin password for this action")
-	// deleteCmd.Flags().IntVarP(&eventId, "eventId", "e", -1, "sets the admin password for this action")
+	deleteCmd.Flags().StringVarP(&internal.AdminPassword, "admin-password", "p", "", "sets the admin password for this action (prefer RACELOG_ADMIN_PASSWORD, --password-file or --password-cmd)")
+	// deleteCmd.Flags().IntVarP(&eventId, "eventId", "e", -1, "sets the admin password for this action (prefer RACELOG_ADMIN_PASSWORD, --password-file or --password-cmd)")
 
 	//
 	viper.BindPFlag("admin.password", deleteCmd.PersistentFlags().Lookup("admin-password"))
@@ -72,7 +72,7 @@ func init() {
 
 func deleteEvent(eventId int) {
 
-	ac := wamp.NewAdminClient(internal.Url, internal.Realm, internal.AdminPassword)
+	ac := wamp.NewAdminClient(internal.Url, internal.Realm, adminCredentials())
 	defer ac.Close()
 	fmt.Printf("Deleting now event %v\n", eventId)
 	err := ac.DeleteEvent(eventId)
@@ -0,0 +1,115 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"racelogctl/analysis"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	driversFormat string // output format: text|json
+	driversNum    int    // page size for states
+)
+
+// driversCmd represents the event drivers command
+var driversCmd = &cobra.Command{
+	Use:   "drivers <eventId>",
+	Short: "Shows per driver statistics of an event",
+	Long: `Shows per driver statistics of an event.
+
+Each lap is attributed to the driver in the car when the lap was completed.
+The statistics are grouped by class and team (ordered by position). 
+Average and standard deviation don't include laps slower than 1.1 times the
+best lap of the driver (pit stops, incidents). Time in car includes pit stops.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		eventId, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("Invalid event id %v: %v", args[0], err)
+		}
+		eventDrivers(eventId)
+	},
+}
+
+func init() {
+	eventCmd.AddCommand(driversCmd)
+
+	driversCmd.Flags().StringVarP(&driversFormat, "format", "f", "text", "Output format: text|json.")
+	driversCmd.Flags().BoolVarP(&internal.JsonPretty, "pretty", "p", false, "use pretty json format. (Default: false)")
+	driversCmd.Flags().IntVar(&driversNum, "num", 100, "How many states should be fetched in one request")
+}
+
+func eventDrivers(eventId int) {
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	event, err := pc.GetEvent(eventId)
+	if err != nil {
+		log.Fatalf("Error getting event: %v\n", err)
+	}
+	carData, err := pc.GetCarData(eventId)
+	if err != nil {
+		log.Printf("No car data for event %d: %v\n", eventId, err)
+		carData = nil
+	}
+	teams := analysis.DriverStatistics(eventLaps(pc, event, driversNum), carData)
+
+	switch driversFormat {
+	case "json":
+		var jsonData []byte
+		if internal.JsonPretty {
+			jsonData, _ = json.MarshalIndent(teams, "", "  ")
+		} else {
+			jsonData, _ = json.Marshal(teams)
+		}
+		fmt.Printf("%s\n", jsonData)
+	default:
+		printDriverStats(teams)
+	}
+}
+
+func printDriverStats(teams []*analysis.TeamStats) {
+	class := ""
+	for i, t := range teams {
+		if i == 0 || t.CarClass != class {
+			class = t.CarClass
+			fmt.Printf("\n== %s ==\n", class)
+		}
+		fmt.Printf("\nP%d #%s %s\n", t.Pos, t.CarNum, t.Team)
+		fmt.Printf("  %-24s %6s %-8s %5s %6s %10s %10s %10s %7s %9s\n",
+			"Driver", "iR", "License", "Laps", "Stints", "Best", "Avg", "Median", "StdDev", "In car")
+		for _, d := range t.Drivers {
+			fmt.Printf("  %-24s %6d %-8s %5d %6d %10s %10s %10s %7.3f %9s\n",
+				truncate(d.Name, 24), d.IRating, d.License, d.Laps, d.Stints,
+				formatOptionalLapTime(d.BestLap), formatOptionalLapTime(d.AvgLap), formatOptionalLapTime(d.MedianLap),
+				d.StdDev, formatSessionTime(d.TimeInCar))
+		}
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"racelogctl/export"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat  string   // the format used by event export
+	exportOutput  string   // output filename (base name for csv/tsv)
+	exportNum     int      // page size for states and speedmaps
+	timelineFrom  float64  // first session time for csv/tsv export
+	timelineTo    float64  // last session time for csv/tsv export
+	timelineEvery float64  // downsampling interval for csv/tsv export
+	timelineCars  []string // car numbers for csv/tsv export
+)
+
+// exportCmd represents the event export command
+var exportCmd = &cobra.Command{
+	Use:   "export <eventId>",
+	Short: "Exports the archived data of an event for analysis",
+	Long: `Exports the archived data of an event for analysis.
+
+Supported formats:
+  sqlite  one database per event with normalized tables for the event info, 
+          sessions, sectors, car data, car states, session states, race 
+          messages and speedmap chunks.
+  csv     two files <output>-cars.csv and <output>-session.csv with one row per
+          (timestamp, carIdx) resp. timestamp. Headers are taken from the 
+          manifests. Use --every, --from-time, --to-time and --cars to reduce 
+          the amount of data.
+  tsv     like csv but tab separated.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		eventId, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("Invalid event id %v: %v", args[0], err)
+		}
+		exportEvent(eventId)
+	},
+}
+
+func init() {
+	eventCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&exportFormat, "format", "sqlite", "Export format (sqlite, csv, tsv)")
+	exportCmd.Flags().StringVar(&exportOutput, "output", "", "Output filename. (Default: event-<eventId>.<format>, for csv/tsv the base name of the files)")
+	exportCmd.Flags().IntVar(&exportNum, "num", 100, "How many states should be fetched in one request")
+	exportCmd.Flags().Float64Var(&timelineEvery, "every", 0, "csv/tsv: write at most one state every N seconds (0: all states)")
+	exportCmd.Flags().Float64Var(&timelineFrom, "from-time", 0, "csv/tsv: first session time (seconds) to export")
+	exportCmd.Flags().Float64Var(&timelineTo, "to-time", 0, "csv/tsv: last session time (seconds) to export (0: until end)")
+	exportCmd.Flags().StringSliceVar(&timelineCars, "cars", []string{}, "csv/tsv: car numbers to export (Default: all cars)")
+}
+
+func exportEvent(eventId int) {
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	event, err := pc.GetEvent(eventId)
+	if err != nil {
+		log.Fatalf("Error getting event: %v\n", err)
+	}
+	carData, err := pc.GetCarData(eventId)
+	if err != nil {
+		log.Printf("No car data for event %d: %v\n", eventId, err)
+		carData = nil
+	}
+	data := &export.Data{
+		Event:   event,
+		CarData: carData,
+		States: func(emit func(internal.State) error) error {
+			states := pc.StateIterator(eventId, event.Data.ReplayInfo.MinTimestamp, exportNum)
+			defer states.Close()
+			for states.Next() {
+				if err := emit(states.Value()); err != nil {
+					return err
+				}
+			}
+			return states.Err()
+		},
+		Speedmaps: func(emit func(*internal.SpeedmapMessage) error) error {
+			speedmaps := pc.SpeedmapIterator(eventId, event.Data.ReplayInfo.MinTimestamp, exportNum)
+			defer speedmaps.Close()
+			for speedmaps.Next() {
+				if err := emit(speedmaps.Value()); err != nil {
+					return err
+				}
+			}
+			return speedmaps.Err()
+		},
+	}
+
+	output := exportOutput
+	switch exportFormat {
+	case "sqlite":
+		if output == "" {
+			output = fmt.Sprintf("event-%d.sqlite", eventId)
+		}
+		err = export.WriteSQLite(output, data)
+	case "csv", "tsv":
+		if output == "" {
+			output = fmt.Sprintf("event-%d", eventId)
+		}
+		output = strings.TrimSuffix(output, filepath.Ext(output))
+		err = exportTimelines(output, data)
+	default:
+		log.Fatalf("Unknown export format %s", exportFormat)
+	}
+	if err != nil {
+		log.Fatalf("Error exporting event %d: %v\n", eventId, err)
+	}
+	fmt.Printf("Exported event %d to %s\n", eventId, output)
+}
+
+func exportTimelines(base string, data *export.Data) error {
+	opts := export.TimelineOptions{
+		Comma: ',',
+		Every: timelineEvery,
+		From:  timelineFrom,
+		To:    timelineTo,
+		Cars:  timelineCars,
+	}
+	if exportFormat == "tsv" {
+		opts.Comma = '\t'
+	}
+	cars, err := os.Create(fmt.Sprintf("%s-cars.%s", base, exportFormat))
+	if err != nil {
+		return err
+	}
+	defer cars.Close()
+	session, err := os.Create(fmt.Sprintf("%s-session.%s", base, exportFormat))
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	return export.WriteTimelines(cars, session, data, opts)
+}
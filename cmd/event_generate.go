@@ -0,0 +1,106 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"racelogctl/internal"
+
+	"github.com/spf13/cobra"
+)
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generates the states of a synthetic race",
+	Long: `Generates the states of a synthetic race without the need of a server.
+
+The states are written in the same format as 'event states --full' which can be used by 'event import'.
+The event and track info may be written in the format used by 'provider register --sample'.
+
+Example:
+racelogctl event generate --synth-cars 30 --synth-classes 2 --synth-race-length 2h \
+   --output synthetic-states.txt --event-output synthetic-event.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		generateEvent()
+	},
+}
+
+var generateSeed int64 = 1
+var generateEventOutput string = ""
+var generateTrackOutput string = ""
+
+func init() {
+	eventCmd.AddCommand(generateCmd)
+
+	generateCmd.Flags().StringVar(&internal.Output, "output", "-", "Output filename for states. (Default: stdout)")
+	generateCmd.Flags().StringVar(&generateEventOutput, "event-output", "", "Output filename for the event info")
+	generateCmd.Flags().StringVar(&generateTrackOutput, "track-output", "", "Output filename for the track info")
+	generateCmd.Flags().Int64Var(&generateSeed, "seed", generateSeed, "Seed for the synthetic race")
+	addSyntheticFlags(generateCmd.Flags())
+}
+
+func generateEvent() {
+	setupSynthParams()
+	gen := newSyntheticGenerator(generateSeed)
+
+	if generateEventOutput != "" {
+		writeJsonFile(generateEventOutput, gen.Event())
+	}
+	if generateTrackOutput != "" {
+		writeJsonFile(generateTrackOutput, gen.TrackInfo())
+	}
+
+	outFile := os.Stdout
+	if internal.Output != "-" {
+		var err error
+		outFile, err = os.Create(internal.Output)
+		if err != nil {
+			log.Fatalf("Error creating output file %v: %v", internal.Output, err)
+		}
+		defer outFile.Close()
+	}
+	num := 0
+	for {
+		state, _, ok := gen.Next()
+		if !ok {
+			break
+		}
+		jsonData, _ := json.Marshal(state)
+		outFile.WriteString(fmt.Sprintln(string(jsonData)))
+		num++
+	}
+	fmt.Fprintf(os.Stderr, "Generated %d states\n", num)
+}
+
+func writeJsonFile(filename string, data interface{}) {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		log.Fatalf("Error encoding %v: %v", filename, err)
+	}
+	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
+		log.Fatalf("Error writing %v: %v", filename, err)
+	}
+}
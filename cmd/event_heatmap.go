@@ -0,0 +1,429 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"racelogctl/analysis"
+	"racelogctl/chart"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// heatmapWindow holds the flag values describing a speedmap window
+type heatmapWindow struct {
+	class     string
+	from, to  float64
+	tod       string
+	trackTemp string
+}
+
+var (
+	heatmapA       heatmapWindow // the (first) window
+	heatmapB       heatmapWindow // the window compared to heatmapA
+	heatmapCompare bool          // compare two windows
+	heatmapBucket  float64       // seconds per heatmap row
+	heatmapWidth   int           // characters used for ansi output
+	heatmapFormat  string        // output format: html|svg|png|ansi
+	heatmapOutput  string        // output filename
+	heatmapNum     int           // page size for speedmaps
+)
+
+// heatmapCmd represents the event heatmap command
+var heatmapCmd = &cobra.Command{
+	Use:   "heatmap <eventId>",
+	Short: "Renders the speedmap data of an event as heatmap",
+	Long: `Renders the speedmap data of an event as heatmap.
+
+The chunk speeds of a car class are averaged over --bucket seconds and drawn
+as track position (x) by session time (y). Without --class a heatmap for each
+class is created.
+
+Windows can be restricted by session time (--from, --to), time of day 
+(--tod 20:00-06:00) and track temperature (--track-temp 30-40).
+
+With --compare the average speeds of two windows are compared. The second
+window is configured with the *-b flags, unset values are taken from the first
+window. Examples:
+  compare classes:   --compare --class GT3 --class-b GT4
+  day vs night:      --compare --tod 10:00-18:00 --tod-b 22:00-05:00
+
+Output formats: html (default), svg, png (one file per chart) and ansi (terminal).
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		eventId, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("Invalid event id %v: %v", args[0], err)
+		}
+		// unset values of the second window are taken from the first one
+		if !cmd.Flags().Changed("class-b") {
+			heatmapB.class = heatmapA.class
+		}
+		if !cmd.Flags().Changed("tod-b") {
+			heatmapB.tod = heatmapA.tod
+		}
+		if !cmd.Flags().Changed("track-temp-b") {
+			heatmapB.trackTemp = heatmapA.trackTemp
+		}
+		if !cmd.Flags().Changed("from-b") {
+			heatmapB.from = heatmapA.from
+		}
+		if !cmd.Flags().Changed("to-b") {
+			heatmapB.to = heatmapA.to
+		}
+		eventHeatmap(eventId)
+	},
+}
+
+func init() {
+	eventCmd.AddCommand(heatmapCmd)
+
+	heatmapCmd.Flags().StringVarP(&heatmapFormat, "format", "f", "html", "Output format: html|svg|png|ansi")
+	heatmapCmd.Flags().StringVar(&heatmapOutput, "output", "", "Output filename. (Default: event-<eventId>-heatmap.<format>)")
+	heatmapCmd.Flags().IntVar(&heatmapNum, "num", 100, "How many speedmap entries should be fetched in one request")
+	heatmapCmd.Flags().Float64Var(&heatmapBucket, "bucket", 60, "Seconds per heatmap row")
+	heatmapCmd.Flags().IntVar(&heatmapWidth, "width", 100, "Number of characters used for ansi output")
+	heatmapCmd.Flags().BoolVar(&heatmapCompare, "compare", false, "Compare two windows")
+	for _, w := range []struct {
+		suffix string
+		window *heatmapWindow
+	}{{"", &heatmapA}, {"-b", &heatmapB}} {
+		heatmapCmd.Flags().StringVar(&w.window.class, "class"+w.suffix, "", "Car class")
+		heatmapCmd.Flags().Float64Var(&w.window.from, "from"+w.suffix, 0, "First session time (seconds)")
+		heatmapCmd.Flags().Float64Var(&w.window.to, "to"+w.suffix, 0, "Last session time (seconds, 0: until end)")
+		heatmapCmd.Flags().StringVar(&w.window.tod, "tod"+w.suffix, "", "Time of day range (HH:MM-HH:MM)")
+		heatmapCmd.Flags().StringVar(&w.window.trackTemp, "track-temp"+w.suffix, "", "Track temperature range (min-max)")
+	}
+}
+
+// filter converts the flag values to a speedmap filter
+func (w heatmapWindow) filter(class string) (analysis.SpeedmapFilter, error) {
+	f := analysis.SpeedmapFilter{Class: class, From: w.from, To: w.to}
+	if w.tod != "" {
+		from, to, ok := strings.Cut(w.tod, "-")
+		if !ok {
+			return f, fmt.Errorf("invalid time of day range %s", w.tod)
+		}
+		var err error
+		if f.TodFrom, err = parseTimeOfDay(from); err != nil {
+			return f, err
+		}
+		if f.TodTo, err = parseTimeOfDay(to); err != nil {
+			return f, err
+		}
+	}
+	if w.trackTemp != "" {
+		min, max, ok := strings.Cut(w.trackTemp, "-")
+		if !ok {
+			return f, fmt.Errorf("invalid track temperature range %s", w.trackTemp)
+		}
+		var err error
+		if f.MinTrackTemp, err = strconv.ParseFloat(strings.TrimSpace(min), 64); err != nil {
+			return f, err
+		}
+		if f.MaxTrackTemp, err = strconv.ParseFloat(strings.TrimSpace(max), 64); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+func (w heatmapWindow) String() string {
+	parts := []string{}
+	if w.class != "" {
+		parts = append(parts, w.class)
+	}
+	if w.from != 0 || w.to != 0 {
+		parts = append(parts, fmt.Sprintf("session %s-%s", formatSessionTime(w.from), formatSessionTime(w.to)))
+	}
+	if w.tod != "" {
+		parts = append(parts, "tod "+w.tod)
+	}
+	if w.trackTemp != "" {
+		parts = append(parts, "track "+w.trackTemp+"°C")
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseTimeOfDay parses HH:MM into seconds since midnight
+func parseTimeOfDay(s string) (float64, error) {
+	h, m, _ := strings.Cut(strings.TrimSpace(s), ":")
+	hours, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %s", s)
+	}
+	minutes := 0
+	if m != "" {
+		if minutes, err = strconv.Atoi(m); err != nil {
+			return 0, fmt.Errorf("invalid time of day %s", s)
+		}
+	}
+	return float64(hours*3600 + minutes*60), nil
+}
+
+func eventHeatmap(eventId int) {
+	switch heatmapFormat {
+	case "html", "svg", "png", "ansi":
+	default:
+		log.Fatalf("Unknown output format %s", heatmapFormat)
+	}
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	event, err := pc.GetEvent(eventId)
+	if err != nil {
+		log.Fatalf("Error getting event: %v\n", err)
+	}
+	speedmaps := []*internal.SpeedmapMessage{}
+	it := pc.SpeedmapIterator(eventId, event.Data.ReplayInfo.MinTimestamp, heatmapNum)
+	defer it.Close()
+	for it.Next() {
+		speedmaps = append(speedmaps, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		log.Fatalf("Error fetching speedmaps: %v\n", err)
+	}
+	classes := analysis.SpeedmapClasses(speedmaps)
+	if len(classes) == 0 {
+		log.Fatalf("No speedmap data for event %d\n", eventId)
+	}
+
+	output := heatmapOutput
+	if output == "" {
+		output = fmt.Sprintf("event-%d-heatmap.%s", eventId, heatmapFormat)
+	}
+	title := fmt.Sprintf("%s - %s", event.Name, event.Data.Info.TrackDisplayName)
+	if heatmapCompare {
+		err = compareHeatmaps(speedmaps, classes, title, output)
+	} else {
+		err = renderHeatmaps(speedmaps, classes, title, output)
+	}
+	if err != nil {
+		log.Fatalf("Error rendering heatmap: %v\n", err)
+	}
+}
+
+func aggregate(speedmaps []*internal.SpeedmapMessage, w heatmapWindow, class string, bucket float64) (analysis.SpeedHeatmap, error) {
+	filter, err := w.filter(class)
+	if err != nil {
+		return analysis.SpeedHeatmap{}, err
+	}
+	agg := analysis.NewSpeedmapAggregator(filter, bucket)
+	for _, s := range speedmaps {
+		agg.Add(s)
+	}
+	return agg.Heatmap(), nil
+}
+
+// defaultClass returns the class of the window or the first class of the event
+func defaultClass(w heatmapWindow, classes []string) string {
+	if w.class != "" {
+		return w.class
+	}
+	return classes[0]
+}
+
+func trackPosFormat(trackLength float64) func(float64) string {
+	return func(f float64) string { return fmt.Sprintf("%.0fm", f*trackLength) }
+}
+
+func renderHeatmaps(speedmaps []*internal.SpeedmapMessage, classes []string, title, output string) error {
+	if heatmapA.class != "" {
+		classes = []string{heatmapA.class}
+	}
+	heatmaps := []*chart.Heatmap{}
+	for _, class := range classes {
+		data, err := aggregate(speedmaps, heatmapA, class, heatmapBucket)
+		if err != nil {
+			return err
+		}
+		if len(data.Speeds) == 0 {
+			log.Printf("No speedmap data for class %s in window\n", class)
+			continue
+		}
+		h := &chart.Heatmap{
+			Title:   fmt.Sprintf("Speed (km/h) - %s", class),
+			XLabel:  "Track position",
+			YLabel:  "Session time",
+			Rows:    data.Speeds,
+			XFormat: trackPosFormat(data.TrackLength),
+		}
+		for _, t := range data.Times {
+			h.RowLabels = append(h.RowLabels, formatSessionTime(t))
+		}
+		heatmaps = append(heatmaps, h)
+	}
+	return writeHeatmaps(title, output, heatmaps, nil)
+}
+
+func compareHeatmaps(speedmaps []*internal.SpeedmapMessage, classes []string, title, output string) error {
+	a, err := aggregate(speedmaps, heatmapA, defaultClass(heatmapA, classes), 0)
+	if err != nil {
+		return err
+	}
+	b, err := aggregate(speedmaps, heatmapB, defaultClass(heatmapB, classes), 0)
+	if err != nil {
+		return err
+	}
+	if len(a.Speeds) == 0 || len(b.Speeds) == 0 {
+		return fmt.Errorf("no speedmap data in window (A: %d, B: %d entries)", len(a.Speeds), len(b.Speeds))
+	}
+	profileA, profileB := a.Profile(), b.Profile()
+	diff, err := analysis.SpeedDiff(profileA, profileB)
+	if err != nil {
+		return err
+	}
+	nameA := fmt.Sprintf("A: %s", heatmapA.withClass(a.Class))
+	nameB := fmt.Sprintf("B: %s", heatmapB.withClass(b.Class))
+	pos := func(i int) float64 { return 100 * float64(i) / float64(len(diff)) }
+	profiles := &chart.Chart{
+		Title:  "Average speed by track position",
+		XLabel: "Track position (%)",
+		YLabel: "Speed (km/h)",
+	}
+	for _, p := range []struct {
+		name   string
+		values []float64
+	}{{nameA, profileA}, {nameB, profileB}} {
+		s := chart.Series{Name: p.name}
+		for i, v := range p.values {
+			if v > 0 {
+				s.Points = append(s.Points, chart.Point{X: pos(i), Y: v})
+			}
+		}
+		profiles.Series = append(profiles.Series, s)
+	}
+	heatmaps := []*chart.Heatmap{
+		{
+			Title:     "Speed (km/h)",
+			XLabel:    "Track position",
+			Rows:      [][]float64{profileA, profileB},
+			RowLabels: []string{"A", "B"},
+			XFormat:   trackPosFormat(a.TrackLength),
+			Height:    200,
+		},
+		{
+			Title:     "Speed difference B-A (km/h)",
+			XLabel:    "Track position",
+			Rows:      [][]float64{diff},
+			RowLabels: []string{"B-A"},
+			XFormat:   trackPosFormat(a.TrackLength),
+			Diverging: true,
+			Height:    160,
+		},
+	}
+	if heatmapFormat == "ansi" {
+		fmt.Printf("%s\n%s\n", nameA, nameB)
+		printSpeedDifferences(diff, a.TrackLength)
+	}
+	return writeHeatmaps(fmt.Sprintf("%s (%s vs %s)", title, nameA, nameB), output, heatmaps, []*chart.Chart{profiles})
+}
+
+func (w heatmapWindow) withClass(class string) string {
+	w.class = class
+	return w.String()
+}
+
+// printSpeedDifferences prints the track sections with the largest differences
+func printSpeedDifferences(diff []float64, trackLength float64) {
+	idx := make([]int, len(diff))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return math.Abs(diff[idx[i]]) > math.Abs(diff[idx[j]]) })
+	fmt.Printf("Largest differences:\n")
+	for _, i := range idx[:int(math.Min(5, float64(len(idx))))] {
+		fmt.Printf("  %6.0fm  %+6.1f km/h\n", trackLength*float64(i)/float64(len(diff)), diff[i])
+	}
+}
+
+func writeHeatmaps(title, output string, heatmaps []*chart.Heatmap, charts []*chart.Chart) error {
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+	name := func(i int, ext string) string {
+		if len(heatmaps)+len(charts) == 1 {
+			return fmt.Sprintf("%s.%s", base, ext)
+		}
+		return fmt.Sprintf("%s-%d.%s", base, i+1, ext)
+	}
+	created := func(filename string, err error) error {
+		if err == nil {
+			fmt.Printf("Created %s\n", filename)
+		}
+		return err
+	}
+	switch heatmapFormat {
+	case "ansi":
+		for _, h := range heatmaps {
+			if err := h.WriteANSI(os.Stdout, heatmapWidth); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "png":
+		for i, h := range heatmaps {
+			filename := name(i, "png")
+			if err := created(filename, writeFile(filename, func(f *os.File) error { return h.WritePNG(f, 4) })); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "svg":
+		for i, c := range charts {
+			filename := name(i, "svg")
+			if err := created(filename, writeFile(filename, func(f *os.File) error { return c.WriteSVG(f) })); err != nil {
+				return err
+			}
+		}
+		for i, h := range heatmaps {
+			filename := name(len(charts)+i, "svg")
+			if err := created(filename, writeFile(filename, func(f *os.File) error { return h.WriteSVG(f) })); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "html":
+		return created(output, writeFile(output, func(f *os.File) error {
+			items := []chart.Drawable{}
+			for _, c := range charts {
+				items = append(items, c)
+			}
+			for _, h := range heatmaps {
+				items = append(items, h)
+			}
+			return chart.WriteHTML(f, title, items)
+		}))
+	default:
+		return fmt.Errorf("unknown output format %s", heatmapFormat)
+	}
+}
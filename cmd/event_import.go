@@ -57,7 +57,7 @@ func init() {
 	importCmd.MarkFlagRequired("input")
 	importCmd.Flags().StringVarP(&internal.EventKey, "eventKey", "k", "", "Key of the event recieving the data")
 	importCmd.MarkFlagRequired("eventKey")
-	importCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action")
+	importCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action (prefer RACELOG_DATAPROVIDER_PASSWORD, --password-file or --password-cmd)")
 }
 
 func importData() {
@@ -71,9 +71,9 @@ func importData() {
 	// optionally, resize scanner's capacity for lines over 64K, see next example
 	sender := make(chan internal.State)
 
-	dataprovider := wamp.NewDataProviderClient(internal.Url, internal.Realm, internal.DataproviderPassword)
+	dataprovider := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
 	defer dataprovider.Close()
-	dataprovider.PublishStateFromChannel(internal.EventKey, sender)
+	senderDone := dataprovider.PublishStateFromChannel(internal.EventKey, sender)
 
 	idx := 0
 	for scanner.Scan() {
@@ -85,6 +85,9 @@ func importData() {
 		// fmt.Printf("%v\n", s.Payload.Session)
 	}
 	close(sender)
+	if err := <-senderDone; err != nil {
+		log.Fatalf("Error publishing states: %v", err)
+	}
 
 	if err := scanner.Err(); err != nil {
 		log.Fatal(err)
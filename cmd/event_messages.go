@@ -0,0 +1,130 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"racelogctl/analysis"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	messageFilter  analysis.MessageFilter // filter for event messages
+	messagesFormat string                 // output format: text|json|csv
+	messagesNum    int                    // page size for states
+)
+
+// messagesCmd represents the event messages command
+var messagesCmd = &cobra.Command{
+	Use:   "messages <eventId>",
+	Short: "Lists the race messages of an event",
+	Long: `Lists the race messages of an event.
+
+Messages can be filtered by type, sub type, car number, car class and text.
+Multiple values for a filter can be given as comma separated list.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		eventId, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("Invalid event id %v: %v", args[0], err)
+		}
+		eventMessages(eventId)
+	},
+}
+
+func init() {
+	eventCmd.AddCommand(messagesCmd)
+
+	messagesCmd.Flags().StringVarP(&messagesFormat, "format", "f", "text", "Output format: text|json|csv.")
+	messagesCmd.Flags().BoolVarP(&internal.JsonPretty, "pretty", "p", false, "use pretty json format. (Default: false)")
+	messagesCmd.Flags().IntVar(&messagesNum, "num", 100, "How many states should be fetched in one request")
+	messagesCmd.Flags().StringSliceVar(&messageFilter.Types, "type", []string{}, "only messages of these types")
+	messagesCmd.Flags().StringSliceVar(&messageFilter.SubTypes, "subtype", []string{}, "only messages of these sub types")
+	messagesCmd.Flags().StringSliceVar(&messageFilter.Cars, "car", []string{}, "only messages of these car numbers")
+	messagesCmd.Flags().StringSliceVar(&messageFilter.Classes, "class", []string{}, "only messages of these car classes")
+	messagesCmd.Flags().StringVar(&messageFilter.Text, "text", "", "only messages containing this text")
+}
+
+func eventMessages(eventId int) {
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	event, err := pc.GetEvent(eventId)
+	if err != nil {
+		log.Fatalf("Error getting event: %v\n", err)
+	}
+	messages := []analysis.RaceMessage{}
+	states := pc.StateIterator(eventId, event.Data.ReplayInfo.MinTimestamp, messagesNum)
+	defer states.Close()
+	for states.Next() {
+		for _, m := range analysis.Messages(event.Data.Manifests, states.Value()) {
+			if messageFilter.Match(m) {
+				messages = append(messages, m)
+			}
+		}
+	}
+	if err := states.Err(); err != nil {
+		log.Fatalf("Error fetching states: %v\n", err)
+	}
+
+	switch messagesFormat {
+	case "json":
+		var jsonData []byte
+		if internal.JsonPretty {
+			jsonData, _ = json.MarshalIndent(messages, "", "  ")
+		} else {
+			jsonData, _ = json.Marshal(messages)
+		}
+		fmt.Printf("%s\n", jsonData)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"timestamp", "sessionTime", "type", "subType", "carIdx", "carNum", "carClass", "msg"})
+		for _, m := range messages {
+			w.Write([]string{
+				strconv.FormatFloat(m.Timestamp, 'f', 3, 64),
+				strconv.FormatFloat(m.SessionTime, 'f', 3, 64),
+				m.Type, m.SubType, strconv.Itoa(m.CarIdx), m.CarNum, m.CarClass, m.Msg,
+			})
+		}
+		w.Flush()
+	default:
+		for _, m := range messages {
+			fmt.Printf("%10s  %-8s %-12s #%-4s %-8s %s\n", formatSessionTime(m.SessionTime),
+				m.Type, m.SubType, m.CarNum, m.CarClass, m.Msg)
+		}
+	}
+}
+
+// formatSessionTime formats seconds as h:mm:ss
+func formatSessionTime(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
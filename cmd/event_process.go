@@ -62,12 +62,12 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// processCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	processCmd.Flags().StringVarP(&internal.AdminPassword, "admin-password", "p", "", "sets the admin password for this action")
+	processCmd.Flags().StringVarP(&internal.AdminPassword, "admin-password", "p", "", "sets the admin password for this action (prefer RACELOG_ADMIN_PASSWORD, --password-file or --password-cmd)")
 }
 
 func processEvent(eventId int) {
 
-	ac := wamp.NewAdminClient(internal.Url, internal.Realm, internal.AdminPassword)
+	ac := wamp.NewAdminClient(internal.Url, internal.Realm, adminCredentials())
 	defer ac.Close()
 
 	fmt.Printf("Processing now event %v\n", eventId)
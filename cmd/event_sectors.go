@@ -0,0 +1,180 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"racelogctl/analysis"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	sectorsFormat string // output format: text|json
+	sectorsNum    int    // page size for states
+)
+
+// sectorsCmd represents the event sectors command
+var sectorsCmd = &cobra.Command{
+	Use:   "sectors <eventId>",
+	Short: "Analyzes the sector times of an event",
+	Long: `Analyzes the sector times of an event.
+
+For each car class the best sectors and the theoretical best lap are shown.
+For each car the best sectors (with the loss to the class best) and the 
+consistency (standard deviation) per sector are listed. Below each car the 
+best sectors of its drivers are shown together with the sector where the 
+driver loses the most time compared to the class best. Laps are attributed 
+to the driver in the car when the lap was completed.
+
+Sector times slower than 1.5 times the best sector of the car (pit stops, 
+incidents) are not used for the consistency.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		eventId, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("Invalid event id %v: %v", args[0], err)
+		}
+		eventSectors(eventId)
+	},
+}
+
+func init() {
+	eventCmd.AddCommand(sectorsCmd)
+
+	sectorsCmd.Flags().StringVarP(&sectorsFormat, "format", "f", "text", "Output format: text|json.")
+	sectorsCmd.Flags().BoolVarP(&internal.JsonPretty, "pretty", "p", false, "use pretty json format. (Default: false)")
+	sectorsCmd.Flags().IntVar(&sectorsNum, "num", 100, "How many states should be fetched in one request")
+}
+
+func eventSectors(eventId int) {
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	event, err := pc.GetEvent(eventId)
+	if err != nil {
+		log.Fatalf("Error getting event: %v\n", err)
+	}
+	classes := analysis.Sectors(eventLaps(pc, event, sectorsNum))
+
+	switch sectorsFormat {
+	case "json":
+		var jsonData []byte
+		if internal.JsonPretty {
+			jsonData, _ = json.MarshalIndent(classes, "", "  ")
+		} else {
+			jsonData, _ = json.Marshal(classes)
+		}
+		fmt.Printf("%s\n", jsonData)
+	default:
+		printSectors(event.Data.Info.Sectors, classes)
+	}
+}
+
+func printSectors(sectors []internal.Sector, classes []*analysis.ClassSectors) {
+	for _, c := range classes {
+		if len(c.Best) == 0 {
+			fmt.Printf("%s: no sector times recorded\n\n", c.Class)
+			continue
+		}
+		fmt.Printf("%s - theoretical best %s\n", c.Class, formatLapTime(c.TheoreticalBest))
+		for i, b := range c.Best {
+			boundary := ""
+			if i < len(sectors) {
+				end := 1.0
+				if i+1 < len(sectors) {
+					end = sectors[i+1].SectorStartPct
+				}
+				boundary = fmt.Sprintf(" (%.0f%%-%.0f%%)", sectors[i].SectorStartPct*100, end*100)
+			}
+			fmt.Printf("  S%d%s: %s #%s\n", i+1, boundary, formatSectorTime(b), c.BestCar[i])
+		}
+		fmt.Printf("\n%-5s %-24s %4s %10s %10s", "Car", "Driver", "Laps", "Best", "Theo")
+		for i := range c.Best {
+			fmt.Printf(" %-19s", fmt.Sprintf("S%d (+loss) ±sd", i+1))
+		}
+		fmt.Printf(" %s\n", "Loses most")
+		for _, car := range c.Cars {
+			names := make([]string, len(car.Drivers))
+			for i, d := range car.Drivers {
+				names[i] = d.Name
+			}
+			fmt.Printf("%-5s %-24s %4d %10s %10s", "#"+car.CarNum, truncate(strings.Join(names, ","), 24), car.Laps,
+				formatOptionalLapTime(car.BestLap), formatOptionalLapTime(car.TheoreticalBest))
+			for i := range c.Best {
+				if car.Best[i] <= 0 {
+					fmt.Printf(" %-19s", "-")
+					continue
+				}
+				fmt.Printf(" %-19s", fmt.Sprintf("%s (+%.3f) ±%.3f", formatSectorTime(car.Best[i]), car.Loss[i], car.StdDev[i]))
+			}
+			fmt.Println()
+			for _, d := range car.Drivers {
+				fmt.Printf("%-5s %-24s %4d %10s %10s", "", truncate(d.Name, 24), d.Laps, "", "")
+				for i := range c.Best {
+					if d.Best[i] <= 0 {
+						fmt.Printf(" %-19s", "-")
+						continue
+					}
+					fmt.Printf(" %-19s", fmt.Sprintf("%s (+%.3f)", formatSectorTime(d.Best[i]), d.Loss[i]))
+				}
+				if worst := worstSector(d.Best, d.Loss); worst >= 0 {
+					fmt.Printf(" S%d +%.3f", worst+1, d.Loss[worst])
+				}
+				fmt.Println()
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// formatSectorTime formats seconds as ss.sss or m:ss.sss
+func formatSectorTime(seconds float64) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%.3f", seconds)
+	}
+	return formatLapTime(seconds)
+}
+
+// worstSector returns the sector with the highest loss to the class best, -1 if there is none
+func worstSector(best, loss []float64) int {
+	worst := -1
+	for i := range loss {
+		if best[i] > 0 && loss[i] > 0 && (worst < 0 || loss[i] > loss[worst]) {
+			worst = i
+		}
+	}
+	return worst
+}
+
+func truncate(s string, n int) string {
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
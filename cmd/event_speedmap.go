@@ -98,7 +98,6 @@ func fetchSpeedmapRangeEntries(eventId int, outFile *os.File) {
 }
 
 func fetchSpeedmapFull(event *internal.Event, outFile *os.File) {
-	// var lastTimestamp float64 = 0
 	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
 	defer pc.Close()
 
@@ -106,15 +105,12 @@ func fetchSpeedmapFull(event *internal.Event, outFile *os.File) {
 	if internal.From != 0 {
 		from = float64(internal.From)
 	}
-	for goon := true; goon; {
-		fmt.Printf("Fetching %d speedmaps beginning at %v\n", internal.Num, from)
-		speedmaps, _ := pc.GetSpeedmaps(int(event.Id), from, internal.Num)
-		goon = len(speedmaps) == internal.Num
-		for _, entry := range speedmaps {
-			jsonData, _ := json.Marshal(entry)
-			outFile.WriteString(fmt.Sprintln(string(jsonData)))
-		}
-		from = speedmaps[len(speedmaps)-1].Timestamp + 0.0001
+	speedmaps := pc.SpeedmapIterator(int(event.Id), from, internal.Num)
+	for speedmaps.Next() {
+		jsonData, _ := json.Marshal(speedmaps.Value())
+		outFile.WriteString(fmt.Sprintln(string(jsonData)))
+	}
+	if speedmaps.Err() != nil {
+		log.Fatalf("Error fetching speedmaps: %v\n", speedmaps.Err())
 	}
-
 }
@@ -75,6 +75,7 @@ func init() {
 	stateCmd.Flags().IntVar(&internal.From, "from", 0, "Fetch states beginning from timestamp (Default: 0=first available entry)")
 	stateCmd.Flags().IntVar(&internal.Num, "num", 10, "How many states should be fetches in one request")
 	stateCmd.Flags().BoolVar(&internal.FullStateData, "full", false, "retrieves all data for this event")
+	stateCmd.Flags().IntVar(&internal.Parallel, "parallel", 1, "Number of parallel sessions used with --full")
 	stateCmd.Flags().StringVar(&internal.Output, "output", "-", "Output filename. (Default: stdout)")
 
 	// Cobra supports local flags which will only run when this command
@@ -105,22 +106,29 @@ func fetchStates(eventId int, outFile *os.File) {
 }
 
 func fetchFullData(event *internal.Event, outFile *os.File) {
-	// var lastTimestamp float64 = 0
-	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
-	defer pc.Close()
 	from := event.Data.ReplayInfo.MinTimestamp
 	if internal.From != 0 {
 		from = float64(internal.From)
 	}
-	for goon := true; goon; {
-		fmt.Printf("Fetching %d states beginning at %v\n", internal.Num, from)
-		states := pc.GetStates(int(event.Id), from, internal.Num)
-		goon = len(states) == internal.Num
-		for _, entry := range states {
-			jsonData, _ := json.Marshal(entry)
-			outFile.WriteString(fmt.Sprintln(string(jsonData)))
+	if internal.Parallel > 1 {
+		err := wamp.FetchStatesParallel(internal.Url, internal.Realm, event, from, internal.Num, internal.Parallel, func(s internal.State) error {
+			jsonData, _ := json.Marshal(s)
+			_, err := outFile.WriteString(fmt.Sprintln(string(jsonData)))
+			return err
+		})
+		if err != nil {
+			log.Fatalf("Error fetching states: %v\n", err)
 		}
-		from = states[len(states)-1].Timestamp + 0.0001
+		return
+	}
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	states := pc.StateIterator(int(event.Id), from, internal.Num)
+	for states.Next() {
+		jsonData, _ := json.Marshal(states.Value())
+		outFile.WriteString(fmt.Sprintln(string(jsonData)))
+	}
+	if states.Err() != nil {
+		log.Fatalf("Error fetching states: %v\n", states.Err())
 	}
-
 }
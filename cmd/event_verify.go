@@ -0,0 +1,177 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"racelogctl/internal"
+	"racelogctl/verify"
+	"racelogctl/wamp"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyOpts = verify.DefaultOptions() // options for event verify
+	verifyAll  bool                      // verify all events of the server
+	verifyNum  int                       // page size for states and speedmaps
+)
+
+// verifyCmd represents the event verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify <eventId>...",
+	Short: "Checks the archived data of events for inconsistencies",
+	Long: `Checks the archived data of events for inconsistencies.
+
+All states and speedmaps are downloaded and checked for
+  - timestamps not increasing, duplicate timestamps and gaps (--max-gap)
+  - delta states referencing rows/cols outside of the manifest
+  - car and session rows not matching the manifests
+  - session time going backwards within a session
+  - ReplayInfo min/max not matching the data
+  - missing car data and speedmaps for racelogger >= 0.4.4
+
+Events which cannot be fetched are reported with an unreachable error, 
+the remaining events are still verified.
+
+Delta checks require a connection to the server. In offline mode only the 
+reconstructed states of the cache are checked.
+Use --format json for a machine readable report. The command exits with 
+status 1 if errors were found.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := []int{}
+		for _, arg := range args {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				log.Fatalf("Invalid event id %v: %v", arg, err)
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 && !verifyAll {
+			log.Fatalf("requires an event id or --all")
+		}
+		verifyEvents(ids)
+	},
+}
+
+func init() {
+	eventCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().StringVarP(&internal.OutputFormat, "format", "f", "text", "Output format: text|json.")
+	verifyCmd.Flags().BoolVarP(&internal.JsonPretty, "pretty", "p", false, "use pretty json format. (Default: false)")
+	verifyCmd.Flags().IntVar(&verifyNum, "num", 100, "How many states should be fetched in one request")
+	verifyCmd.Flags().BoolVar(&verifyAll, "all", false, "verify all events of the server")
+	verifyCmd.Flags().Float64Var(&verifyOpts.MaxGap, "max-gap", verifyOpts.MaxGap, "report gaps between states longer than this (seconds)")
+	verifyCmd.Flags().IntVar(&verifyOpts.MaxFindings, "max-findings", verifyOpts.MaxFindings, "maximum number of findings per check and event (0: unlimited)")
+}
+
+func verifyEvents(ids []int) {
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	if verifyAll {
+		events, err := pc.GetEventList()
+		if err != nil {
+			log.Fatalf("Error getting events: %v\n", err)
+		}
+		for _, e := range events {
+			ids = append(ids, int(e.Id))
+		}
+	}
+	reports := []*verify.Report{}
+	errors := 0
+	for _, id := range ids {
+		r := verifyEvent(pc, id)
+		errors += r.Errors()
+		reports = append(reports, r)
+		if internal.OutputFormat != "json" {
+			printVerifyReport(r)
+		}
+	}
+	if internal.OutputFormat == "json" {
+		var jsonData []byte
+		if internal.JsonPretty {
+			jsonData, _ = json.MarshalIndent(reports, "", "  ")
+		} else {
+			jsonData, _ = json.Marshal(reports)
+		}
+		fmt.Printf("%s\n", jsonData)
+	}
+	if errors > 0 {
+		os.Exit(1)
+	}
+}
+
+func verifyEvent(pc *wamp.PublicClient, id int) *verify.Report {
+	event, err := pc.GetEvent(id)
+	if err != nil {
+		return verify.Unreachable(id, err)
+	}
+	v := verify.New(event, verifyOpts)
+	start := event.Data.ReplayInfo.MinTimestamp
+	var states *wamp.Iterator[internal.State]
+	add := v.AddRawState
+	if pc.Client() != nil {
+		states = pc.RawStateIterator(id, start, verifyNum)
+	} else {
+		states = pc.StateIterator(id, start, verifyNum)
+		add = v.AddState
+	}
+	defer states.Close()
+	for states.Next() {
+		add(states.Value())
+	}
+	if err := states.Err(); err != nil {
+		v.FetchFailed("states", err)
+		return v.Report()
+	}
+	speedmaps := pc.SpeedmapIterator(id, start, verifyNum)
+	defer speedmaps.Close()
+	for speedmaps.Next() {
+		v.AddSpeedmap(speedmaps.Value())
+	}
+	if err := speedmaps.Err(); err != nil {
+		v.FetchFailed("speedmaps", err)
+	}
+	v.SetCarData(pc.GetCarData(id))
+	return v.Report()
+}
+
+func printVerifyReport(r *verify.Report) {
+	status := "OK"
+	if r.Errors() > 0 {
+		status = "ERRORS"
+	} else if len(r.Findings) > 0 {
+		status = "WARNINGS"
+	}
+	fmt.Printf("Event %d %s: %s (%d states, %d speedmaps)\n", r.EventId, r.Name, status, r.States, r.Speedmaps)
+	for _, check := range sortedKeys(r.Counts) {
+		fmt.Printf("  %-20s %d\n", check, r.Counts[check])
+	}
+	for _, f := range r.Findings {
+		fmt.Printf("  %-7s %-20s %14.3f %s\n", f.Severity, f.Check, f.Timestamp, f.Message)
+	}
+}
@@ -58,7 +58,7 @@ func init() {
 	registerCmd.Flags().StringVarP(&internal.EventName, "name", "n", "", "Event name for registration (default: Sample-YYYY-MM-DD-HH-MM)")
 	registerCmd.Flags().StringVarP(&internal.EventKey, "key", "k", "", "Event key for registration")
 	registerCmd.Flags().StringVarP(&internal.EventDescription, "description", "d", "", "Event description")
-	registerCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action")
+	registerCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action (prefer RACELOG_DATAPROVIDER_PASSWORD, --password-file or --password-cmd)")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -87,7 +87,7 @@ func register() {
 	if len(internal.EventName) > 0 {
 		registerMsg.Info.Name = internal.EventName
 	}
-	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, internal.DataproviderPassword)
+	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
 	err := dpc.RegisterProvider(registerMsg)
 	if err != nil {
 		log.Fatalf("Error registering event: %v", err)
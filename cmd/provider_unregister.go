@@ -60,10 +60,10 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// unregisterCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	unregisterCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action")
+	unregisterCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action (prefer RACELOG_DATAPROVIDER_PASSWORD, --password-file or --password-cmd)")
 }
 
 func unregisterEventProvider(eventKey string) {
-	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, internal.DataproviderPassword)
+	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
 	dpc.UnregisterProvider(eventKey)
 }
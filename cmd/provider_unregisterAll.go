@@ -23,11 +23,11 @@ var unregisterAllCmd = &cobra.Command{
 
 func init() {
 	providerCmd.AddCommand(unregisterAllCmd)
-	unregisterAllCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action")
+	unregisterAllCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action (prefer RACELOG_DATAPROVIDER_PASSWORD, --password-file or --password-cmd)")
 }
 
 func unregisterAll() {
-	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, internal.DataproviderPassword)
+	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
 	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
 	providers, err := pc.ProviderList()
 	if err != nil {
@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"racelogctl/internal"
 	"strings"
@@ -42,7 +43,7 @@ func Execute() {
 
 func init() {
 	// println("root.init")
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, setupConnection, setupCache)
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
@@ -50,7 +51,7 @@ func init() {
 	// rootCmd.SetVersionTemplate(fmt.Sprintf("Version %s", Version))
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.racelogctl.yaml)")
 	rootCmd.PersistentFlags().StringVar(&internal.Realm, "realm", "racelog", "racelog realm to use")
-	rootCmd.PersistentFlags().StringVar(&internal.Url, "url", "wss://crossbar.iracing-tools.de/ws", "the websocket URL of the racelog WAMP server")
+	rootCmd.PersistentFlags().StringVar(&internal.Url, "url", "wss://crossbar.iracing-tools.de/ws", "the URL of the racelog WAMP server (ws://, wss://, tcp://, tcps://, unix://, ws+unix://<socket>:<path>)")
 
 }
 
@@ -78,10 +79,13 @@ func initConfig() {
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		applyProfile()
 		bindFlags(rootCmd, viper.GetViper())
 
 		// bindFlags(deleteCmd, viper.GetViper())
 
+	} else if internal.Profile != "" {
+		log.Fatalf("Profile %s requires a config file: %v\n", internal.Profile, err)
 	}
 
 }
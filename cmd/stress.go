@@ -23,12 +23,14 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"strings"
 
 	"racelogctl/internal"
 	"racelogctl/wamp"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/mod/semver"
 )
 
@@ -36,7 +38,29 @@ import (
 var stressCmd = &cobra.Command{
 	Use:   "stress",
 	Short: "Commands for stress testing the app. Used for development ONLY",
-	Long:  "",
+	Long: `Commands for stress testing the app. Used for development ONLY
+
+Each stress mode collects request latencies, throughput and errors and writes 
+a report as stress-<mode>-<timestamp>.json and .html to the report directory.
+Producers publish states with their original timestamps and remember the send 
+time of each state, so listeners of the same run are able to measure the 
+publish-to-receive latency. States not published by this run are only counted 
+(live.state.unmatched).
+
+Random decisions are derived from --seed. The seed and the job plan (picked 
+events, listen durations) are stored in the report, so a run can be repeated 
+with --seed or --replay.
+
+On Ctrl-C (SIGINT/SIGTERM) the run is stopped gracefully: pending data is 
+published, all providers registered by the run are unregistered and the 
+partial report is written.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// stress tests measure the server, so cached data must not be used
+		if offlineMode {
+			log.Fatalf("stress commands are not available in offline mode")
+		}
+		wamp.UseCache(nil, false)
+	},
 }
 
 func init() {
@@ -52,10 +76,28 @@ func init() {
 	// is called directly, e.g.:
 	stressCmd.PersistentFlags().IntVarP(&internal.Worker, "worker", "w", 1, "Number of workers to use")
 	stressCmd.PersistentFlags().StringVar(&internal.RaceloggerVersion, "racelogger-version", "v0.6.0", "Minimum version of racelogger to be used for stress tests")
+	stressCmd.PersistentFlags().StringVar(&reportDir, "report-dir", reportDir, "Directory for the stress reports")
+	stressCmd.PersistentFlags().StringVar(&reportInterval, "report-interval", reportInterval, "Interval for throughput aggregation in stress reports")
+	stressCmd.PersistentFlags().Int64Var(&stressSeed, "seed", stressSeed, "Seed for the random decisions (event picks, durations). 0 means: use the current time")
+	stressCmd.PersistentFlags().StringVar(&replayFile, "replay", replayFile, "Replay the job plan of a previous stress report (uses its seed unless --seed is given)")
+	addSyntheticFlags(stressCmd.PersistentFlags())
 }
 
 // helper functions here
 
+// addSyntheticFlags adds the parameters for synthetic races to a flag set
+func addSyntheticFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&synthParams.Cars, "synth-cars", synthParams.Cars, "Number of cars in a synthetic race")
+	flags.IntVar(&synthParams.Classes, "synth-classes", synthParams.Classes, "Number of car classes in a synthetic race")
+	flags.Float64Var(&synthParams.TrackLength, "synth-track-length", synthParams.TrackLength, "Track length (in meters) of a synthetic race")
+	flags.IntVar(&synthParams.Sectors, "synth-sectors", synthParams.Sectors, "Number of sectors of a synthetic race")
+	flags.Float64Var(&synthParams.LapTime, "synth-laptime", synthParams.LapTime, "Mean lap time (in seconds) of the fastest class in a synthetic race")
+	flags.Float64Var(&synthParams.LapTimeStdDev, "synth-laptime-stddev", synthParams.LapTimeStdDev, "Standard deviation (in seconds) of lap times in a synthetic race")
+	flags.IntVar(&synthParams.PitStops, "synth-pitstops", synthParams.PitStops, "Number of pit stops per car in a synthetic race")
+	flags.Float64Var(&synthParams.PitDuration, "synth-pit-duration", synthParams.PitDuration, "Duration (in seconds) of a pit stop in a synthetic race")
+	flags.StringVar(&synthRaceLengthArg, "synth-race-length", synthRaceLengthArg, "Duration of a synthetic race")
+}
+
 func raceLoggerVersion(e *internal.Event) bool {
 	minVersion := internal.RaceloggerVersion
 	if !strings.HasPrefix(minVersion, "v") {
@@ -22,15 +22,13 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
-	"math"
 	"math/rand"
-	"os"
 	"racelogctl/internal"
+	"racelogctl/report"
 	"racelogctl/wamp"
-	"sort"
 	"sync"
 	"time"
 
@@ -47,13 +45,6 @@ var browserCmd = &cobra.Command{
 	},
 }
 
-type durationStats struct {
-	Min time.Duration `json:"min"`
-	Max time.Duration `json:"max"`
-	Avg time.Duration `json:"avg"`
-	Sum time.Duration `json:"sum"`
-}
-
 type jobData struct {
 	id    int
 	event *internal.Event
@@ -67,28 +58,6 @@ type jobResult struct {
 	numStates  int
 }
 
-type summary struct {
-	Id            int             `json:"id"`
-	Num           int             `json:"num"`
-	NumStates     int             `json:"numStates"`
-	NumFetches    int             `json:"numFetches"`
-	Durations     []time.Duration `json:"durations"`
-	DurationStats durationStats   `json:"durationStats"`
-	Name          string          `json:"name"`
-}
-
-type statistics struct {
-	eventSummary  map[int]summary
-	workerSummary map[int]summary
-}
-
-// support for sorting summary arrays
-type byId []summary
-
-func (s byId) Len() int           { return len(s) }
-func (s byId) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
-func (s byId) Less(i, j int) bool { return s[i].Id < s[j].Id }
-
 func init() {
 	stressCmd.AddCommand(browserCmd)
 	browserCmd.Flags().IntVar(&speed, "speed", 1, "Replay speed (<=0 means: go as fast as possible)")
@@ -98,152 +67,70 @@ func init() {
 }
 
 func simulateBrowser() {
+	rec := newStressRecorder("browser")
+	rec.SetParam("speed", speed)
+	rec.SetParam("numStates", numStates)
+	rec.SetParam("numRuns", numRuns)
+	rec.SetParam("raceLimit", raceLimitMin)
+
 	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
 	defer pc.Close()
-	events, err := pc.GetEventList()
+	var events []*internal.Event
+	err := rec.Time(metricEventList, func() (err error) {
+		events, err = pc.GetEventList()
+		return err
+	})
 	if err != nil {
 		log.Fatal("Could not read event list", err)
 	}
 
 	queue := make(chan *jobData)
 	results := make(chan *jobResult)
-	statistics := make(chan *statistics)
-	go createJobs(queue, events, numRuns)
+	collectorDone := make(chan bool)
+	ctx, stop := stressContext()
+	defer stop()
+	go createJobs(ctx, rec, queue, events, numRuns)
 
 	wg := sync.WaitGroup{}
 
-	go createResultCollector(events, results, statistics, &wg)
+	go createResultCollector(rec, results, collectorDone)
 	for i := 0; i < internal.Worker; i++ {
 		wg.Add(1)
 		fmt.Printf("Starting worker %d\n", i)
-		go worker(i, queue, results, &wg)
+		go worker(ctx, i, rec, queue, results, &wg)
 	}
 
 	wg.Wait()
 
 	close(results)
+	<-collectorDone
 
-	printStatsSummary(<-statistics, events)
-
-}
-
-func printStatsSummary(stats *statistics, events []*internal.Event) {
-	lookup := make(map[int]*internal.Event, len(events))
-	for _, e := range events {
-		lookup[int(e.Id)] = e
-	}
-
-	eventsSummary := processSummary(stats.eventSummary, func(s summary) string {
-		return fmt.Sprintf("Event: %v-%v", lookup[s.Id].Id, lookup[s.Id].Name)
-	})
-	workerSummary := processSummary(stats.workerSummary, func(s summary) string {
-		return fmt.Sprintf("Worker: %v", s.Id)
-	})
-	fmt.Printf("\nSummary by events\n")
-	printSummary(eventsSummary)
-	fmt.Printf("\nSummary by workers\n")
-	printSummary(workerSummary)
-
-	type x struct {
-		Events  []summary `json:"events"`
-		Workers []summary `json:"workers"`
-	}
-	j := x{Events: eventsSummary, Workers: workerSummary}
-	jsonData, _ := json.Marshal(j)
-	// fmt.Printf("\n%v\n", string(jsonData))
-	os.WriteFile(fmt.Sprintf("stress-browser-%s.json", time.Now().Format("20060102-150405")), jsonData, 0644)
+	finishStressRun(ctx, rec)
 }
 
-func processSummary(s map[int]summary, title func(summary) string) []summary {
-	values := make([]summary, 0, len(s))
-	for _, v := range s {
-		values = append(values, v)
-	}
-
-	sort.Sort(byId(values))
-	for idx, item := range values {
-		item.DurationStats = minMaxAvg(item.Durations)
-		item.Name = title(item)
-		values[idx] = item
-		// fmt.Printf("%v\n", item)
-	}
-	// fmt.Printf("%v\n", values)
-	return values
-
-}
-
-func printSummary(values []summary) {
-
-	for _, item := range values {
-		fmt.Printf("%s\n", item.Name)
-		fmt.Printf("Num: %d Total: %s Min: %s Max: %s Avg: %s Fetches: %d States: %d\n", item.Num, item.DurationStats.Sum, item.DurationStats.Min, item.DurationStats.Max, item.DurationStats.Avg, item.NumFetches, item.NumStates)
-	}
-
-}
-
-func minMaxAvg(items []time.Duration) durationStats {
-	if len(items) == 0 {
-		return durationStats{}
-	}
-	min := time.Duration(math.MaxInt64)
-	max := time.Duration(0)
-	sum := time.Duration(0)
-	for _, arg := range items {
-		if arg < min {
-			min = arg
-		}
-		if arg > max {
-			max = arg
-		}
-		sum += arg
-	}
-	avg := int(sum) / len(items)
-	return durationStats{min, max, time.Duration(avg), sum}
-}
-
-func createResultCollector(events []*internal.Event, results chan *jobResult, stats chan *statistics, wg *sync.WaitGroup) {
-
-	byEventSummary := make(map[int]summary)
-	byWorkerSummary := make(map[int]summary)
-
+func createResultCollector(rec *report.Recorder, results chan *jobResult, done chan bool) {
 	for {
 		job, ok := <-results
 		if ok {
 			fmt.Printf("Worker %2d finishied Job %d: %v-%v used %d batches for %d packets in %s\n", job.workerId, job.jobId, job.event.Id, job.event.Name, job.numFetches, job.numStates, job.duration)
-			val, other := byEventSummary[int(job.event.Id)]
-			if !other {
-				val = summary{Id: int(job.event.Id), Durations: []time.Duration{}}
-			} else {
-				// fmt.Printf("Adding mode for %v\n", job.event.Id)
+			rec.Observe(metricJob, job.duration)
+			for group, name := range map[string]string{
+				"events":  fmt.Sprintf("Event: %v-%v", job.event.Id, job.event.Name),
+				"workers": fmt.Sprintf("Worker: %v", job.workerId),
+			} {
+				rec.ObserveIn(group, name, job.duration)
+				rec.AddIn(group, name, "fetches", job.numFetches)
+				rec.AddIn(group, name, "states", job.numStates)
 			}
-			val.Num += 1
-			val.NumFetches += job.numFetches
-			val.NumStates += job.numStates
-			val.Durations = append(val.Durations, job.duration)
-
-			byEventSummary[int(job.event.Id)] = val
-
-			val, other = byWorkerSummary[job.workerId]
-			if !other {
-				val = summary{Id: job.workerId, Durations: []time.Duration{}}
-			}
-			val.Num += 1
-			val.NumFetches += job.numFetches
-			val.NumStates += job.numStates
-			val.Durations = append(val.Durations, job.duration)
-			byWorkerSummary[job.workerId] = val
-
 		} else {
 			fmt.Printf("ResultCollector: no more results. Terminating\n")
-			// fmt.Printf("%+v\n", byEventSummary)
-			stats <- &statistics{eventSummary: byEventSummary, workerSummary: byWorkerSummary}
-
+			done <- true
 			return
 		}
 	}
 }
 
-func worker(idx int, queue chan *jobData, results chan *jobResult, wg *sync.WaitGroup) {
+func worker(ctx context.Context, idx int, rec *report.Recorder, queue chan *jobData, results chan *jobResult, wg *sync.WaitGroup) {
 
 	defer wg.Done()
 
@@ -251,7 +138,7 @@ func worker(idx int, queue chan *jobData, results chan *jobResult, wg *sync.Wait
 		job, ok := <-queue
 		if ok {
 			start := time.Now()
-			numFetches, numPackets := simulateFrontendFetching(job.event)
+			numFetches, numPackets := simulateFrontendFetching(ctx, rec, job.event, speed, numStates)
 			duration := time.Since((start))
 			results <- &jobResult{workerId: idx + 1, jobId: job.id, event: job.event, duration: duration, numFetches: numFetches, numStates: numPackets}
 			// fmt.Printf("Job %3d %v-%v done in %s\n", job.id, job.event.Id, job.event.Name, duration)
@@ -263,10 +150,10 @@ func worker(idx int, queue chan *jobData, results chan *jobResult, wg *sync.Wait
 	}
 }
 
-func createJobs(ch chan<- *jobData, events []*internal.Event, numRuns int) {
-	pickShortRace := func() int {
+func createJobs(ctx context.Context, rec *report.Recorder, ch chan<- *jobData, events []*internal.Event, numRuns int) {
+	pickShortRace := func(r *rand.Rand) int {
 		for {
-			pick := rand.Intn(len(events))
+			pick := r.Intn(len(events))
 			event := events[pick]
 			if raceLimitMin > 0 {
 				if (event.Data.ReplayInfo.MaxSessionTime - event.Data.ReplayInfo.MinSessionTime) < float64(raceLimitMin*60) {
@@ -279,36 +166,43 @@ func createJobs(ch chan<- *jobData, events []*internal.Event, numRuns int) {
 	}
 	defer close(ch)
 	for i := 0; i < numRuns; i++ {
-		pick := pickShortRace()
-		event := events[pick]
+		var event *internal.Event
+		if planned, ok := plannedJob("browser", i+1); ok {
+			event = plannedEvent(planned, events)
+		}
+		if event == nil {
+			event = events[pickShortRace(jobRand("browser", i+1))]
+		}
+		rec.Plan(report.PlanEntry{Actor: "browser", Job: i + 1, EventId: int(event.Id)})
 
 		fmt.Printf("Run %03d picked event %d: %s\n", i+1, event.Id, event.Name)
-		ch <- &jobData{id: i + 1, event: event}
+		select {
+		case ch <- &jobData{id: i + 1, event: event}:
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
-func simulateFrontendFetching(event *internal.Event) (int, int) {
+// simulateFrontendFetching fetches all states of the event like the frontend does on replay.
+// Returns the number of fetches and the number of states.
+func simulateFrontendFetching(ctx context.Context, rec *report.Recorder, event *internal.Event, speed int, numStates int) (int, int) {
 	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
 	defer pc.Close()
-	fetches := 0
 	numPackets := 0
-	from := event.Data.ReplayInfo.MinTimestamp
-	for goon := true; goon; {
-		fmt.Printf("Fetching %d states beginning at %d\n", numStates, int64(from))
-		states := pc.GetStates(int(event.Id), from, numStates)
-		fetches += 1
-		numPackets += len(states)
-		// fmt.Printf("Got %v states\n", len(states))
-		goon = len(states) > 0 // == numStates
-		if goon {
-			if speed > 0 {
-				sleep := int64(float64(len(states)*1000) / float64(speed))
-				// fmt.Printf("Sleeping for %+v ms\n", sleep)
-				time.Sleep(time.Duration(sleep) * time.Millisecond)
-			}
-
-			from = states[len(states)-1].Timestamp + 0.0001
+	states := pc.StateIterator(int(event.Id), event.Data.ReplayInfo.MinTimestamp, numStates).
+		SetObserver(observeFetch(rec, metricStateFetch))
+	defer states.Close()
+	for ctx.Err() == nil {
+		page, ok := states.NextPage()
+		if !ok {
+			break
+		}
+		numPackets += len(page)
+		if speed > 0 {
+			sleep := int64(float64(len(page)*1000) / float64(speed))
+			time.Sleep(time.Duration(sleep) * time.Millisecond)
 		}
 	}
-	return fetches, numPackets
+	return states.Pages(), numPackets
 }
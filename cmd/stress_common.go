@@ -1,5 +1,25 @@
 package cmd
 
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"racelogctl/internal"
+	"racelogctl/report"
+	"racelogctl/synth"
+	"racelogctl/wamp"
+	"sync"
+	"syscall"
+	"time"
+
+	nexusWamp "github.com/gammazero/nexus/v3/wamp"
+)
+
 var sourceEventId int = -1 // the eventId for the source
 var recordingSpeed int = 1 // used to simulate a faster recording speed
 var numListener int = 5    // the number of simulated live listeners
@@ -12,3 +32,262 @@ var numRuns = 1       // how many repetitions
 var numStates = 30    // how many states should be fetched in go
 var numSpeedMaps = 30 // how many speedmaps should be fetched in go
 var raceLimitMin = -1 // if > 0, pick only races shorter than this amount
+
+var useSynthetic = false                // if true, generate races instead of using archived events
+var synthParams = synth.DefaultParams() // parameters for synthetic races
+var synthRaceLengthArg string = "30m"   // race length of synthetic races
+
+// setupSynthParams validates the command line parameters for synthetic races.
+// Must be called before the first synthetic generator is created.
+func setupSynthParams() {
+	raceLength, err := time.ParseDuration(synthRaceLengthArg)
+	if err != nil {
+		log.Fatalf("Invalid synthetic race length %v: %v", synthRaceLengthArg, err)
+	}
+	if raceLength <= 0 {
+		log.Fatalf("Invalid synthetic race length %v: must be positive", synthRaceLengthArg)
+	}
+	synthParams.RaceLength = raceLength
+}
+
+// newSyntheticGenerator creates a generator for a synthetic race based on the command line parameters
+func newSyntheticGenerator(seed int64) *synth.Generator {
+	p := synthParams
+	p.Seed = seed
+	p.StartTime = time.Now()
+	return synth.New(p)
+}
+
+var reportDir = "."        // directory for stress reports
+var reportInterval = "10s" // interval for throughput aggregation in stress reports
+
+// names of the metrics collected during stress tests
+const (
+	metricStateFetch      = "archive.state.delta"
+	metricSpeedmapFetch   = "archive.speedmap"
+	metricCarData         = "get_event_cars"
+	metricTrack           = "get_track_info"
+	metricEventList       = "get_events"
+	metricProviderList    = "list_providers"
+	metricAnalysis        = "live.get_event_analysis"
+	metricRegister        = "register_provider"
+	metricUnregister      = "remove_provider"
+	metricPublishState    = "publish.state"
+	metricPublishSpeedmap = "publish.speedmap"
+	metricLiveState       = "live.state"
+	metricLiveUnmatched   = "live.state.unmatched" // received states not published by this run
+	metricSubscribe       = "subscribe"
+	metricJob             = "job"
+)
+
+var stressSeed int64 = 0 // seed for the random decisions of stress runs (0: derive from the current time)
+var replayFile = ""      // report of a previous run to replay
+var replayReport *report.Report
+
+// setupStressSeed initializes the seed (and the plan if a previous run is replayed) and stores it in the report
+func setupStressSeed(rec *report.Recorder) {
+	if len(replayFile) > 0 {
+		var err error
+		replayReport, err = report.ReadJSON(replayFile)
+		if err != nil {
+			log.Fatalf("Error reading replay report: %v", err)
+		}
+		if stressSeed == 0 {
+			stressSeed = replayReport.Seed
+		}
+		rec.SetParam("replay", replayFile)
+	}
+	if stressSeed == 0 {
+		stressSeed = time.Now().UnixNano()
+	}
+	log.Printf("Using seed %d\n", stressSeed)
+	rec.SetSeed(stressSeed)
+}
+
+// jobRand returns the random generator for a job of an actor.
+// The generator only depends on the seed, the actor and the job id, so the decisions for a job
+// are the same in every run with the same seed regardless of the worker processing it.
+func jobRand(actor string, job int) *rand.Rand {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s-%d", actor, job)
+	return rand.New(rand.NewSource(stressSeed ^ int64(h.Sum64())))
+}
+
+// plannedJob returns the plan entry of the replayed run for a job of an actor
+func plannedJob(actor string, job int) (report.PlanEntry, bool) {
+	if replayReport == nil {
+		return report.PlanEntry{}, false
+	}
+	return replayReport.Lookup(actor, job)
+}
+
+// plannedEvent returns the event with the id of the plan entry
+func plannedEvent(entry report.PlanEntry, events []*internal.Event) *internal.Event {
+	for _, e := range events {
+		if int(e.Id) == entry.EventId {
+			return e
+		}
+	}
+	log.Printf("Planned event %d for %s job %d not available. Picking a different one\n", entry.EventId, entry.Actor, entry.Job)
+	return nil
+}
+
+func newStressRecorder(mode string) *report.Recorder {
+	interval, err := time.ParseDuration(reportInterval)
+	if err != nil {
+		log.Fatalf("Invalid report interval %v: %v", reportInterval, err)
+	}
+	rec := report.NewRecorder(mode, interval)
+	rec.SetParam("url", internal.Url)
+	rec.SetParam("worker", internal.Worker)
+	setupStressSeed(rec)
+	return rec
+}
+
+// writeStressReport prints the summary of the recorded data and writes the json and html report
+func writeStressReport(rec *report.Recorder) {
+	rep := rec.Report()
+	rep.Print(os.Stdout)
+	base := filepath.Join(reportDir, fmt.Sprintf("stress-%s-%s", rep.Mode, rep.Start.Format("20060102-150405")))
+	if err := rep.WriteJSON(base + ".json"); err != nil {
+		log.Printf("Error writing report: %v\n", err)
+	}
+	if err := rep.WriteHTML(base + ".html"); err != nil {
+		log.Printf("Error writing report: %v\n", err)
+	}
+	log.Printf("Report written to %s.json and %s.html\n", base, base)
+}
+
+// observeFetch returns an observer for page requests which records the latency (or the error) of the metric
+func observeFetch(rec *report.Recorder, metric string) func(d time.Duration, n int, err error) {
+	return func(d time.Duration, n int, err error) {
+		if err != nil {
+			rec.Error(metric)
+			return
+		}
+		rec.Observe(metric, d)
+	}
+}
+
+// liveState records the send time of the state for the latency measurement of the listeners.
+// The state itself is not modified, so the recorded event keeps the original timestamps.
+func liveState(rec *report.Recorder, eventKey string, s internal.State) internal.State {
+	rec.MarkSent(liveStateKey(eventKey, s.Timestamp))
+	return s
+}
+
+func liveStateKey(eventKey string, timestamp float64) string {
+	return fmt.Sprintf("%s/%.6f", eventKey, timestamp)
+}
+
+// recordLiveLatency records the latency of a received live state message.
+// The send time is only known for states published by this run. Other states
+// (e.g. of real live events or producers in another process) are only counted.
+func recordLiveLatency(rec *report.Recorder, eventKey string, event *nexusWamp.Event) {
+	if len(event.Arguments) == 0 {
+		rec.Error(metricLiveState)
+		return
+	}
+	data, ok := nexusWamp.AsDict(event.Arguments[0])
+	if !ok {
+		rec.Error(metricLiveState)
+		return
+	}
+	ts, ok := nexusWamp.AsFloat64(data["timestamp"])
+	if !ok {
+		rec.Error(metricLiveState)
+		return
+	}
+	sent, ok := rec.SentAt(liveStateKey(eventKey, ts))
+	if !ok {
+		rec.Count(metricLiveUnmatched)
+		return
+	}
+	rec.Observe(metricLiveState, time.Since(sent))
+}
+
+// waitPublished waits until the publisher has processed all data and records a publish error
+func waitPublished(rec *report.Recorder, metric string, done <-chan error) {
+	if err := <-done; err != nil {
+		log.Printf("Error publishing %s: %v\n", metric, err)
+		rec.Error(metric)
+	}
+}
+
+// publishStressCarData publishes the car data of a recorded event. Errors are logged only.
+func publishStressCarData(dpc *wamp.DataProviderClient, eventKey string, carData *internal.EventCarMessage) {
+	if err := dpc.PublishCarData(eventKey, carData); err != nil {
+		log.Printf("Error publishing car data of %s: %v\n", eventKey, err)
+	}
+}
+
+// stressContext returns a context which is cancelled on SIGINT/SIGTERM.
+// After the first signal the default handling is restored, so a second Ctrl-C terminates immediately.
+func stressContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Printf("Shutting down. Press Ctrl-C again to terminate immediately\n")
+	}()
+	return ctx, stop
+}
+
+// registeredProviders keeps track of the providers registered by this run
+var registeredProviders = struct {
+	sync.Mutex
+	keys map[string]bool
+}{keys: map[string]bool{}}
+
+// registerStressProvider registers the provider and remembers it for unregistration on shutdown
+func registerStressProvider(rec *report.Recorder, dpc *wamp.DataProviderClient, registerMsg internal.RegisterMessage) error {
+	err := rec.Time(metricRegister, func() error { return dpc.RegisterProvider(registerMsg) })
+	if err == nil {
+		registeredProviders.Lock()
+		registeredProviders.keys[registerMsg.EventKey] = true
+		registeredProviders.Unlock()
+	}
+	return err
+}
+
+// unregisterStressProvider unregisters a provider created by registerStressProvider
+func unregisterStressProvider(rec *report.Recorder, dpc *wamp.DataProviderClient, eventKey string) error {
+	err := rec.Time(metricUnregister, func() error { return dpc.UnregisterProvider(eventKey) })
+	if err == nil {
+		registeredProviders.Lock()
+		delete(registeredProviders.keys, eventKey)
+		registeredProviders.Unlock()
+	}
+	return err
+}
+
+// unregisterRemainingProviders unregisters all providers of this run which are still registered
+func unregisterRemainingProviders(rec *report.Recorder) {
+	registeredProviders.Lock()
+	keys := make([]string, 0, len(registeredProviders.keys))
+	for k := range registeredProviders.keys {
+		keys = append(keys, k)
+	}
+	registeredProviders.Unlock()
+	if len(keys) == 0 {
+		return
+	}
+	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
+	defer dpc.Close()
+	for _, k := range keys {
+		if err := unregisterStressProvider(rec, dpc, k); err != nil {
+			log.Printf("Error unregistering provider %s: %v\n", k, err)
+		} else {
+			log.Printf("Unregistered provider %s\n", k)
+		}
+	}
+}
+
+// finishStressRun unregisters the remaining providers and writes the report
+func finishStressRun(ctx context.Context, rec *report.Recorder) {
+	if ctx.Err() != nil {
+		rec.SetParam("interrupted", true)
+	}
+	unregisterRemainingProviders(rec)
+	writeStressReport(rec)
+}
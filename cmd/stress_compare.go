@@ -0,0 +1,95 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"racelogctl/report"
+
+	"github.com/spf13/cobra"
+)
+
+var compareThresholds = report.DefaultThresholds()
+var compareShowAll bool
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <old.json> <new.json>",
+	Short: "Compares two stress reports and highlights regressions",
+	Long: `Compares two stress reports and highlights regressions.
+
+All metrics and the per-event/per-worker summaries are compared. Latency thresholds are
+relative increases in percent, the error threshold is the increase of the error ratio in
+percentage points, the rate threshold is the relative decrease in percent. Negative
+thresholds disable the check. The json files of older 'stress browser' runs are supported.
+
+Exits with status 1 if a regression was found.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !compareReports(args[0], args[1]) {
+			os.Exit(1)
+		}
+	},
+}
+
+var latencyThresholdPct, errorThresholdPct, rateThresholdPct float64
+
+func init() {
+	stressCmd.AddCommand(compareCmd)
+	compareCmd.Flags().Float64Var(&latencyThresholdPct, "latency-threshold", compareThresholds.Latency*100, "max increase of latencies (avg, p50, p90, p99) in percent")
+	compareCmd.Flags().Float64Var(&errorThresholdPct, "error-threshold", compareThresholds.ErrorRate*100, "max increase of the error ratio in percentage points")
+	compareCmd.Flags().Float64Var(&rateThresholdPct, "rate-threshold", compareThresholds.Rate, "max decrease of the rate in percent")
+	compareCmd.Flags().IntVar(&compareThresholds.MinSamples, "min-samples", compareThresholds.MinSamples, "metrics with less samples are not checked for latency regressions")
+	compareCmd.Flags().BoolVar(&compareShowAll, "all", false, "show all compared values, not just regressions")
+}
+
+// compareReports prints the differences of the reports. Returns false if a regression was found.
+func compareReports(oldFile, newFile string) bool {
+	old, err := report.ReadJSON(oldFile)
+	if err != nil {
+		log.Fatalf("Error reading report: %v", err)
+	}
+	current, err := report.ReadJSON(newFile)
+	if err != nil {
+		log.Fatalf("Error reading report: %v", err)
+	}
+	if old.Mode != current.Mode {
+		log.Printf("Comparing reports of different modes: %s and %s\n", old.Mode, current.Mode)
+	}
+	compareThresholds.Latency = latencyThresholdPct / 100
+	compareThresholds.ErrorRate = errorThresholdPct / 100
+	compareThresholds.Rate = rateThresholdPct / 100
+	if rateThresholdPct < 0 {
+		compareThresholds.Rate = -1
+	}
+
+	diffs := report.Compare(old, current, compareThresholds)
+	report.PrintDiffs(os.Stdout, diffs, compareShowAll)
+	if report.HasRegression(diffs) {
+		fmt.Printf("\nRegressions found\n")
+		return false
+	}
+	fmt.Printf("\nNo regressions found\n")
+	return true
+}
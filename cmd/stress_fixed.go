@@ -22,11 +22,14 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
 	"io"
 	"log"
 	"racelogctl/internal"
+	"racelogctl/report"
+	"racelogctl/synth"
 	"racelogctl/wamp"
 	"time"
 
@@ -43,6 +46,7 @@ var fixedCmd = &cobra.Command{
 	
 NOTE: This command performs the recording of an event while a number of 
 clients will be connected to the live server.
+If no source event is given a synthetic race is recorded (see --synth-* flags).
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		simulateLiveRecording()
@@ -56,20 +60,29 @@ func init() {
 	fixedCmd.Flags().IntVar(&numListener, "num-listener", numListener, "How many states should be fetched in one request")
 
 	fixedCmd.Flags().IntVar(&sourceEventId, "eventId", sourceEventId, "the id of the source event")
-	fixedCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action")
+	fixedCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action (prefer RACELOG_DATAPROVIDER_PASSWORD, --password-file or --password-cmd)")
 
 	fixedCmd.Flags().StringVar(&eventKey, "eventKey", "", "sets the event key")
 
 	fixedCmd.Flags().StringVar(&internal.SourceUrl, "source-url", "", "sets the url of the source server")
+	fixedCmd.Flags().BoolVar(&useSynthetic, "synthetic", useSynthetic, "record a synthetic race (default if no eventId is given)")
 
 }
 
 func simulateLiveRecording() {
-	if sourceEventId == -1 {
-		fmt.Printf("we could pick a random event here. For now we do nothing\n")
+	rec := newStressRecorder("fixed")
+	rec.SetParam("speed", recordingSpeed)
+	rec.SetParam("numListener", numListener)
+	ctx, stop := stressContext()
+	defer stop()
+	if sourceEventId == -1 || useSynthetic {
+		rec.SetParam("source", "synthetic")
+		setupSynthParams()
+		simulateSyntheticRecording(ctx, rec)
+		finishStressRun(ctx, rec)
 		return
-
 	}
+	rec.SetParam("source", sourceEventId)
 
 	var sourcePc *wamp.PublicClient
 	var destPc *wamp.PublicClient
@@ -108,12 +121,16 @@ func simulateLiveRecording() {
 		registerMsg.EventKey = eventKey
 
 	}
-	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, internal.DataproviderPassword)
-	dpc.RegisterProvider(registerMsg)
+	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
 	defer dpc.Close()
+	if err := registerStressProvider(rec, dpc, registerMsg); err != nil {
+		log.Printf("Error registering event: %v\n", err)
+		finishStressRun(ctx, rec)
+		return
+	}
 	producerDone := make(chan bool)
 	// create producer
-	go simulateRacelogger(sourcePc, event, registerMsg.EventKey, producerDone)
+	go simulateRacelogger(ctx, rec, sourcePc, event, registerMsg.EventKey, producerDone)
 
 	// create live consumer
 	// wg := sync.WaitGroup{}
@@ -121,7 +138,7 @@ func simulateLiveRecording() {
 	for i := 0; i < numListener; i++ {
 
 		fmt.Printf("Starting listener %d\n", i)
-		go simulateBrowserListener(i, registerMsg.EventKey)
+		go simulateBrowserListener(i, rec, registerMsg.EventKey)
 	}
 
 	// wg.Wait()
@@ -130,15 +147,57 @@ func simulateLiveRecording() {
 
 	log.Printf("Producer done\n")
 
-	dpc.UnregisterProvider(registerMsg.EventKey)
+	unregisterStressProvider(rec, dpc, registerMsg.EventKey)
+
+	log.Printf("Unregistered event\n")
+
+	time.Sleep(time.Duration(2) * time.Second)
+	log.Printf("Wait done\n")
+	finishStressRun(ctx, rec)
+}
+
+func simulateSyntheticRecording(ctx context.Context, rec *report.Recorder) {
+	// the race depends on the seed of the run, so --seed and --replay reproduce it
+	seed := jobRand("producer", 1).Int63()
+	if planned, replay := plannedJob("producer", 1); replay && planned.Seed != 0 {
+		seed = planned.Seed
+	}
+	rec.Plan(report.PlanEntry{Actor: "producer", Job: 1, Seed: seed})
+	gen := newSyntheticGenerator(seed)
+	key := eventKey
+	if key == "" {
+		h := md5.New()
+		io.WriteString(h, gen.Info().Name)
+		io.WriteString(h, uuid.New().String())
+		key = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	registerMsg := gen.RegisterMessage(key)
+	registerMsg.Info.Name = fmt.Sprintf("stresstest-%s", time.Now().Format("20060102-150405"))
+
+	dpc := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
+	defer dpc.Close()
+	if err := registerStressProvider(rec, dpc, registerMsg); err != nil {
+		log.Printf("Error registering event: %v\n", err)
+		return
+	}
+	producerDone := make(chan bool)
+	go simulateSyntheticRacelogger(ctx, rec, gen, registerMsg.EventKey, producerDone)
+
+	for i := 0; i < numListener; i++ {
+		fmt.Printf("Starting listener %d\n", i)
+		go simulateBrowserListener(i, rec, registerMsg.EventKey)
+	}
 
+	<-producerDone
+	log.Printf("Producer done\n")
+	unregisterStressProvider(rec, dpc, registerMsg.EventKey)
 	log.Printf("Unregistered event\n")
 
 	time.Sleep(time.Duration(2) * time.Second)
 	log.Printf("Wait done\n")
 }
 
-func simulateBrowserListener(idx int, eventKey string) {
+func simulateBrowserListener(idx int, rec *report.Recorder, eventKey string) {
 
 	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
 	defer pc.Close()
@@ -147,7 +206,7 @@ func simulateBrowserListener(idx int, eventKey string) {
 	msgNum := 0
 	handler := func(event *nexusWamp.Event) {
 		msgNum++
-		// eventData, b := nexusWamp.AsList(event.Arguments[0])
+		recordLiveLatency(rec, eventKey, event)
 
 		if (msgNum % 100) == 0 {
 
@@ -155,45 +214,68 @@ func simulateBrowserListener(idx int, eventKey string) {
 			// log.Printf("Event: %+v\n", event)
 		}
 	}
-	err := pc.Client().Subscribe(topic, handler, nil)
+	err := rec.Time(metricSubscribe, func() error {
+		return pc.Client().Subscribe(topic, handler, nil)
+	})
 	if err != nil {
-		log.Fatal("subscribe error: ", err)
+		log.Printf("Listener %d: subscribe error: %v\n", idx, err)
+		return
 	}
 	<-pc.Client().Done()
 	log.Printf("subsriber %d finished\n", idx)
 }
 
-func simulateRacelogger(pc *wamp.PublicClient, event *internal.Event, recordingEventKey string, done chan bool) {
-
-	fetches := 0
-	numPackets := 0
+func simulateRacelogger(ctx context.Context, rec *report.Recorder, pc *wamp.PublicClient, event *internal.Event, recordingEventKey string, done chan bool) {
 
 	sender := make(chan internal.State)
-	dataprovider := wamp.NewDataProviderClient(internal.Url, internal.Realm, internal.DataproviderPassword)
+	dataprovider := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
 	defer dataprovider.Close()
-	dataprovider.PublishStateFromChannel(recordingEventKey, sender)
-
-	from := event.Data.ReplayInfo.MinTimestamp
-	for goon := true; goon; {
-		// fmt.Printf("Fetching %d states beginning at %d\n", numStates, int64(from))
-		states := pc.GetStates(int(event.Id), from, numStates)
-		fetches += 1
-		numPackets += len(states)
-		// fmt.Printf("Got %v states\n", len(states))
-		goon = len(states) > 0 // == numStates
-		if goon {
-			for _, state := range states {
-
-				sender <- state
-				if recordingSpeed > 0 {
-					sleep := int64(1000 / float64(recordingSpeed))
-					fmt.Printf("Sleeping for %+v ms\n", sleep)
-					time.Sleep(time.Duration(sleep) * time.Millisecond)
-				}
-			}
-
-			from = states[len(states)-1].Timestamp + 0.0001
+	senderDone := dataprovider.PublishStateFromChannel(recordingEventKey, sender)
+
+	states := pc.StateIterator(int(event.Id), event.Data.ReplayInfo.MinTimestamp, numStates).
+		SetObserver(observeFetch(rec, metricStateFetch))
+	defer states.Close()
+	for ctx.Err() == nil && states.Next() {
+		sender <- liveState(rec, recordingEventKey, states.Value())
+		rec.Count(metricPublishState)
+		if recordingSpeed > 0 {
+			sleep := int64(1000 / float64(recordingSpeed))
+			fmt.Printf("Sleeping for %+v ms\n", sleep)
+			time.Sleep(time.Duration(sleep) * time.Millisecond)
+		}
+	}
+	close(sender)
+	waitPublished(rec, metricPublishState, senderDone)
+	done <- true
+}
+
+func simulateSyntheticRacelogger(ctx context.Context, rec *report.Recorder, gen *synth.Generator, recordingEventKey string, done chan bool) {
+	states := make(chan internal.State)
+	speedmaps := make(chan internal.SpeedmapMessage)
+	dataprovider := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
+	defer dataprovider.Close()
+	statesDone := dataprovider.PublishStateFromChannel(recordingEventKey, states)
+	speedmapsDone := dataprovider.PublishSpeedmapDataFromChannel(recordingEventKey, speedmaps)
+	publishStressCarData(dataprovider, recordingEventKey, gen.CarData())
+
+	for ctx.Err() == nil {
+		state, speedmap, ok := gen.Next()
+		if !ok {
+			break
+		}
+		states <- liveState(rec, recordingEventKey, state)
+		rec.Count(metricPublishState)
+		if speedmap != nil {
+			speedmaps <- *speedmap
+			rec.Count(metricPublishSpeedmap)
+		}
+		if recordingSpeed > 0 {
+			time.Sleep(time.Duration(1000/recordingSpeed) * time.Millisecond)
 		}
 	}
+	close(states)
+	close(speedmaps)
+	waitPublished(rec, metricPublishState, statesDone)
+	waitPublished(rec, metricPublishSpeedmap, speedmapsDone)
 	done <- true
 }
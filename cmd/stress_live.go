@@ -25,8 +25,8 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math/rand"
 	"racelogctl/internal"
+	"racelogctl/report"
 	"racelogctl/wamp"
 	"sync"
 	"time"
@@ -81,7 +81,14 @@ func init() {
 }
 
 func setupScenario() {
-	ctx, cancel := context.WithCancel(context.Background())
+	stressCtx, stop := stressContext()
+	defer stop()
+	ctx, cancel := context.WithCancel(stressCtx)
+	rec := newStressRecorder("live")
+	rec.SetParam("duration", testDurationArg)
+	rec.SetParam("workerListenDuration", workerListenDurationArg)
+	rec.SetParam("workerPauseDuration", workerPauseDurationArg)
+	rec.SetParam("workerRandomDuration", workerListenRandom)
 
 	workerPause, _ = time.ParseDuration(workerPauseDurationArg)
 	workerListen, _ = time.ParseDuration(workerListenDurationArg)
@@ -93,7 +100,7 @@ func setupScenario() {
 	for i := 0; i < internal.Worker; i++ {
 		wg.Add(1)
 		fmt.Printf("Starting worker %d\n", i)
-		go simBrowserClient(i, queue, &wg, ctx)
+		go simBrowserClient(i, rec, queue, &wg, ctx)
 		jobNum++
 		queue <- jobNum
 	}
@@ -101,7 +108,10 @@ func setupScenario() {
 	go func() {
 		testDuration, _ := time.ParseDuration(testDurationArg)
 		log.Printf("Waiting %v to terminate worker\n", testDuration)
-		time.Sleep(testDuration)
+		select {
+		case <-time.After(testDuration):
+		case <-ctx.Done():
+		}
 		log.Printf("signalling cancel\n")
 		cancel()
 		log.Printf("signalled cancel\n")
@@ -110,9 +120,10 @@ func setupScenario() {
 	log.Printf("Waiting for terminating jobs\n")
 	wg.Wait()
 	log.Printf("All workers finished\n")
+	finishStressRun(stressCtx, rec)
 }
 
-func simBrowserClient(idx int, queue chan int, wg *sync.WaitGroup, ctx context.Context) {
+func simBrowserClient(idx int, rec *report.Recorder, queue chan int, wg *sync.WaitGroup, ctx context.Context) {
 	defer wg.Done()
 	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
 	defer pc.Close()
@@ -126,7 +137,11 @@ func simBrowserClient(idx int, queue chan int, wg *sync.WaitGroup, ctx context.C
 			fmt.Printf("Dummy: %v\n", dummy)
 
 			fmt.Println("get available live events")
-			providers, _ := pc.ProviderList()
+			var providers []*internal.ProviderData
+			rec.Time(metricProviderList, func() (err error) {
+				providers, err = pc.ProviderList()
+				return err
+			})
 			if (len(providers)) == 0 {
 				log.Println("no event avail. pausing")
 				time.Sleep(workerPause)
@@ -137,9 +152,8 @@ func simBrowserClient(idx int, queue chan int, wg *sync.WaitGroup, ctx context.C
 				}()
 
 			} else {
-				pick := rand.Intn(len(providers))
-
-				go simulateLiveListener(dummy, providers[pick].EventKey, queue)
+				eventKey, unsubTimer := pickLiveEvent(rec, dummy, providers)
+				go simulateLiveListener(ctx, dummy, rec, eventKey, unsubTimer, queue)
 
 			}
 
@@ -147,36 +161,72 @@ func simBrowserClient(idx int, queue chan int, wg *sync.WaitGroup, ctx context.C
 	}
 }
 
-func simulateLiveListener(idx int, eventKey string, queue chan int) {
+// pickLiveEvent selects the live event and the listen duration for the job
+func pickLiveEvent(rec *report.Recorder, job int, providers []*internal.ProviderData) (string, time.Duration) {
+	r := jobRand("listener", job)
+	eventKey := providers[r.Intn(len(providers))].EventKey
+	unsubTimer := workerListen
+	if workerListenRandom && workerListen >= time.Second {
+		unsubTimer = time.Duration(r.Intn(int(workerListen.Seconds()))) * time.Second
+	}
+	if planned, ok := plannedJob("listener", job); ok {
+		unsubTimer = planned.Duration
+		for _, p := range providers {
+			if p.EventKey == planned.EventKey {
+				eventKey = p.EventKey
+			}
+		}
+		if eventKey != planned.EventKey {
+			log.Printf("Planned event %s for listener job %d not available. Picking a different one\n", planned.EventKey, job)
+		}
+	}
+	rec.Plan(report.PlanEntry{Actor: "listener", Job: job, EventKey: eventKey, Duration: unsubTimer})
+	return eventKey, unsubTimer
+}
+
+func simulateLiveListener(ctx context.Context, idx int, rec *report.Recorder, eventKey string, unsubTimer time.Duration, queue chan int) {
+	listenLiveEvent(ctx, idx, rec, eventKey, unsubTimer)
+
+	time.Sleep(workerPause)
+	jobNum++
+	queue <- jobNum
+}
+
+// listenLiveEvent requests the analysis data of the live event and listens to its states
+// for the given duration or until the context is cancelled
+func listenLiveEvent(ctx context.Context, idx int, rec *report.Recorder, eventKey string, unsubTimer time.Duration) {
 	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
 
 	defer pc.Close()
 
-	pc.GetLiveAnalysisData(eventKey) // don't need, just to issue the request
+	rec.Time(metricAnalysis, func() error {
+		_, err := pc.GetLiveAnalysisData(eventKey) // don't need, just to issue the request
+		return err
+	})
 
 	topic := fmt.Sprintf("racelog.public.live.state.%s", eventKey)
 	msgNum := 0
 	handler := func(event *nexusWamp.Event) {
 		msgNum++
-		// eventData, b := nexusWamp.AsList(event.Arguments[0])
-
+		recordLiveLatency(rec, eventKey, event)
 		// log.Printf("Listener %d - Event data for topic %s: msgNum %d \n", idx, eventKey, msgNum)
 		// log.Printf("Event: %+v\n", event)
 
 	}
-	err := pc.Client().Subscribe(topic, handler, nil)
+	err := rec.Time(metricSubscribe, func() error {
+		return pc.Client().Subscribe(topic, handler, nil)
+	})
 	if err != nil {
-		log.Fatal("subscribe error: ", err)
+		log.Printf("subscribe error: %v\n", err)
+		return
 	}
 
 	go func() {
-
-		unsubTimer := workerListen
-		if workerListenRandom {
-			unsubTimer, _ = time.ParseDuration(fmt.Sprintf("%ds", rand.Intn(int(workerListen.Seconds()))))
-		}
 		log.Printf("i: %v Unsub in %v\n", idx, unsubTimer)
-		time.Sleep(unsubTimer)
+		select {
+		case <-time.After(unsubTimer):
+		case <-ctx.Done():
+		}
 		pc.Client().Unsubscribe(topic)
 		log.Printf("i: %v: unsubscribed\n", idx)
 		pc.Client().Close()
@@ -185,8 +235,4 @@ func simulateLiveListener(idx int, eventKey string, queue chan int) {
 	log.Printf("i: %v vor done \n", idx)
 	<-pc.Client().Done()
 	log.Printf("subsriber %d finished\n", idx)
-
-	time.Sleep(workerPause)
-	jobNum++
-	queue <- jobNum
 }
@@ -0,0 +1,440 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"racelogctl/internal"
+	"racelogctl/report"
+	"racelogctl/wamp"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run <scenario.yml>",
+	Short: "Runs a stress scenario with producers, listeners and browsers in one process",
+	Long: `Runs a stress scenario with producers, listeners and browsers in one process.
+
+The scenario file describes the actors and a sequence of phases. Each phase defines
+the number of actors of each type. If ramp is true, the number of actors changes
+linearly from the previous phase to the values of this phase, otherwise the numbers
+are applied at the beginning of the phase.
+
+Actors:
+- producer: records a copy of an archived (or synthetic) event (like 'stress timed')
+- listener: listens to a live event (like 'stress live')
+- browser:  fetches all states of an archived event (like 'stress browser')
+
+Example:
+name: weekend-load
+events:
+  synthetic: false             # producers record synthetic races
+  ids: [12, 17]                # events to use (default: all suitable events)
+  min-session-duration: 30m    # producers: minimum session duration of the source
+  race-limit: 2h               # browsers: max race length to consider
+producer:
+  speed: 2
+listener:
+  listen-duration: 2m
+  pause: 5s
+  random-duration: true
+browser:
+  speed: 0
+  num-states: 30
+phases:
+  - name: ramp-up
+    duration: 5m
+    ramp: true
+    producers: 5
+    listeners: 50
+    browsers: 10
+  - name: steady
+    duration: 30m
+    producers: 5
+    listeners: 50
+    browsers: 10
+  - name: ramp-down
+    duration: 5m
+    ramp: true
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sc, err := readScenario(args[0])
+		if err != nil {
+			log.Fatalf("Error reading scenario: %v", err)
+		}
+		runScenario(sc)
+	},
+}
+
+type scenario struct {
+	Name     string           `mapstructure:"name"`
+	Events   scenarioEvents   `mapstructure:"events"`
+	Producer scenarioProducer `mapstructure:"producer"`
+	Listener scenarioListener `mapstructure:"listener"`
+	Browser  scenarioBrowser  `mapstructure:"browser"`
+	Phases   []scenarioPhase  `mapstructure:"phases"`
+}
+
+type scenarioEvents struct {
+	Ids                []int         `mapstructure:"ids"`
+	Synthetic          bool          `mapstructure:"synthetic"`
+	MinSessionDuration time.Duration `mapstructure:"min-session-duration"`
+	RaceLimit          time.Duration `mapstructure:"race-limit"`
+}
+
+type scenarioProducer struct {
+	Speed int `mapstructure:"speed"`
+}
+
+type scenarioListener struct {
+	ListenDuration time.Duration `mapstructure:"listen-duration"`
+	Pause          time.Duration `mapstructure:"pause"`
+	RandomDuration bool          `mapstructure:"random-duration"`
+}
+
+type scenarioBrowser struct {
+	Speed     int `mapstructure:"speed"`
+	NumStates int `mapstructure:"num-states"`
+}
+
+type scenarioPhase struct {
+	Name      string        `mapstructure:"name"`
+	Duration  time.Duration `mapstructure:"duration"`
+	Ramp      bool          `mapstructure:"ramp"`
+	Producers int           `mapstructure:"producers"`
+	Listeners int           `mapstructure:"listeners"`
+	Browsers  int           `mapstructure:"browsers"`
+}
+
+func init() {
+	stressCmd.AddCommand(runCmd)
+	runCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action (prefer RACELOG_DATAPROVIDER_PASSWORD, --password-file or --password-cmd)")
+	runCmd.Flags().StringVar(&internal.SourceUrl, "source-url", "", "sets the url of the source server for producers")
+}
+
+func readScenario(filename string) (*scenario, error) {
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	sc := &scenario{
+		Producer: scenarioProducer{Speed: 1},
+		Listener: scenarioListener{ListenDuration: time.Minute, Pause: 5 * time.Second},
+		Browser:  scenarioBrowser{Speed: 1, NumStates: numStates},
+	}
+	if err := v.Unmarshal(sc); err != nil {
+		return nil, err
+	}
+	if len(sc.Phases) == 0 {
+		return nil, fmt.Errorf("no phases defined")
+	}
+	for i, p := range sc.Phases {
+		if p.Duration <= 0 {
+			return nil, fmt.Errorf("phase %d (%s): duration must be > 0", i+1, p.Name)
+		}
+		if p.Producers < 0 || p.Listeners < 0 || p.Browsers < 0 {
+			return nil, fmt.Errorf("phase %d (%s): negative number of actors", i+1, p.Name)
+		}
+	}
+	return sc, nil
+}
+
+// actorPool manages the running actors of one type
+type actorPool struct {
+	kind    string
+	run     func(ctx context.Context, id int)
+	cancels []context.CancelFunc
+	nextId  int
+	wg      sync.WaitGroup
+}
+
+// scale starts or stops actors until n actors are running
+func (p *actorPool) scale(ctx context.Context, n int) {
+	for len(p.cancels) < n {
+		p.nextId++
+		actorCtx, cancel := context.WithCancel(ctx)
+		p.cancels = append(p.cancels, cancel)
+		p.wg.Add(1)
+		go func(id int) {
+			defer p.wg.Done()
+			p.run(actorCtx, id)
+		}(p.nextId)
+		log.Printf("started %s %d (%d running)\n", p.kind, p.nextId, len(p.cancels))
+	}
+	for len(p.cancels) > n {
+		last := len(p.cancels) - 1
+		p.cancels[last]()
+		p.cancels = p.cancels[:last]
+		log.Printf("stopping %s (%d running)\n", p.kind, len(p.cancels))
+	}
+}
+
+// interpolate returns the number of actors at fraction f (0..1) of a phase
+func interpolate(from, to int, f float64) int {
+	if f >= 1 {
+		return to
+	}
+	return from + int(float64(to-from)*f)
+}
+
+func runScenario(sc *scenario) {
+	rec := newStressRecorder("run")
+	rec.SetParam("scenario", sc.Name)
+
+	useSynthetic = sc.Events.Synthetic
+	workerListen = sc.Listener.ListenDuration
+	workerListenRandom = sc.Listener.RandomDuration
+	if useSynthetic {
+		setupSynthParams()
+	}
+	if !useSynthetic && scenarioNeedsProducers(sc) {
+		availableEvents = scenarioProducerEvents(sc)
+		if len(availableEvents) == 0 {
+			log.Fatalf("No suitable source events found for producers")
+		}
+	}
+	var browserEvents []*internal.Event
+	if scenarioNeedsBrowsers(sc) {
+		browserEvents = scenarioBrowserEvents(sc)
+		if len(browserEvents) == 0 {
+			log.Fatalf("No suitable events found for browsers")
+		}
+	}
+
+	stressCtx, stop := stressContext()
+	defer stop()
+	ctx, cancel := context.WithCancel(stressCtx)
+	defer cancel()
+	pools := []*actorPool{
+		{kind: "producer", run: func(ctx context.Context, id int) { scenarioProducerActor(ctx, id, rec, sc) }},
+		{kind: "listener", run: func(ctx context.Context, id int) { scenarioListenerActor(ctx, id, rec, sc) }},
+		{kind: "browser", run: func(ctx context.Context, id int) { scenarioBrowserActor(ctx, id, rec, sc, browserEvents) }},
+	}
+
+	start := time.Now()
+	prev := scenarioPhase{}
+phases:
+	for i, phase := range sc.Phases {
+		log.Printf("Phase %d (%s) for %s: producers %d listeners %d browsers %d\n", i+1, phase.Name, phase.Duration, phase.Producers, phase.Listeners, phase.Browsers)
+		rec.SetParam(fmt.Sprintf("phase %d", i+1), fmt.Sprintf("%s at %s for %s (producers %d listeners %d browsers %d ramp %v)",
+			phase.Name, time.Since(start).Round(time.Second), phase.Duration, phase.Producers, phase.Listeners, phase.Browsers, phase.Ramp))
+		phaseStart := time.Now()
+		ticker := time.NewTicker(time.Second)
+		for {
+			f := 1.0
+			if phase.Ramp {
+				f = float64(time.Since(phaseStart)) / float64(phase.Duration)
+			}
+			pools[0].scale(ctx, interpolate(prev.Producers, phase.Producers, f))
+			pools[1].scale(ctx, interpolate(prev.Listeners, phase.Listeners, f))
+			pools[2].scale(ctx, interpolate(prev.Browsers, phase.Browsers, f))
+			if time.Since(phaseStart) >= phase.Duration {
+				break
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				ticker.Stop()
+				break phases
+			}
+		}
+		ticker.Stop()
+		prev = phase
+	}
+
+	log.Printf("Scenario done. Waiting for actors to terminate\n")
+	cancel()
+	for _, p := range pools {
+		p.scale(ctx, 0)
+		p.wg.Wait()
+	}
+	finishStressRun(stressCtx, rec)
+}
+
+func scenarioNeedsProducers(sc *scenario) bool {
+	for _, p := range sc.Phases {
+		if p.Producers > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func scenarioNeedsBrowsers(sc *scenario) bool {
+	for _, p := range sc.Phases {
+		if p.Browsers > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// selectScenarioEvents returns the events selected by ids (or all events if no ids are given) which match the filter
+func selectScenarioEvents(sc *scenario, url string, filter func(e *internal.Event) bool) []*internal.Event {
+	pc := wamp.NewPublicClient(url, internal.Realm)
+	defer pc.Close()
+	allEvents, err := pc.GetEventList()
+	if err != nil {
+		log.Fatalf("Could not read event list: %v", err)
+	}
+	ids := map[int]bool{}
+	for _, id := range sc.Events.Ids {
+		ids[id] = true
+	}
+	ret := []*internal.Event{}
+	for _, e := range allEvents {
+		if (len(ids) == 0 || ids[int(e.Id)]) && filter(e) {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+func scenarioProducerEvents(sc *scenario) []*internal.Event {
+	source := internal.SourceUrl
+	if len(source) == 0 {
+		source = internal.Url
+	}
+	return selectScenarioEvents(sc, source, func(e *internal.Event) bool {
+		return raceLoggerVersion(e) && isMinSessionLength(e, int(sc.Events.MinSessionDuration.Minutes()))
+	})
+}
+
+func scenarioBrowserEvents(sc *scenario) []*internal.Event {
+	return selectScenarioEvents(sc, internal.Url, func(e *internal.Event) bool {
+		return sc.Events.RaceLimit <= 0 ||
+			(e.Data.ReplayInfo.MaxSessionTime-e.Data.ReplayInfo.MinSessionTime) < sc.Events.RaceLimit.Seconds()
+	})
+}
+
+// sleepCtx sleeps for the duration. Returns false if the context was cancelled before.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+var scenarioJobMutex sync.Mutex
+var producerJobId, listenerJobId, browserJobId int
+
+// nextScenarioJob returns the next id of the job counter
+func nextScenarioJob(counter *int) int {
+	scenarioJobMutex.Lock()
+	defer scenarioJobMutex.Unlock()
+	*counter++
+	return *counter
+}
+
+// scenarioProducerActor records events until the context is cancelled
+func scenarioProducerActor(ctx context.Context, id int, rec *report.Recorder, sc *scenario) {
+	source := internal.SourceUrl
+	if len(source) == 0 {
+		source = internal.Url
+	}
+	pc := wamp.NewPublicClient(source, internal.Realm)
+	defer pc.Close()
+	dataprovider := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
+	defer dataprovider.Close()
+
+	for ctx.Err() == nil {
+		job := newTimedJobRequest(rec, nextScenarioJob(&producerJobId))
+		log.Printf("Producer %d got job %v\n", id, job.output())
+		start := time.Now()
+		if recordTimedJob(ctx, id, rec, pc, dataprovider, job, sc.Producer.Speed) {
+			rec.Observe(metricJob, time.Since(start))
+		}
+	}
+}
+
+// scenarioListenerActor listens to live events until the context is cancelled
+func scenarioListenerActor(ctx context.Context, id int, rec *report.Recorder, sc *scenario) {
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+
+	retry := time.Duration(0)
+	for ctx.Err() == nil {
+		var providers []*internal.ProviderData
+		rec.Time(metricProviderList, func() (err error) {
+			providers, err = pc.ProviderList()
+			return err
+		})
+		if len(providers) == 0 {
+			retry = nextRetryPause(retry, sc.Listener.Pause)
+			log.Printf("Listener %d: no event avail. pausing %v\n", id, retry)
+			sleepCtx(ctx, retry)
+			continue
+		}
+		retry = 0
+		eventKey, listen := pickLiveEvent(rec, nextScenarioJob(&listenerJobId), providers)
+		listenLiveEvent(ctx, id, rec, eventKey, listen)
+		sleepCtx(ctx, sc.Listener.Pause)
+	}
+}
+
+// limits of the pause before requesting the provider list again if no live event is available
+const (
+	minRetryPause = time.Second
+	maxRetryPause = 30 * time.Second
+)
+
+// nextRetryPause doubles the previous pause (at least the configured pause) up to maxRetryPause
+func nextRetryPause(previous, pause time.Duration) time.Duration {
+	next := max(2*previous, pause, minRetryPause)
+	return min(next, max(maxRetryPause, pause))
+}
+
+// scenarioBrowserActor replays archived events until the context is cancelled
+func scenarioBrowserActor(ctx context.Context, id int, rec *report.Recorder, sc *scenario, events []*internal.Event) {
+	for ctx.Err() == nil {
+		job := nextScenarioJob(&browserJobId)
+		var event *internal.Event
+		if planned, ok := plannedJob("browser", job); ok {
+			event = plannedEvent(planned, events)
+		}
+		if event == nil {
+			event = events[jobRand("browser", job).Intn(len(events))]
+		}
+		rec.Plan(report.PlanEntry{Actor: "browser", Job: job, EventId: int(event.Id)})
+		start := time.Now()
+		numFetches, numPackets := simulateFrontendFetching(ctx, rec, event, sc.Browser.Speed, sc.Browser.NumStates)
+		if ctx.Err() != nil {
+			return
+		}
+		duration := time.Since(start)
+		name := fmt.Sprintf("Event: %v-%v", event.Id, event.Name)
+		rec.ObserveIn("events", name, duration)
+		rec.AddIn("events", name, "fetches", numFetches)
+		rec.AddIn("events", name, "states", numPackets)
+	}
+}
@@ -27,8 +27,9 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
 	"racelogctl/internal"
+	"racelogctl/report"
+	"racelogctl/synth"
 	"racelogctl/util"
 	"racelogctl/wamp"
 	"sync"
@@ -65,6 +66,7 @@ var availableEvents []*internal.Event
 type TimedJobRequest struct {
 	id          int
 	eventSource *internal.Event
+	generator   *synth.Generator // set if a synthetic race should be recorded
 }
 
 type TimedJobResult struct {
@@ -76,13 +78,38 @@ func (j TimedJobRequest) output() string {
 	return fmt.Sprintf("JobId: %d Event: %s", j.id, composeEventOverview(j.eventSource))
 }
 
+// newTimedJobRequest creates a job either for a random archived event or for a synthetic race
+func newTimedJobRequest(rec *report.Recorder, id int) *TimedJobRequest {
+	r := jobRand("producer", id)
+	planned, replay := plannedJob("producer", id)
+	if useSynthetic {
+		seed := r.Int63()
+		if replay && planned.Seed != 0 {
+			seed = planned.Seed
+		}
+		gen := newSyntheticGenerator(seed)
+		rec.Plan(report.PlanEntry{Actor: "producer", Job: id, Seed: seed})
+		return &TimedJobRequest{id: id, eventSource: gen.Event(), generator: gen}
+	}
+	var event *internal.Event
+	if replay {
+		event = plannedEvent(planned, availableEvents)
+	}
+	if event == nil {
+		event = availableEvents[r.Intn(len(availableEvents))]
+	}
+	rec.Plan(report.PlanEntry{Actor: "producer", Job: id, EventId: int(event.Id)})
+	return &TimedJobRequest{id: id, eventSource: event}
+}
+
 func init() {
 	stressCmd.AddCommand(timedCmd)
 	timedCmd.Flags().IntVar(&speed, "speed", 1, "Recording speed (<=0 means: go as fast as possible)")
 	timedCmd.Flags().StringVar(&testDurationArg, "duration", testDurationArg, "How long should the test run")
 	timedCmd.Flags().StringVar(&minSessionDuration, "min-session-duration", minSessionDuration, "the minimum session duration of the source")
-	timedCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action")
+	timedCmd.Flags().StringVarP(&internal.DataproviderPassword, "dataprovider-password", "p", "", "sets the Dataprovider password for this action (prefer RACELOG_DATAPROVIDER_PASSWORD, --password-file or --password-cmd)")
 	timedCmd.Flags().StringVar(&internal.SourceUrl, "source-url", "", "sets the url of the source server")
+	timedCmd.Flags().BoolVar(&useSynthetic, "synthetic", useSynthetic, "record synthetic races instead of copies of existing races (see --synth-* flags)")
 
 	// Here you will define your flags and configuration settings.
 
@@ -100,26 +127,43 @@ func setupTimedProducer() {
 	if len(source) == 0 {
 		source = internal.Url
 	}
-	pc := wamp.NewPublicClient(source, internal.Realm)
-	defer pc.Close()
-	minDuration, _ := time.ParseDuration(minSessionDuration)
-	availableEvents = computeAvailableEvents(pc, int(minDuration.Minutes()))
+	rec := newStressRecorder("timed")
+	rec.SetParam("speed", speed)
+	rec.SetParam("duration", testDurationArg)
+	rec.SetParam("synthetic", useSynthetic)
+	if useSynthetic {
+		setupSynthParams()
+	} else {
+		rec.SetParam("minSessionDuration", minSessionDuration)
+		pc := wamp.NewPublicClient(source, internal.Realm)
+		minDuration, _ := time.ParseDuration(minSessionDuration)
+		availableEvents = computeAvailableEvents(pc, int(minDuration.Minutes()))
+		pc.Close()
+		if len(availableEvents) == 0 {
+			log.Fatalf("No suitable source events found. Consider using --synthetic")
+		}
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	stressCtx, stop := stressContext()
+	defer stop()
+	ctx, cancel := context.WithCancel(stressCtx)
 	// setup worker for producer
 	wg := sync.WaitGroup{}
 	queue := make(chan *TimedJobRequest)
 	results := make(chan *TimedJobResult)
 
-	go timedResultCollector(queue, results, ctx)
+	go timedResultCollector(rec, queue, results, ctx)
 	for i := 0; i < internal.Worker; i++ {
 		wg.Add(1)
 		fmt.Printf("Starting worker %d\n", i)
-		go raceloggerWorker(i, queue, results, &wg, ctx)
+		go raceloggerWorker(i, rec, queue, results, &wg, ctx)
 	}
 
 	for jobId := 1; jobId <= internal.Worker; jobId++ {
-		queue <- &TimedJobRequest{id: jobId, eventSource: availableEvents[rand.Intn(len(availableEvents))]}
+		select {
+		case queue <- newTimedJobRequest(rec, jobId):
+		case <-ctx.Done():
+		}
 	}
 	nextJobId = internal.Worker + 1
 
@@ -127,7 +171,10 @@ func setupTimedProducer() {
 	go func() {
 		testDuration, _ := time.ParseDuration(testDurationArg)
 		log.Printf("Waiting %s to terminate worker\n", testDuration)
-		time.Sleep(testDuration)
+		select {
+		case <-time.After(testDuration):
+		case <-ctx.Done():
+		}
 		log.Printf("signalling cancel\n")
 		cancel()
 		log.Printf("signalled cancel\n")
@@ -136,13 +183,14 @@ func setupTimedProducer() {
 	log.Printf("Waiting for terminating jobs\n")
 	wg.Wait()
 	log.Printf("All workers finished\n")
+	finishStressRun(stressCtx, rec)
 
 	// handle producer finish
 	// done
 	log.Printf("All done\n")
 }
 
-func raceloggerWorker(idx int, requestChan chan *TimedJobRequest, resultChan chan *TimedJobResult, wg *sync.WaitGroup, ctx context.Context) {
+func raceloggerWorker(idx int, rec *report.Recorder, requestChan chan *TimedJobRequest, resultChan chan *TimedJobResult, wg *sync.WaitGroup, ctx context.Context) {
 	defer wg.Done()
 
 	source := internal.SourceUrl
@@ -151,7 +199,7 @@ func raceloggerWorker(idx int, requestChan chan *TimedJobRequest, resultChan cha
 	}
 
 	pc := wamp.NewPublicClient(source, internal.Realm)
-	dataprovider := wamp.NewDataProviderClient(internal.Url, internal.Realm, internal.DataproviderPassword)
+	dataprovider := wamp.NewDataProviderClient(internal.Url, internal.Realm, dataproviderCredentials())
 
 	defer pc.Close()
 	defer dataprovider.Close()
@@ -165,85 +213,155 @@ func raceloggerWorker(idx int, requestChan chan *TimedJobRequest, resultChan cha
 			log.Printf("test duration reached (outer) Terminating worker %d", idx)
 			return
 		case job := <-requestChan:
-			currentRun := 0
-			currentStateIdx := 0
-			currentSpeedmapIdx := 0
 			log.Printf("Worker %d got job %v\n", idx, job.output())
-			trackInfo, _ := pc.GetTrack(job.eventSource.Data.Info.TrackId)
-			registerMsg := createRegisterMessage(job.eventSource, trackInfo)
-			dataprovider.RegisterProvider(registerMsg)
-			recordingEventKey := registerMsg.EventKey
-			stateChannel := make(chan internal.State)
-			speedMapChannel := make(chan internal.SpeedmapMessage)
-			finalizeRecorder := func() {
-				dataprovider.UnregisterProvider(recordingEventKey)
-				resultChan <- &TimedJobResult{jobId: job.id, workerId: idx}
+			completed := recordTimedJob(ctx, idx, rec, pc, dataprovider, job, speed)
+			resultChan <- &TimedJobResult{jobId: job.id, workerId: idx}
+			if !completed {
+				return
 			}
+		}
 
-			dataprovider.PublishStateFromChannel(recordingEventKey, stateChannel)
-			dataprovider.PublishSpeedmapDataFromChannel(recordingEventKey, speedMapChannel)
-
-			from := job.eventSource.Data.ReplayInfo.MinTimestamp
-			states := pc.GetStates(int(job.eventSource.Id), from, numStates)
-			speedMaps, _ := pc.GetSpeedmaps(int(job.eventSource.Id), from, numSpeedMaps)
-			// log.Printf("Got %d speedmap entries\n", len(speedMaps))
-			carDataAvail := semver.MustParseRange(">=0.4.4")
-			if carDataAvail(semver.MustParse(util.GetEventRaceloggerVersion(job.eventSource))) {
-				carData, _ := pc.GetCarData(int(job.eventSource.Id))
-				dataprovider.PublishCarData(recordingEventKey, carData)
-			}
+	}
+}
 
-			hasMoreSpeedmapData := len(speedMaps) > 0 // at this point we know: there are speedmaps and may be even more
-			for goon := true; goon; {
-				select {
-				case <-ctx.Done():
-					log.Printf("test duration reached (inner). Terminating worker %d", idx)
-					finalizeRecorder()
-					return
-				default:
-					// log.Printf("Worker %d Iter %d\n", idx, currentRun)
-					stateChannel <- states[currentStateIdx]
-					if speed > 0 {
-						sleep := 1000 / speed
-						// fmt.Printf("Worker %d Sleeping for %+v ms\n", idx, sleep)
-						time.Sleep(time.Duration(sleep) * time.Millisecond)
-
-					}
-					if currentSpeedmapIdx < len(speedMaps) {
-
-						if speedMaps[currentSpeedmapIdx].Timestamp < states[currentStateIdx].Timestamp {
-							// log.Printf("Worker %d Iter %d Sending Speedmap %d\n", idx, currentRun, currentSpeedmapIdx)
-							speedMapChannel <- *speedMaps[currentSpeedmapIdx]
-							currentSpeedmapIdx++
-						}
-					} else {
-						if hasMoreSpeedmapData {
-							speedMaps, _ = pc.GetSpeedmaps(int(job.eventSource.Id), speedMaps[len(speedMaps)-1].Timestamp, numSpeedMaps)
-							hasMoreSpeedmapData = len(speedMaps) > 0
-							currentSpeedmapIdx = 0
-							// log.Printf("Worker %d Iter %d Reading %d new speedmap\n", idx, currentRun, len(speedMaps))
-						}
-					}
-
-					currentRun++
-					currentStateIdx++
-					goon = currentStateIdx < len(states)
-					// check if more states are available
-					if !goon {
-						from = states[len(states)-1].Timestamp + 0.0001
-						states = pc.GetStates(int(job.eventSource.Id), from, numStates)
-						// log.Printf("Worker %d get %d new states\n", idx, len(states))
-
-						goon = len(states) > 0
-						currentStateIdx = 0
-					}
-				}
-			}
+// recordTimedJob publishes the race of the job with the given speed.
+// Returns false if the context was cancelled while recording.
+func recordTimedJob(ctx context.Context, idx int, rec *report.Recorder, pc *wamp.PublicClient, dataprovider *wamp.DataProviderClient, job *TimedJobRequest, speed int) bool {
+	if job.generator != nil {
+		return recordSyntheticJob(ctx, idx, rec, dataprovider, job, speed)
+	}
+	return recordEventJob(ctx, idx, rec, pc, dataprovider, job, speed)
+}
 
-			log.Printf("Worker %d End of task. \n", idx)
+// recordEventJob publishes a copy of the archived event of the job.
+// Returns false if the context was cancelled while recording.
+func recordEventJob(ctx context.Context, idx int, rec *report.Recorder, pc *wamp.PublicClient, dataprovider *wamp.DataProviderClient, job *TimedJobRequest, speed int) bool {
+	var trackInfo *internal.TrackInfo
+	err := rec.Time(metricTrack, func() (err error) {
+		trackInfo, err = pc.GetTrack(job.eventSource.Data.Info.TrackId)
+		return err
+	})
+	if err != nil {
+		log.Printf("Worker %d: error getting track, skipping job: %v\n", idx, err)
+		return true
+	}
+	registerMsg := createRegisterMessage(job.eventSource, trackInfo)
+	if err := registerStressProvider(rec, dataprovider, registerMsg); err != nil {
+		log.Printf("Worker %d: error registering event, skipping job: %v\n", idx, err)
+		return true
+	}
+	recordingEventKey := registerMsg.EventKey
+	stateChannel := make(chan internal.State)
+	speedMapChannel := make(chan internal.SpeedmapMessage)
+	stateDone := dataprovider.PublishStateFromChannel(recordingEventKey, stateChannel)
+	speedmapDone := dataprovider.PublishSpeedmapDataFromChannel(recordingEventKey, speedMapChannel)
+	finalizeRecorder := func() {
+		close(stateChannel)
+		close(speedMapChannel)
+		waitPublished(rec, metricPublishState, stateDone)
+		waitPublished(rec, metricPublishSpeedmap, speedmapDone)
+		unregisterStressProvider(rec, dataprovider, recordingEventKey)
+	}
+
+	from := job.eventSource.Data.ReplayInfo.MinTimestamp
+	states := pc.StateIterator(int(job.eventSource.Id), from, numStates).
+		SetObserver(observeFetch(rec, metricStateFetch))
+	defer states.Close()
+	speedMaps := pc.SpeedmapIterator(int(job.eventSource.Id), from, numSpeedMaps).
+		SetObserver(observeFetch(rec, metricSpeedmapFetch))
+	defer speedMaps.Close()
+
+	carDataAvail := semver.MustParseRange(">=0.4.4")
+	if carDataAvail(semver.MustParse(util.GetEventRaceloggerVersion(job.eventSource))) {
+		var carData *internal.EventCarMessage
+		err := rec.Time(metricCarData, func() (err error) {
+			carData, err = pc.GetCarData(int(job.eventSource.Id))
+			return err
+		})
+		if err == nil {
+			publishStressCarData(dataprovider, recordingEventKey, carData)
+		}
+	}
+
+	hasSpeedmap := speedMaps.Next()
+	for states.Next() {
+		select {
+		case <-ctx.Done():
+			log.Printf("test duration reached (inner). Terminating worker %d", idx)
 			finalizeRecorder()
+			return false
+		default:
+			state := states.Value()
+			stateChannel <- liveState(rec, recordingEventKey, state)
+			rec.Count(metricPublishState)
+			if speed > 0 {
+				sleep := 1000 / speed
+				time.Sleep(time.Duration(sleep) * time.Millisecond)
+			}
+			if hasSpeedmap && speedMaps.Value().Timestamp < state.Timestamp {
+				speedMapChannel <- *speedMaps.Value()
+				rec.Count(metricPublishSpeedmap)
+				hasSpeedmap = speedMaps.Next()
+			}
 		}
+	}
 
+	log.Printf("Worker %d End of task. \n", idx)
+	finalizeRecorder()
+	return true
+}
+
+// recordSyntheticJob publishes the synthetic race of the job.
+// Returns false if the context was cancelled while recording.
+func recordSyntheticJob(ctx context.Context, idx int, rec *report.Recorder, dataprovider *wamp.DataProviderClient, job *TimedJobRequest, speed int) bool {
+	registerMsg := job.generator.RegisterMessage("")
+	registerMsg.Info.Name = fmt.Sprintf("stresstest-%s", time.Now().Format("20060102-150405"))
+	h := md5.New()
+	io.WriteString(h, registerMsg.Info.Name)
+	io.WriteString(h, uuid.New().String())
+	registerMsg.EventKey = fmt.Sprintf("%x", h.Sum(nil))
+	if err := registerStressProvider(rec, dataprovider, registerMsg); err != nil {
+		log.Printf("Worker %d: error registering event, skipping job: %v\n", idx, err)
+		return true
+	}
+	recordingEventKey := registerMsg.EventKey
+
+	stateChannel := make(chan internal.State)
+	speedMapChannel := make(chan internal.SpeedmapMessage)
+	stateDone := dataprovider.PublishStateFromChannel(recordingEventKey, stateChannel)
+	speedmapDone := dataprovider.PublishSpeedmapDataFromChannel(recordingEventKey, speedMapChannel)
+	publishStressCarData(dataprovider, recordingEventKey, job.generator.CarData())
+	finalizeRecorder := func() {
+		close(stateChannel)
+		close(speedMapChannel)
+		waitPublished(rec, metricPublishState, stateDone)
+		waitPublished(rec, metricPublishSpeedmap, speedmapDone)
+		unregisterStressProvider(rec, dataprovider, recordingEventKey)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("test duration reached (inner). Terminating worker %d", idx)
+			finalizeRecorder()
+			return false
+		default:
+			state, speedmap, ok := job.generator.Next()
+			if !ok {
+				log.Printf("Worker %d End of task. \n", idx)
+				finalizeRecorder()
+				return true
+			}
+			stateChannel <- liveState(rec, recordingEventKey, state)
+			rec.Count(metricPublishState)
+			if speedmap != nil {
+				speedMapChannel <- *speedmap
+				rec.Count(metricPublishSpeedmap)
+			}
+			if speed > 0 {
+				time.Sleep(time.Duration(1000/speed) * time.Millisecond)
+			}
+		}
 	}
 }
 
@@ -266,7 +384,7 @@ func createRegisterMessage(event *internal.Event, trackInfo *internal.TrackInfo)
 	return registerMsg
 }
 
-func timedResultCollector(requests chan *TimedJobRequest, results chan *TimedJobResult, ctx context.Context) {
+func timedResultCollector(rec *report.Recorder, requests chan *TimedJobRequest, results chan *TimedJobResult, ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -278,8 +396,14 @@ func timedResultCollector(requests chan *TimedJobRequest, results chan *TimedJob
 			return
 		case result, ok := <-results:
 			log.Printf("Got result: %v ok: %v\n", result, ok)
+			if ctx.Err() != nil {
+				continue
+			}
 			nextJobId++
-			requests <- &TimedJobRequest{id: nextJobId, eventSource: availableEvents[rand.Intn(len(availableEvents))]}
+			select {
+			case requests <- newTimedJobRequest(rec, nextJobId):
+			case <-ctx.Done():
+			}
 		}
 	}
 }
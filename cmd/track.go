@@ -0,0 +1,77 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// trackCmd represents the track command
+var trackCmd = &cobra.Command{
+	Use:   "track",
+	Short: "Commands regarding tracks",
+}
+
+func init() {
+	rootCmd.AddCommand(trackCmd)
+}
+
+// trackIdArg parses the track id argument
+func trackIdArg(args []string) int {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("Invalid track id %v: %v", args[0], err)
+	}
+	return id
+}
+
+func getTrack(url string, id int) *internal.TrackInfo {
+	pc := wamp.NewPublicClient(url, internal.Realm)
+	defer pc.Close()
+	track, err := pc.GetTrack(id)
+	if err != nil {
+		log.Fatalf("Error getting track %d from %s: %v\n", id, url, err)
+	}
+	return track
+}
+
+// trackJson returns the track info in the format of samples/track-<id>.json
+func trackJson(track *internal.TrackInfo) []byte {
+	jsonData, _ := json.MarshalIndent(track, "", "    ")
+	return jsonData
+}
+
+func printTrackJson(track *internal.TrackInfo) {
+	if internal.JsonPretty {
+		fmt.Printf("%s\n", trackJson(track))
+		return
+	}
+	jsonData, _ := json.Marshal(track)
+	fmt.Printf("%s\n", jsonData)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"racelogctl/internal"
+	"racelogctl/util"
+
+	"github.com/spf13/cobra"
+)
+
+// trackDiffCmd represents the track diff command
+var trackDiffCmd = &cobra.Command{
+	Use:   "diff <trackId>",
+	Short: "Compares the track info of two servers",
+	Long: `Compares the track info of two servers.
+
+The track info of --source-url is compared with the one of --url. 
+The command exits with status 1 if there are differences.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if internal.SourceUrl == "" {
+			log.Fatalf("--source-url is required\n")
+		}
+		id := trackIdArg(args)
+		source := getTrack(internal.SourceUrl, id)
+		target := getTrack(internal.Url, id)
+		diffs := util.DiffTracks(source, target)
+		if len(diffs) == 0 {
+			fmt.Printf("Track %d: no differences\n", id)
+			return
+		}
+		fmt.Printf("Track %d: %s (source) vs %s\n", id, internal.SourceUrl, internal.Url)
+		for _, d := range diffs {
+			fmt.Printf("  %s\n", d)
+		}
+		os.Exit(1)
+	},
+}
+
+func init() {
+	trackCmd.AddCommand(trackDiffCmd)
+
+	trackDiffCmd.Flags().StringVar(&internal.SourceUrl, "source-url", "", "sets the url of the server to compare with")
+}
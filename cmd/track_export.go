@@ -0,0 +1,67 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"racelogctl/internal"
+
+	"github.com/spf13/cobra"
+)
+
+var trackExportOutput string
+
+// trackExportCmd represents the track export command
+var trackExportCmd = &cobra.Command{
+	Use:   "export <trackId>",
+	Short: "Exports the track info as JSON in the format used by samples/track-<id>.json",
+	Long: `Exports the track info as JSON in the format used by samples/track-<id>.json.
+
+The file can be used by 'provider register --sample'. Use --output - to print 
+the data to stdout.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id := trackIdArg(args)
+		track := getTrack(internal.Url, id)
+		if trackExportOutput == "-" {
+			fmt.Printf("%s\n", trackJson(track))
+			return
+		}
+		output := trackExportOutput
+		if output == "" {
+			output = fmt.Sprintf("track-%d.json", id)
+		}
+		if err := os.WriteFile(output, trackJson(track), 0644); err != nil {
+			log.Fatalf("Error writing %s: %v\n", output, err)
+		}
+		fmt.Printf("Exported track %d to %s\n", id, output)
+	},
+}
+
+func init() {
+	trackCmd.AddCommand(trackExportCmd)
+
+	trackExportCmd.Flags().StringVar(&trackExportOutput, "output", "", "Output filename. (Default: track-<trackId>.json)")
+}
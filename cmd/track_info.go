@@ -0,0 +1,82 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"racelogctl/internal"
+
+	"github.com/spf13/cobra"
+)
+
+// trackInfoCmd represents the track info command
+var trackInfoCmd = &cobra.Command{
+	Use:   "info <trackId>",
+	Short: "Shows track information including sectors and pit lane",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		track := getTrack(internal.Url, trackIdArg(args))
+		switch internal.OutputFormat {
+		case "json":
+			printTrackJson(track)
+		default:
+			printTrack(track)
+		}
+	},
+}
+
+func init() {
+	trackCmd.AddCommand(trackInfoCmd)
+
+	trackInfoCmd.Flags().StringVarP(&internal.OutputFormat, "format", "f", "text", "Output format: text|json.")
+	trackInfoCmd.Flags().BoolVarP(&internal.JsonPretty, "pretty", "p", false, "use pretty json format. (Default: false)")
+}
+
+func printTrack(t *internal.TrackInfo) {
+	fmt.Printf(`Id: %d
+Name: %s (%s)
+Config: %s
+Length: %.0fm
+Pit entry: %.1f%% (%.0fm)
+Pit exit: %.1f%% (%.0fm)
+Pit lane length: %.0fm
+`,
+		t.TrackId,
+		t.TrackDisplayName, t.TrackDisplayShortName,
+		t.TrackConfigName,
+		t.TrackLength,
+		t.Pit.Entry*100, t.Pit.Entry*t.TrackLength,
+		t.Pit.Exit*100, t.Pit.Exit*t.TrackLength,
+		t.Pit.LaneLength)
+	if t.TrackPitSpeed > 0 {
+		fmt.Printf("Pit speed: %.0f km/h\n", t.TrackPitSpeed)
+	}
+	fmt.Printf("Sectors:\n")
+	for i, s := range t.Sectors {
+		end := 1.0
+		if i+1 < len(t.Sectors) {
+			end = t.Sectors[i+1].SectorStartPct
+		}
+		fmt.Printf("  S%d: %5.1f%% %6.0fm  length %5.0fm\n", i+1, s.SectorStartPct*100, s.SectorStartPct*t.TrackLength,
+			(end-s.SectorStartPct)*t.TrackLength)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2022 Markus Papenbrock
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"racelogctl/internal"
+	"racelogctl/wamp"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// trackListCmd represents the track list command
+var trackListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the tracks used by the events on the server",
+	Run: func(cmd *cobra.Command, args []string) {
+		listTracks()
+	},
+}
+
+func init() {
+	trackCmd.AddCommand(trackListCmd)
+}
+
+// trackUsage summarizes the events recorded on a track
+type trackUsage struct {
+	info      internal.EventInfo
+	events    int
+	lastEvent string
+}
+
+func listTracks() {
+	pc := wamp.NewPublicClient(internal.Url, internal.Realm)
+	defer pc.Close()
+	events, err := pc.GetEventList()
+	if err != nil {
+		log.Fatalf("Error getting events: %v\n", err)
+	}
+	tracks := map[int]*trackUsage{}
+	for _, e := range events {
+		t, ok := tracks[e.Data.Info.TrackId]
+		if !ok {
+			t = &trackUsage{info: e.Data.Info}
+			tracks[e.Data.Info.TrackId] = t
+		}
+		t.events++
+		if e.RecordDate > t.lastEvent {
+			t.lastEvent = e.RecordDate
+		}
+	}
+	ids := make([]int, 0, len(tracks))
+	for id := range tracks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	fmt.Printf("%5s  %-45s %-25s %8s %6s  %s\n", "Id", "Name", "Config", "Length", "Events", "Last event")
+	for _, id := range ids {
+		t := tracks[id]
+		last := t.lastEvent
+		if len(last) > 10 {
+			last = last[:10]
+		}
+		fmt.Printf("%5d  %-45s %-25s %7.0fm %6d  %s\n", id, truncate(t.info.TrackDisplayName, 45),
+			truncate(t.info.TrackConfigName, 25), t.info.TrackLength, t.events, last)
+	}
+}
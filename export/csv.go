@@ -0,0 +1,119 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"racelogctl/internal"
+	"strconv"
+)
+
+// TimelineOptions control which states are written by WriteTimelines
+type TimelineOptions struct {
+	Comma rune     // field separator (',' for csv, '\t' for tsv)
+	Every float64  // write at most one state per this many seconds (0: all states)
+	From  float64  // first session time to include (0: from start)
+	To    float64  // last session time to include (0: until end)
+	Cars  []string // car numbers to include (empty: all cars)
+}
+
+// WriteTimelines writes the reconstructed car rows as one line per (timestamp, carIdx) to cars
+// and the session rows as one line per timestamp to session. Headers are taken from the manifests.
+// The time window is based on the sessionTime column if present, otherwise on the timestamp.
+func WriteTimelines(cars, session io.Writer, data *Data, opts TimelineOptions) error {
+	manifests := data.Event.Data.Manifests
+	carWriter := newTimelineWriter(cars, opts.Comma)
+	sessionWriter := newTimelineWriter(session, opts.Comma)
+	if err := carWriter.Write(append([]string{"timestamp"}, manifests.Car...)); err != nil {
+		return err
+	}
+	if err := sessionWriter.Write(append([]string{"timestamp"}, manifests.Session...)); err != nil {
+		return err
+	}
+	carNumIdx := indexOf(manifests.Car, "carNum")
+	sessionTimeIdx := indexOf(manifests.Session, "sessionTime")
+	wantCar := map[string]bool{}
+	for _, c := range opts.Cars {
+		wantCar[c] = true
+	}
+
+	lastWritten := 0.0
+	first := true
+	err := data.States(func(s internal.State) error {
+		t := s.Timestamp
+		if sessionTimeIdx >= 0 && sessionTimeIdx < len(s.Payload.Session) {
+			if st, ok := s.Payload.Session[sessionTimeIdx].(float64); ok {
+				t = st
+			}
+		}
+		if t < opts.From || (opts.To > 0 && t > opts.To) {
+			return nil
+		}
+		if !first && opts.Every > 0 && s.Timestamp-lastWritten < opts.Every {
+			return nil
+		}
+		first = false
+		lastWritten = s.Timestamp
+		ts := formatValue(s.Timestamp)
+		for _, car := range s.Payload.Cars {
+			if len(wantCar) > 0 && (carNumIdx < 0 || carNumIdx >= len(car) || !wantCar[formatValue(car[carNumIdx])]) {
+				continue
+			}
+			if err := carWriter.Write(append([]string{ts}, formatRow(manifests.Car, car)...)); err != nil {
+				return err
+			}
+		}
+		if len(s.Payload.Session) > 0 {
+			return sessionWriter.Write(append([]string{ts}, formatRow(manifests.Session, s.Payload.Session)...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	carWriter.Flush()
+	sessionWriter.Flush()
+	if err := carWriter.Error(); err != nil {
+		return err
+	}
+	return sessionWriter.Error()
+}
+
+func newTimelineWriter(w io.Writer, comma rune) *csv.Writer {
+	ret := csv.NewWriter(w)
+	if comma != 0 {
+		ret.Comma = comma
+	}
+	return ret
+}
+
+func indexOf(list []string, item string) int {
+	for i, v := range list {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatValue converts a payload value to its text representation
+func formatValue(v interface{}) string {
+	switch val := scalar(v).(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		return val
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+func formatRow(manifest []string, values []interface{}) []string {
+	ret := make([]string, len(manifest))
+	for i, v := range row(manifest, values) {
+		ret[i] = formatValue(v)
+	}
+	return ret
+}
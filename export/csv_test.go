@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"racelogctl/internal"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func readTimeline(t *testing.T, data []byte, comma rune) [][]string {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestWriteTimelines(t *testing.T) {
+	data := testData()
+	numStates, numCarRows := 0, 0
+	data.States(func(s internal.State) error {
+		numStates++
+		numCarRows += len(s.Payload.Cars)
+		return nil
+	})
+
+	cars, session := bytes.Buffer{}, bytes.Buffer{}
+	if err := WriteTimelines(&cars, &session, data, TimelineOptions{Comma: '\t'}); err != nil {
+		t.Fatal(err)
+	}
+	carRecords := readTimeline(t, cars.Bytes(), '\t')
+	sessionRecords := readTimeline(t, session.Bytes(), '\t')
+	manifests := data.Event.Data.Manifests
+	if got := strings.Join(carRecords[0], ","); got != "timestamp,"+strings.Join(manifests.Car, ",") {
+		t.Errorf("unexpected car header %s", got)
+	}
+	if len(carRecords)-1 != numCarRows {
+		t.Errorf("car rows: got %d, want %d", len(carRecords)-1, numCarRows)
+	}
+	if len(sessionRecords)-1 != numStates {
+		t.Errorf("session rows: got %d, want %d", len(sessionRecords)-1, numStates)
+	}
+}
+
+func TestWriteTimelinesFiltered(t *testing.T) {
+	data := testData()
+	manifests := data.Event.Data.Manifests
+	cars, session := bytes.Buffer{}, bytes.Buffer{}
+	opts := TimelineOptions{Every: 10, From: 60, To: 120, Cars: []string{"1"}}
+	if err := WriteTimelines(&cars, &session, data, opts); err != nil {
+		t.Fatal(err)
+	}
+	carRecords := readTimeline(t, cars.Bytes(), ',')
+	sessionRecords := readTimeline(t, session.Bytes(), ',')
+	if len(sessionRecords) < 2 || len(sessionRecords)-1 > 7 {
+		t.Fatalf("expected at most one state per 10s in a 60s window, got %d", len(sessionRecords)-1)
+	}
+	sessionTimeIdx := indexOf(manifests.Session, "sessionTime") + 1
+	for _, r := range sessionRecords[1:] {
+		if st, _ := strconv.ParseFloat(r[sessionTimeIdx], 64); st < 60 || st > 120 {
+			t.Errorf("session time %s outside of window", r[sessionTimeIdx])
+		}
+	}
+	carNumIdx := indexOf(manifests.Car, "carNum") + 1
+	for _, r := range carRecords[1:] {
+		if r[carNumIdx] != "1" {
+			t.Errorf("unexpected car %s", r[carNumIdx])
+		}
+	}
+	if len(carRecords)-1 != len(sessionRecords)-1 {
+		t.Errorf("expected one car row per state, got %d cars for %d states", len(carRecords)-1, len(sessionRecords)-1)
+	}
+}
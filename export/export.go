@@ -0,0 +1,67 @@
+// Package export writes the archived data of an event into formats suitable for ad-hoc analysis.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"racelogctl/internal"
+)
+
+// Data is the source of an export. States and Speedmaps are called once and pass
+// all items in order to the emit function.
+type Data struct {
+	Event     *internal.Event
+	CarData   *internal.EventCarMessage // may be nil
+	States    func(emit func(internal.State) error) error
+	Speedmaps func(emit func(*internal.SpeedmapMessage) error) error // may be nil
+}
+
+// FromSlices creates export data from states and speedmaps already in memory
+func FromSlices(event *internal.Event, carData *internal.EventCarMessage, states []internal.State, speedmaps []*internal.SpeedmapMessage) *Data {
+	return &Data{
+		Event:   event,
+		CarData: carData,
+		States: func(emit func(internal.State) error) error {
+			for _, s := range states {
+				if err := emit(s); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Speedmaps: func(emit func(*internal.SpeedmapMessage) error) error {
+			for _, s := range speedmaps {
+				if err := emit(s); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// scalar converts a payload value to a value suitable for a single table cell.
+// Lists and maps (for example [value, marker] entries) are stored as json.
+func scalar(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, bool, float64, float32, int, int64, int32:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
+
+// row maps the values of a payload row to the manifest columns. Missing values are nil.
+func row(manifest []string, values []interface{}) []interface{} {
+	ret := make([]interface{}, len(manifest))
+	for i := range manifest {
+		if i < len(values) {
+			ret[i] = scalar(values[i])
+		}
+	}
+	return ret
+}
@@ -0,0 +1,31 @@
+package export
+
+import (
+	"racelogctl/internal"
+	"racelogctl/synth"
+	"time"
+)
+
+// testData creates export data of a short synthetic race
+func testData() *Data {
+	p := synth.DefaultParams()
+	p.Cars = 4
+	p.Classes = 2
+	p.RaceLength = 5 * time.Minute
+	p.LapTime = 60
+	p.StartTime = time.Date(2022, 8, 20, 8, 0, 0, 0, time.UTC)
+	g := synth.New(p)
+	states := []internal.State{}
+	speedmaps := []*internal.SpeedmapMessage{}
+	for {
+		s, sm, ok := g.Next()
+		if !ok {
+			break
+		}
+		states = append(states, s)
+		if sm != nil {
+			speedmaps = append(speedmaps, sm)
+		}
+	}
+	return FromSlices(g.Event(), g.CarData(), states, speedmaps)
+}
@@ -0,0 +1,227 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"racelogctl/internal"
+	"sort"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+// WriteSQLite writes the event into a new sqlite database. An existing file is replaced.
+//
+// Tables:
+//
+//	event, sessions, sectors                  event info
+//	car_classes, cars, entries, drivers       car data
+//	car_states                                one row per car and timestamp (columns from Manifests.Car)
+//	session_states                            one row per timestamp (columns from Manifests.Session)
+//	messages                                  race messages (columns from Manifests.Message)
+//	speedmaps                                 one row per timestamp, car class and chunk
+func WriteSQLite(filename string, data *Data) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, pragma := range []string{"PRAGMA journal_mode=OFF", "PRAGMA synchronous=OFF"} {
+		if _, err := db.Exec(pragma); err != nil {
+			return err
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	w := &sqliteWriter{tx: tx, manifests: data.Event.Data.Manifests}
+	steps := []func(*Data) error{w.writeEvent, w.writeCarData, w.writeStates, w.writeSpeedmaps}
+	for _, step := range steps {
+		if err := step(data); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type sqliteWriter struct {
+	tx        *sql.Tx
+	manifests internal.Manifests
+}
+
+// quote quotes an identifier
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// createTable creates the table with the columns (without types) and returns a prepared insert statement
+func (w *sqliteWriter) createTable(name string, columns []string) (*sql.Stmt, error) {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quote(c)
+	}
+	if _, err := w.tx.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", quote(name), strings.Join(quoted, ", "))); err != nil {
+		return nil, fmt.Errorf("create table %s: %w", name, err)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	return w.tx.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", quote(name), placeholders))
+}
+
+// insertAll creates the table and inserts the rows
+func (w *sqliteWriter) insertAll(name string, columns []string, rows [][]interface{}) error {
+	stmt, err := w.createTable(name, columns)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range rows {
+		if _, err := stmt.Exec(r...); err != nil {
+			return fmt.Errorf("insert into %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (w *sqliteWriter) writeEvent(data *Data) error {
+	e := data.Event
+	info := e.Data.Info
+	replay := e.Data.ReplayInfo
+	err := w.insertAll("event",
+		[]string{"id", "event_key", "name", "description", "record_date", "racelogger_version",
+			"track_id", "track_name", "track_config", "track_length", "multi_class", "team_racing",
+			"num_car_classes", "speedmap_interval", "min_timestamp", "min_session_time", "max_session_time"},
+		[][]interface{}{{e.Id, e.EventKey, e.Name, e.Description, e.RecordDate, info.RaceloggerVersion,
+			info.TrackId, info.TrackDisplayName, info.TrackConfigName, info.TrackLength, info.MultiClass, info.TeamRacing,
+			info.NumCarClasses, info.SpeedmapInterval, replay.MinTimestamp, replay.MinSessionTime, replay.MaxSessionTime}})
+	if err != nil {
+		return err
+	}
+	sessions := [][]interface{}{}
+	for _, s := range info.Sessions {
+		sessions = append(sessions, []interface{}{s.Num, s.Name, s.Type, s.Laps, s.Time})
+	}
+	if err := w.insertAll("sessions", []string{"num", "name", "type", "laps", "time"}, sessions); err != nil {
+		return err
+	}
+	sectors := [][]interface{}{}
+	for _, s := range info.Sectors {
+		sectors = append(sectors, []interface{}{s.SectorNum, s.SectorStartPct})
+	}
+	return w.insertAll("sectors", []string{"num", "start_pct"}, sectors)
+}
+
+func (w *sqliteWriter) writeCarData(data *Data) error {
+	cars := internal.EventCars{}
+	if data.CarData != nil {
+		cars = data.CarData.Payload
+	}
+	classes := [][]interface{}{}
+	for _, c := range cars.CarClasses {
+		classes = append(classes, []interface{}{c.Id, c.Name})
+	}
+	if err := w.insertAll("car_classes", []string{"id", "name"}, classes); err != nil {
+		return err
+	}
+	carRows := [][]interface{}{}
+	for _, c := range cars.Cars {
+		carRows = append(carRows, []interface{}{c.CarId, c.Name, c.NameShort, c.CarClassId, c.CarClassName,
+			c.FuelPct, c.PowerAdjust, c.WeightPenalty, c.DryTireSets})
+	}
+	if err := w.insertAll("cars", []string{"car_id", "name", "name_short", "car_class_id", "car_class_name",
+		"fuel_pct", "power_adjust", "weight_penalty", "dry_tire_sets"}, carRows); err != nil {
+		return err
+	}
+	entries := [][]interface{}{}
+	drivers := [][]interface{}{}
+	for _, e := range cars.Entries {
+		entries = append(entries, []interface{}{e.Car.CarIdx, e.Car.CarId, e.Car.CarClassId, e.Car.CarNumber,
+			e.Car.CarNumberRaw, e.Car.Name, e.Team.Id, e.Team.Name})
+		for _, d := range e.Drivers {
+			drivers = append(drivers, []interface{}{d.Id, e.Car.CarIdx, d.Name, d.AbbrevName, d.Initials,
+				d.IRating, d.LicLevel, d.LicSubLevel, d.LicString})
+		}
+	}
+	if err := w.insertAll("entries", []string{"car_idx", "car_id", "car_class_id", "car_number", "car_number_raw",
+		"car_name", "team_id", "team_name"}, entries); err != nil {
+		return err
+	}
+	return w.insertAll("drivers", []string{"id", "car_idx", "name", "abbrev_name", "initials",
+		"irating", "lic_level", "lic_sub_level", "lic_string"}, drivers)
+}
+
+func (w *sqliteWriter) writeStates(data *Data) error {
+	carStmt, err := w.createTable("car_states", append([]string{"timestamp"}, w.manifests.Car...))
+	if err != nil {
+		return err
+	}
+	defer carStmt.Close()
+	sessionStmt, err := w.createTable("session_states", append([]string{"timestamp"}, w.manifests.Session...))
+	if err != nil {
+		return err
+	}
+	defer sessionStmt.Close()
+	msgStmt, err := w.createTable("messages", append([]string{"timestamp"}, w.manifests.Message...))
+	if err != nil {
+		return err
+	}
+	defer msgStmt.Close()
+
+	insert := func(stmt *sql.Stmt, ts float64, manifest []string, values []interface{}) error {
+		_, err := stmt.Exec(append([]interface{}{ts}, row(manifest, values)...)...)
+		return err
+	}
+	return data.States(func(s internal.State) error {
+		for _, car := range s.Payload.Cars {
+			if err := insert(carStmt, s.Timestamp, w.manifests.Car, car); err != nil {
+				return err
+			}
+		}
+		if len(s.Payload.Session) > 0 {
+			if err := insert(sessionStmt, s.Timestamp, w.manifests.Session, s.Payload.Session); err != nil {
+				return err
+			}
+		}
+		for _, msg := range s.Payload.Messages {
+			if err := insert(msgStmt, s.Timestamp, w.manifests.Message, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (w *sqliteWriter) writeSpeedmaps(data *Data) error {
+	stmt, err := w.createTable("speedmaps", []string{"timestamp", "session_time", "time_of_day", "track_temp",
+		"current_pos", "chunk_size", "car_class", "laptime", "chunk", "speed"})
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if data.Speedmaps == nil {
+		return nil
+	}
+	return data.Speedmaps(func(s *internal.SpeedmapMessage) error {
+		p := s.Payload
+		classes := make([]string, 0, len(p.Data))
+		for c := range p.Data {
+			classes = append(classes, c)
+		}
+		sort.Strings(classes)
+		for _, c := range classes {
+			d := p.Data[c]
+			for chunk, speed := range d.ChunkSpeeds {
+				if _, err := stmt.Exec(s.Timestamp, p.SessionTime, p.TimeOfDay, p.TrackTemp,
+					p.CurrentPos, p.ChunkSize, c, d.Laptime, chunk, speed); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"database/sql"
+	"path/filepath"
+	"racelogctl/internal"
+	"testing"
+)
+
+func TestWriteSQLite(t *testing.T) {
+	data := testData()
+	filename := filepath.Join(t.TempDir(), "event.sqlite")
+	if err := WriteSQLite(filename, data); err != nil {
+		t.Fatal(err)
+	}
+	numStates, numCarRows, numMessages, numSpeedmapRows := 0, 0, 0, 0
+	data.States(func(s internal.State) error {
+		numStates++
+		numCarRows += len(s.Payload.Cars)
+		numMessages += len(s.Payload.Messages)
+		return nil
+	})
+	data.Speedmaps(func(s *internal.SpeedmapMessage) error {
+		for _, d := range s.Payload.Data {
+			numSpeedmapRows += len(d.ChunkSpeeds)
+		}
+		return nil
+	})
+
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	count := func(query string) int {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+	expect := map[string]int{
+		"SELECT count(*) FROM event":                          1,
+		"SELECT count(*) FROM sessions":                       len(data.Event.Data.Info.Sessions),
+		"SELECT count(*) FROM sectors":                        len(data.Event.Data.Info.Sectors),
+		"SELECT count(*) FROM car_classes":                    len(data.CarData.Payload.CarClasses),
+		"SELECT count(*) FROM entries":                        len(data.CarData.Payload.Entries),
+		"SELECT count(*) FROM car_states":                     numCarRows,
+		"SELECT count(*) FROM session_states":                 numStates,
+		"SELECT count(DISTINCT timestamp) FROM car_states":    numStates,
+		"SELECT count(*) FROM messages":                       numMessages,
+		"SELECT count(*) FROM speedmaps":                      numSpeedmapRows,
+		`SELECT count(*) FROM car_states WHERE "lap" IS NULL`: 0,
+	}
+	for query, want := range expect {
+		if got := count(query); got != want {
+			t.Errorf("%s: got %d, want %d", query, got, want)
+		}
+	}
+	if numMessages == 0 || numSpeedmapRows == 0 {
+		t.Errorf("test data should contain messages and speedmaps")
+	}
+}
@@ -24,5 +24,16 @@ var (
 	SourceUrl                  string // the target url for event copy
 	TargetDataproviderPassword string // the dataprovider password of the target when copying an event
 	RaceloggerVersion          string // minimum version of racelogger to be used for stress tests
+	Parallel                   int    // the number of parallel sessions used for full downloads
+	Serializer                 string // the WAMP serializer to use (json, msgpack, cbor)
+	TlsCaFile                  string // CA certificates (PEM) to verify the server for wss:// and tcps:// connections
+	TlsCertFile                string // client certificate (PEM) for wss:// and tcps:// connections
+	TlsKeyFile                 string // key of the client certificate (PEM)
+	TlsInsecure                bool   // skip the verification of the server certificate
+	Profile                    string // the config profile to use
+	AuthId                     string // the authid for admin and dataprovider commands
+	AuthMethod                 string // the authentication method for admin and dataprovider commands (ticket, wampcra, anonymous)
+	PasswordFile               string // file containing the password for admin and dataprovider commands
+	PasswordCmd                string // command printing the password for admin and dataprovider commands
 
 )
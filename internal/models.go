@@ -38,6 +38,7 @@ type TrackInfo struct {
 	TrackDisplayShortName string   `json:"trackDisplayShortName"`
 	TrackConfigName       string   `json:"trackConfigName"`
 	TrackLength           float64  `json:"trackLength"`
+	TrackPitSpeed         float64  `json:"trackPitSpeed,omitempty"`
 	Sectors               []Sector `json:"sectors"`
 	Pit                   struct {
 		Entry      float64 `json:"entry"`
@@ -0,0 +1,170 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Thresholds define when a change between two reports is considered a regression.
+// Negative values disable the check.
+type Thresholds struct {
+	Latency    float64 // max relative increase of avg/p50/p90/p99 latency (0.2 means +20%)
+	ErrorRate  float64 // max absolute increase of the error ratio (0.01 means +1 percentage point)
+	Rate       float64 // max relative decrease of the rate (0.2 means -20%)
+	MinSamples int     // metrics with less samples (in one of the reports) are not checked for latency regressions
+}
+
+// DefaultThresholds returns the thresholds used if nothing else is configured
+func DefaultThresholds() Thresholds {
+	return Thresholds{Latency: 0.2, ErrorRate: 0.01, Rate: -1, MinSamples: 5}
+}
+
+// Diff describes the change of a single value of a metric
+type Diff struct {
+	Group      string  // empty for the overall metrics
+	Name       string  // name of the metric
+	Field      string  // compared value (for example p90)
+	Old        float64 // value in the old report (latencies in ms)
+	New        float64 // value in the new report (latencies in ms)
+	Change     float64 // relative change (New-Old)/Old, absolute change for ratios
+	Regression bool
+	Missing    string // "old" or "new" if the metric exists in only one report
+}
+
+// Compare compares all metrics of the new report with the old one
+func Compare(old, current *Report, th Thresholds) []Diff {
+	ret := compareMetrics("", old.Metrics, current.Metrics, th)
+	groups := map[string]bool{}
+	for g := range old.Groups {
+		groups[g] = true
+	}
+	for g := range current.Groups {
+		groups[g] = true
+	}
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	for _, g := range names {
+		ret = append(ret, compareMetrics(g, old.Groups[g], current.Groups[g], th)...)
+	}
+	return ret
+}
+
+// HasRegression returns true if at least one diff is a regression
+func HasRegression(diffs []Diff) bool {
+	for _, d := range diffs {
+		if d.Regression {
+			return true
+		}
+	}
+	return false
+}
+
+func compareMetrics(group string, old, current []Metric, th Thresholds) []Diff {
+	oldLookup := map[string]Metric{}
+	for _, m := range old {
+		oldLookup[m.Name] = m
+	}
+	newLookup := map[string]Metric{}
+	for _, m := range current {
+		newLookup[m.Name] = m
+	}
+	ret := []Diff{}
+	for _, o := range old {
+		n, ok := newLookup[o.Name]
+		if !ok {
+			ret = append(ret, Diff{Group: group, Name: o.Name, Missing: "new"})
+			continue
+		}
+		ret = append(ret, compareMetric(group, o, n, th)...)
+	}
+	for _, n := range current {
+		if _, ok := oldLookup[n.Name]; !ok {
+			ret = append(ret, Diff{Group: group, Name: n.Name, Missing: "old"})
+		}
+	}
+	return ret
+}
+
+func compareMetric(group string, o, n Metric, th Thresholds) []Diff {
+	ret := []Diff{}
+	checkLatency := th.Latency >= 0 && o.Samples >= th.MinSamples && n.Samples >= th.MinSamples && o.Samples > 0
+	latencies := []struct {
+		field        string
+		old, current time.Duration
+	}{
+		{"avg", o.Avg, n.Avg},
+		{"p50", o.P50, n.P50},
+		{"p90", o.P90, n.P90},
+		{"p99", o.P99, n.P99},
+	}
+	for _, l := range latencies {
+		d := Diff{Group: group, Name: o.Name, Field: l.field, Old: ms(l.old), New: ms(l.current), Change: relChange(ms(l.old), ms(l.current))}
+		d.Regression = checkLatency && d.Change > th.Latency
+		ret = append(ret, d)
+	}
+
+	d := Diff{Group: group, Name: o.Name, Field: "errors", Old: errorRatio(o), New: errorRatio(n)}
+	d.Change = d.New - d.Old
+	d.Regression = th.ErrorRate >= 0 && d.Change > th.ErrorRate
+	ret = append(ret, d)
+
+	if o.Rate > 0 || n.Rate > 0 {
+		d := Diff{Group: group, Name: o.Name, Field: "rate", Old: o.Rate, New: n.Rate, Change: relChange(o.Rate, n.Rate)}
+		d.Regression = th.Rate >= 0 && o.Rate > 0 && -d.Change > th.Rate
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func relChange(old, current float64) float64 {
+	if old == 0 {
+		return 0
+	}
+	return (current - old) / old
+}
+
+// errorRatio returns the ratio of errors to all operations of the metric
+func errorRatio(m Metric) float64 {
+	if m.Count+m.Errors == 0 {
+		return 0
+	}
+	return float64(m.Errors) / float64(m.Count+m.Errors)
+}
+
+// PrintDiffs writes the diffs as text. If all is false only regressions and missing metrics are printed.
+func PrintDiffs(w io.Writer, diffs []Diff, all bool) {
+	for _, d := range diffs {
+		if !all && !d.Regression && d.Missing == "" {
+			continue
+		}
+		name := d.Name
+		if d.Group != "" {
+			name = fmt.Sprintf("%s/%s", d.Group, d.Name)
+		}
+		if d.Missing != "" {
+			fmt.Fprintf(w, "   %-40s missing in %s report\n", name, d.Missing)
+			continue
+		}
+		marker := "  "
+		if d.Regression {
+			marker = "!!"
+		}
+		switch d.Field {
+		case "errors":
+			fmt.Fprintf(w, "%s %-40s %-6s %9.2f%% -> %9.2f%% (%+.2f pp)\n", marker, name, d.Field, d.Old*100, d.New*100, d.Change*100)
+		case "rate":
+			fmt.Fprintf(w, "%s %-40s %-6s %9.2f/s -> %9.2f/s (%+.1f%%)\n", marker, name, d.Field, d.Old, d.New, d.Change*100)
+		default:
+			fmt.Fprintf(w, "%s %-40s %-6s %9.1fms -> %9.1fms (%+.1f%%)\n", marker, name, d.Field, d.Old, d.New, d.Change*100)
+		}
+	}
+}
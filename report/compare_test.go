@@ -0,0 +1,79 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	old := &Report{
+		Metrics: []Metric{
+			{Name: "fetch", Count: 100, Samples: 100, Avg: 10 * time.Millisecond, P50: 10 * time.Millisecond, P90: 20 * time.Millisecond, P99: 30 * time.Millisecond},
+			{Name: "gone", Count: 1},
+		},
+		Groups: map[string][]Metric{"events": {{Name: "Event: 1-Test", Count: 10, Samples: 10, P90: time.Second}}},
+	}
+	new := &Report{
+		Metrics: []Metric{
+			{Name: "fetch", Count: 95, Errors: 5, Samples: 95, Avg: 11 * time.Millisecond, P50: 10 * time.Millisecond, P90: 30 * time.Millisecond, P99: 30 * time.Millisecond},
+		},
+		Groups: map[string][]Metric{"events": {{Name: "Event: 1-Test", Count: 10, Samples: 10, P90: time.Second}}},
+	}
+	diffs := Compare(old, new, DefaultThresholds())
+	if !HasRegression(diffs) {
+		t.Fatalf("expected regression in %+v", diffs)
+	}
+	regressions := map[string]bool{}
+	missing := 0
+	for _, d := range diffs {
+		if d.Regression {
+			regressions[d.Group+"/"+d.Name+"/"+d.Field] = true
+		}
+		if d.Missing != "" {
+			missing++
+		}
+	}
+	want := map[string]bool{"/fetch/p90": true, "/fetch/errors": true}
+	if len(regressions) != len(want) {
+		t.Errorf("Compare() regressions = %v, want %v", regressions, want)
+	}
+	for k := range want {
+		if !regressions[k] {
+			t.Errorf("Compare() missing regression %v", k)
+		}
+	}
+	if missing != 1 {
+		t.Errorf("Compare() missing metrics = %d, want 1", missing)
+	}
+
+	if HasRegression(Compare(old, old, DefaultThresholds())) {
+		t.Errorf("report should not regress against itself")
+	}
+}
+
+func TestReadLegacyBrowserReport(t *testing.T) {
+	data := `{"events":[{"id":1,"num":2,"numStates":10,"numFetches":4,"durations":[1000000,3000000],
+	"durationStats":{"min":1000000,"max":3000000,"avg":2000000,"sum":4000000},"name":"Event: 1-Test"}],
+	"workers":[{"id":1,"num":2,"numStates":10,"numFetches":4,"durations":[1000000,3000000],
+	"durationStats":{"min":1000000,"max":3000000,"avg":2000000,"sum":4000000},"name":"Worker: 1"}]}`
+	filename := filepath.Join(t.TempDir(), "legacy.json")
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := ReadJSON(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Mode != "browser" || len(rep.Groups["events"]) != 1 || len(rep.Groups["workers"]) != 1 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	e := rep.Groups["events"][0]
+	if e.Name != "Event: 1-Test" || e.Count != 2 || e.Avg != 2*time.Millisecond || e.P99 != 3*time.Millisecond || e.Counters["states"] != 10 {
+		t.Errorf("unexpected event metric %+v", e)
+	}
+	if len(rep.Metrics) != 1 || rep.Metrics[0].Samples != 2 {
+		t.Errorf("unexpected metrics %+v", rep.Metrics)
+	}
+}
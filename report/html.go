@@ -0,0 +1,135 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+const barWidth = 12
+const chartHeight = 80
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms":   formatMs,
+	"bars": bars,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>stress {{.Mode}} {{.Start.Format "2006-01-02 15:04:05"}}</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+svg rect { fill: #4682b4; }
+</style>
+</head>
+<body>
+<h1>stress {{.Mode}}</h1>
+<p>Start: {{.Start.Format "2006-01-02 15:04:05"}} Duration: {{.Duration}}</p>
+{{if .Params}}<table>{{range $k, $v := .Params}}<tr><td>{{$k}}</td><td>{{$v}}</td></tr>{{end}}</table>{{end}}
+{{define "metrics"}}
+<table>
+<tr><th>Name</th><th>Count</th><th>Errors</th><th>Rate/s</th><th>Min</th><th>Avg</th><th>P50</th><th>P90</th><th>P99</th><th>Max</th><th>Counters</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.Errors}}</td><td>{{printf "%.2f" .Rate}}</td><td>{{ms .Min}}</td><td>{{ms .Avg}}</td><td>{{ms .P50}}</td><td>{{ms .P90}}</td><td>{{ms .P99}}</td><td>{{ms .Max}}</td><td>{{range $k, $v := .Counters}}{{$k}}: {{$v}} {{end}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Metrics</h2>
+{{template "metrics" .Metrics}}
+{{range .Metrics}}
+<h3>{{.Name}}</h3>
+{{if .Histogram}}<p>Latency histogram (ms)</p>{{bars .Histogram}}{{end}}
+{{if .Throughput}}<p>Throughput per {{$.Interval}}</p>{{bars .Throughput}}{{end}}
+{{end}}
+{{range $name, $metrics := .Groups}}
+<h2>{{$name}}</h2>
+{{template "metrics" $metrics}}
+{{end}}
+</body>
+</html>
+`))
+
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+}
+
+// bars renders histogram buckets or throughput points as a simple svg bar chart
+func bars(data interface{}) template.HTML {
+	labels := []string{}
+	values := []int{}
+	switch v := data.(type) {
+	case []Bucket:
+		for _, b := range v {
+			if b.UpperBound == 0 {
+				labels = append(labels, "more")
+			} else {
+				labels = append(labels, "<="+formatMs(b.UpperBound))
+			}
+			values = append(values, b.Count)
+		}
+	case []ThroughputPoint:
+		for _, p := range v {
+			labels = append(labels, p.Offset.String())
+			values = append(values, p.Count)
+		}
+	}
+	maxValue := 1
+	for _, v := range values {
+		if v > maxValue {
+			maxValue = v
+		}
+	}
+	svg := fmt.Sprintf(`<svg width="%d" height="%d">`, len(values)*barWidth+2, chartHeight)
+	for i, v := range values {
+		h := v * chartHeight / maxValue
+		svg += fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d"><title>%s: %d</title></rect>`,
+			i*barWidth, chartHeight-h, barWidth-2, h, template.HTMLEscapeString(labels[i]), v)
+	}
+	svg += "</svg>"
+	return template.HTML(svg)
+}
+
+// WriteHTML writes the report as standalone html page to the file
+func (rep *Report) WriteHTML(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return htmlTemplate.Execute(f, rep)
+}
+
+// Print writes a text summary of the report
+func (rep *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nStress %s report (duration %s)\n", rep.Mode, rep.Duration.Round(time.Second))
+	printMetrics(w, rep.Metrics)
+	names := make([]string, 0, len(rep.Groups))
+	for name := range rep.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "\nSummary by %s\n", name)
+		printMetrics(w, rep.Groups[name])
+	}
+}
+
+func printMetrics(w io.Writer, metrics []Metric) {
+	for _, m := range metrics {
+		fmt.Fprintf(w, "%-30s Num: %6d Err: %4d Rate: %7.2f/s P50: %8sms P90: %8sms P99: %8sms Max: %8sms",
+			m.Name, m.Count, m.Errors, m.Rate, formatMs(m.P50), formatMs(m.P90), formatMs(m.P99), formatMs(m.Max))
+		keys := make([]string, 0, len(m.Counters))
+		for k := range m.Counters {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, " %s: %d", k, m.Counters[k])
+		}
+		fmt.Fprintln(w)
+	}
+}
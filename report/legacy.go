@@ -0,0 +1,72 @@
+package report
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// legacySummary is an entry of the json file written by 'stress browser' before the common report format
+type legacySummary struct {
+	Id            int             `json:"id"`
+	Num           int             `json:"num"`
+	NumStates     int             `json:"numStates"`
+	NumFetches    int             `json:"numFetches"`
+	Durations     []time.Duration `json:"durations"`
+	DurationStats struct {
+		Min time.Duration `json:"min"`
+		Max time.Duration `json:"max"`
+		Avg time.Duration `json:"avg"`
+		Sum time.Duration `json:"sum"`
+	} `json:"durationStats"`
+	Name string `json:"name"`
+}
+
+type legacyBrowserReport struct {
+	Mode    string          `json:"mode"`
+	Events  []legacySummary `json:"events"`
+	Workers []legacySummary `json:"workers"`
+}
+
+// fromLegacy converts the json data of the old 'stress browser' output into a report.
+// Returns false if the data is not in the legacy format.
+func fromLegacy(data []byte) (*Report, bool) {
+	legacy := legacyBrowserReport{}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, false
+	}
+	if legacy.Mode != "" || (legacy.Events == nil && legacy.Workers == nil) {
+		return nil, false
+	}
+	ret := &Report{
+		Mode:   "browser",
+		Groups: map[string][]Metric{"events": legacyMetrics(legacy.Events), "workers": legacyMetrics(legacy.Workers)},
+	}
+	// the legacy format has no overall metrics. The job durations of all events are the closest match.
+	all := []time.Duration{}
+	for _, s := range legacy.Events {
+		all = append(all, s.Durations...)
+	}
+	if len(all) > 0 {
+		ret.Metrics = []Metric{fromSamples("job", all)}
+	}
+	return ret, true
+}
+
+func legacyMetrics(items []legacySummary) []Metric {
+	ret := make([]Metric, 0, len(items))
+	for _, s := range items {
+		m := fromSamples(s.Name, s.Durations)
+		m.Count = s.Num
+		m.Counters = map[string]int{"fetches": s.NumFetches, "states": s.NumStates}
+		if len(s.Durations) == 0 {
+			m.Min, m.Max, m.Avg, m.Sum = s.DurationStats.Min, s.DurationStats.Max, s.DurationStats.Avg, s.DurationStats.Sum
+		}
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// fromSamples evaluates latency samples without throughput information
+func fromSamples(name string, samples []time.Duration) Metric {
+	return evaluate(name, &metric{count: len(samples), samples: samples}, 0, time.Second)
+}
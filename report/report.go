@@ -0,0 +1,393 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// upper bounds of the latency histogram buckets. The last bucket collects everything above.
+var histogramBounds = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+type metric struct {
+	count    int
+	errors   int
+	samples  []time.Duration
+	timeline map[int]int // throughput bucket -> count
+	counters map[string]int
+}
+
+// Recorder collects latencies, throughput and errors of named metrics during a stress run.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu       sync.Mutex
+	mode     string
+	start    time.Time
+	interval time.Duration
+	metrics  map[string]*metric
+	groups   map[string]map[string]*metric
+	params   map[string]interface{}
+	seed     int64
+	plan     []PlanEntry
+	sent     map[string]time.Time // send time of messages by key, see MarkSent
+}
+
+// sentRetention is the time the send time of a message is kept
+const sentRetention = 5 * time.Minute
+
+// NewRecorder creates a recorder for the stress mode. Throughput is aggregated per interval.
+func NewRecorder(mode string, interval time.Duration) *Recorder {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &Recorder{
+		mode:     mode,
+		start:    time.Now(),
+		interval: interval,
+		metrics:  map[string]*metric{},
+		groups:   map[string]map[string]*metric{},
+		params:   map[string]interface{}{},
+		sent:     map[string]time.Time{},
+	}
+}
+
+func (r *Recorder) lookup(group, name string) *metric {
+	metrics := r.metrics
+	if group != "" {
+		if _, ok := r.groups[group]; !ok {
+			r.groups[group] = map[string]*metric{}
+		}
+		metrics = r.groups[group]
+	}
+	m, ok := metrics[name]
+	if !ok {
+		m = &metric{timeline: map[int]int{}, counters: map[string]int{}}
+		metrics[name] = m
+	}
+	return m
+}
+
+func (r *Recorder) tick(m *metric) {
+	m.count++
+	m.timeline[int(time.Since(r.start)/r.interval)]++
+}
+
+// Observe records a latency sample for the metric
+func (r *Recorder) Observe(name string, d time.Duration) {
+	r.ObserveIn("", name, d)
+}
+
+// ObserveIn records a latency sample for the metric within a group (for example per event or per worker)
+func (r *Recorder) ObserveIn(group, name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.lookup(group, name)
+	r.tick(m)
+	m.samples = append(m.samples, d)
+}
+
+// Count records an occurrence of the metric without a latency (used for throughput only)
+func (r *Recorder) Count(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tick(r.lookup("", name))
+}
+
+// AddIn adds n to a named counter of the metric within a group (for example the number of fetched states)
+func (r *Recorder) AddIn(group, name, counter string, n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookup(group, name).counters[counter] += n
+}
+
+// Error records a failed operation for the metric
+func (r *Recorder) Error(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookup("", name).errors++
+}
+
+// Time runs f and records its duration for the metric. If f returns an error, it is recorded as error.
+func (r *Recorder) Time(name string, f func() error) error {
+	start := time.Now()
+	err := f()
+	if err != nil {
+		r.Error(name)
+		return err
+	}
+	r.Observe(name, time.Since(start))
+	return nil
+}
+
+// SetParam stores a parameter of the run which will be included in the report
+func (r *Recorder) SetParam(key string, value interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.params[key] = value
+}
+
+// SetSeed stores the seed of the random generators used in the run
+func (r *Recorder) SetSeed(seed int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seed = seed
+}
+
+// PlanEntry records a random decision of a stress run (for example the event picked for a job).
+// The plan of a report may be used to replay the run with the same decisions.
+type PlanEntry struct {
+	Actor    string        `json:"actor"` // producer, listener or browser
+	Job      int           `json:"job"`
+	Offset   time.Duration `json:"offset"` // time since the start of the run
+	EventId  int           `json:"eventId,omitempty"`
+	EventKey string        `json:"eventKey,omitempty"`
+	Seed     int64         `json:"seed,omitempty"`     // seed of a synthetic race
+	Duration time.Duration `json:"duration,omitempty"` // listen duration
+}
+
+// Plan records an entry of the job plan
+func (r *Recorder) Plan(e PlanEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.Offset = time.Since(r.start)
+	r.plan = append(r.plan, e)
+}
+
+// Bucket is a histogram bucket. Count holds the samples <= UpperBound (and above the previous bound).
+// The last bucket has an UpperBound of 0 meaning "above the previous bound".
+type Bucket struct {
+	UpperBound time.Duration `json:"upperBound"`
+	Count      int           `json:"count"`
+}
+
+// ThroughputPoint holds the number of occurrences in the interval beginning at Offset
+type ThroughputPoint struct {
+	Offset time.Duration `json:"offset"`
+	Count  int           `json:"count"`
+}
+
+// Metric is the evaluated data of a named metric
+type Metric struct {
+	Name       string            `json:"name"`
+	Count      int               `json:"count"`
+	Errors     int               `json:"errors"`
+	Samples    int               `json:"samples"`
+	Min        time.Duration     `json:"min"`
+	Max        time.Duration     `json:"max"`
+	Avg        time.Duration     `json:"avg"`
+	Sum        time.Duration     `json:"sum"`
+	P50        time.Duration     `json:"p50"`
+	P90        time.Duration     `json:"p90"`
+	P99        time.Duration     `json:"p99"`
+	Rate       float64           `json:"rate"` // occurrences per second over the whole run
+	Counters   map[string]int    `json:"counters,omitempty"`
+	Histogram  []Bucket          `json:"histogram,omitempty"`
+	Throughput []ThroughputPoint `json:"throughput,omitempty"`
+}
+
+// Report is the common report format of all stress modes
+type Report struct {
+	Mode     string                 `json:"mode"`
+	Start    time.Time              `json:"start"`
+	End      time.Time              `json:"end"`
+	Duration time.Duration          `json:"duration"`
+	Interval time.Duration          `json:"interval"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+	Seed     int64                  `json:"seed,omitempty"`
+	Plan     []PlanEntry            `json:"plan,omitempty"`
+	Metrics  []Metric               `json:"metrics"`
+	Groups   map[string][]Metric    `json:"groups,omitempty"`
+}
+
+// Lookup returns the plan entry of the actor for the job
+func (rep *Report) Lookup(actor string, job int) (PlanEntry, bool) {
+	for _, e := range rep.Plan {
+		if e.Actor == actor && e.Job == job {
+			return e, true
+		}
+	}
+	return PlanEntry{}, false
+}
+
+// MarkSent records the current time as the send time of the message identified by key.
+// Receivers use SentAt to compute the publish-to-receive latency.
+func (r *Recorder) MarkSent(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if len(r.sent) >= 10000 {
+		for k, t := range r.sent {
+			if now.Sub(t) > sentRetention {
+				delete(r.sent, k)
+			}
+		}
+	}
+	r.sent[key] = now
+}
+
+// SentAt returns the send time of the message identified by key.
+// Returns false if the message was not sent by this recorder (or too long ago).
+func (r *Recorder) SentAt(key string) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.sent[key]
+	return t, ok
+}
+
+// Report evaluates the data collected so far
+func (r *Recorder) Report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	end := time.Now()
+	ret := &Report{
+		Mode:     r.mode,
+		Start:    r.start,
+		End:      end,
+		Duration: end.Sub(r.start),
+		Interval: r.interval,
+		Params:   map[string]interface{}{},
+		Seed:     r.seed,
+		Plan:     append([]PlanEntry{}, r.plan...),
+		Metrics:  evaluateAll(r.metrics, end.Sub(r.start), r.interval),
+		Groups:   map[string][]Metric{},
+	}
+	for k, v := range r.params {
+		ret.Params[k] = v
+	}
+	sort.SliceStable(ret.Plan, func(i, j int) bool {
+		if ret.Plan[i].Actor != ret.Plan[j].Actor {
+			return ret.Plan[i].Actor < ret.Plan[j].Actor
+		}
+		return ret.Plan[i].Job < ret.Plan[j].Job
+	})
+	for group, metrics := range r.groups {
+		ret.Groups[group] = evaluateAll(metrics, end.Sub(r.start), r.interval)
+	}
+	return ret
+}
+
+func evaluateAll(metrics map[string]*metric, total time.Duration, interval time.Duration) []Metric {
+	ret := make([]Metric, 0, len(metrics))
+	for name, m := range metrics {
+		ret = append(ret, evaluate(name, m, total, interval))
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func evaluate(name string, m *metric, total time.Duration, interval time.Duration) Metric {
+	ret := Metric{Name: name, Count: m.count, Errors: m.errors, Samples: len(m.samples)}
+	if len(m.counters) > 0 {
+		ret.Counters = map[string]int{}
+		for k, v := range m.counters {
+			ret.Counters[k] = v
+		}
+	}
+	if total > 0 {
+		ret.Rate = float64(m.count) / total.Seconds()
+	}
+	if len(m.timeline) > 0 {
+		last := 0
+		for k := range m.timeline {
+			if k > last {
+				last = k
+			}
+		}
+		for i := 0; i <= last; i++ {
+			ret.Throughput = append(ret.Throughput, ThroughputPoint{Offset: time.Duration(i) * interval, Count: m.timeline[i]})
+		}
+	}
+	if len(m.samples) == 0 {
+		return ret
+	}
+	sorted := make([]time.Duration, len(m.samples))
+	copy(sorted, m.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	ret.Min = sorted[0]
+	ret.Max = sorted[len(sorted)-1]
+	for _, d := range sorted {
+		ret.Sum += d
+	}
+	ret.Avg = ret.Sum / time.Duration(len(sorted))
+	ret.P50 = Percentile(sorted, 50)
+	ret.P90 = Percentile(sorted, 90)
+	ret.P99 = Percentile(sorted, 99)
+	ret.Histogram = histogram(sorted)
+	return ret
+}
+
+// Percentile returns the p-th percentile (nearest rank) of the sorted samples
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func histogram(sorted []time.Duration) []Bucket {
+	ret := make([]Bucket, len(histogramBounds)+1)
+	for i, b := range histogramBounds {
+		ret[i].UpperBound = b
+	}
+	idx := 0
+	for _, d := range sorted {
+		for idx < len(histogramBounds) && d > histogramBounds[idx] {
+			idx++
+		}
+		ret[idx].Count++
+	}
+	return ret
+}
+
+// WriteJSON writes the report as json to the file
+func (rep *Report) WriteJSON(filename string) error {
+	jsonData, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, jsonData, 0644)
+}
+
+// ReadJSON reads a report written by WriteJSON.
+// The json files written by 'stress browser' before the common report format are also accepted.
+func ReadJSON(filename string) (*Report, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if rep, ok := fromLegacy(data); ok {
+		return rep, nil
+	}
+	rep := &Report{}
+	if err := json.Unmarshal(data, rep); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return rep, nil
+}
@@ -0,0 +1,125 @@
+package report
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		name string
+		data []time.Duration
+		p    float64
+		want time.Duration
+	}{
+		{name: "empty", data: nil, p: 50, want: 0},
+		{name: "single", data: []time.Duration{5}, p: 99, want: 5},
+		{name: "p50", data: sorted, p: 50, want: 50 * time.Millisecond},
+		{name: "p90", data: sorted, p: 90, want: 90 * time.Millisecond},
+		{name: "p99", data: sorted, p: 99, want: 99 * time.Millisecond},
+		{name: "p0", data: sorted, p: 0, want: 1 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Percentile(tt.data, tt.p); got != tt.want {
+				t.Errorf("Percentile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	got := histogram([]time.Duration{500 * time.Microsecond, time.Millisecond, 3 * time.Millisecond, 2 * time.Hour})
+	if got[0].Count != 2 || got[2].Count != 1 || got[len(got)-1].Count != 1 {
+		t.Errorf("histogram() = %v", got)
+	}
+	if got[len(got)-1].UpperBound != 0 {
+		t.Errorf("last bucket should be unbounded: %v", got[len(got)-1])
+	}
+}
+
+func TestRecorderReport(t *testing.T) {
+	r := NewRecorder("test", time.Second)
+	r.Observe("fetch", 10*time.Millisecond)
+	r.Observe("fetch", 30*time.Millisecond)
+	r.Error("fetch")
+	r.Count("publish")
+	r.ObserveIn("workers", "Worker: 1", 20*time.Millisecond)
+	r.SetParam("worker", 1)
+	r.SetSeed(1234567890123456789)
+	r.Plan(PlanEntry{Actor: "producer", Job: 2, EventId: 17})
+	r.Plan(PlanEntry{Actor: "listener", Job: 1, EventKey: "abc", Duration: time.Minute})
+
+	rep := r.Report()
+	if len(rep.Metrics) != 2 || rep.Metrics[0].Name != "fetch" || rep.Metrics[1].Name != "publish" {
+		t.Fatalf("unexpected metrics %+v", rep.Metrics)
+	}
+	fetch := rep.Metrics[0]
+	if fetch.Count != 2 || fetch.Errors != 1 || fetch.Min != 10*time.Millisecond || fetch.Max != 30*time.Millisecond || fetch.Avg != 20*time.Millisecond {
+		t.Errorf("unexpected fetch metric %+v", fetch)
+	}
+	if rep.Metrics[1].Samples != 0 || rep.Metrics[1].Count != 1 {
+		t.Errorf("unexpected publish metric %+v", rep.Metrics[1])
+	}
+	if len(rep.Groups["workers"]) != 1 {
+		t.Errorf("unexpected groups %+v", rep.Groups)
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "report.json")
+	if err := rep.WriteJSON(filename); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadJSON(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Metrics, rep.Metrics) {
+		t.Errorf("ReadJSON() = %+v, want %+v", read.Metrics, rep.Metrics)
+	}
+	if read.Seed != 1234567890123456789 {
+		t.Errorf("ReadJSON() seed = %v", read.Seed)
+	}
+	if e, ok := read.Lookup("producer", 2); !ok || e.EventId != 17 {
+		t.Errorf("Lookup() = %+v, %v", e, ok)
+	}
+	if e, ok := read.Lookup("listener", 1); !ok || e.Duration != time.Minute || e.EventKey != "abc" {
+		t.Errorf("Lookup() = %+v, %v", e, ok)
+	}
+	if _, ok := read.Lookup("browser", 1); ok {
+		t.Errorf("Lookup() found unexpected entry")
+	}
+	if err := rep.WriteHTML(filepath.Join(dir, "report.html")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecorderSentAt(t *testing.T) {
+	r := NewRecorder("test", time.Second)
+	if _, ok := r.SentAt("a/1"); ok {
+		t.Error("SentAt() of unknown message")
+	}
+	before := time.Now()
+	r.MarkSent("a/1")
+	if sent, ok := r.SentAt("a/1"); !ok || sent.Before(before) {
+		t.Errorf("SentAt() = %v, %v", sent, ok)
+	}
+	// old entries are removed once the map grows
+	r.sent["old"] = time.Now().Add(-2 * sentRetention)
+	for i := 0; i < 10000; i++ {
+		r.MarkSent(fmt.Sprint(i))
+	}
+	if _, ok := r.SentAt("old"); ok {
+		t.Error("old entry not removed")
+	}
+	if _, ok := r.SentAt("a/1"); !ok {
+		t.Error("recent entry removed")
+	}
+}
@@ -0,0 +1,599 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"racelogctl/internal"
+	"sort"
+	"time"
+)
+
+// Params controls the shape of a synthetic race
+type Params struct {
+	Cars             int           // number of cars in the field
+	Classes          int           // number of car classes (cars are assigned round robin)
+	TrackLength      float64       // track length in meters
+	Sectors          int           // number of sectors per lap
+	LapTime          float64       // mean lap time (seconds) of the fastest class
+	LapTimeStdDev    float64       // standard deviation (seconds) of a single lap
+	PitStops         int           // number of pit stops per car
+	PitDuration      float64       // seconds spent in the pit lane per stop
+	RaceLength       time.Duration // the race ends with the first car crossing the line after this duration
+	SpeedmapInterval int           // seconds between two speedmap messages
+	Seed             int64         // seed for the random generator
+	StartTime        time.Time     // wall clock time of the race start
+}
+
+// DefaultParams returns the parameters for a short single class race
+func DefaultParams() Params {
+	return Params{
+		Cars:             20,
+		Classes:          1,
+		TrackLength:      5000,
+		Sectors:          3,
+		LapTime:          100,
+		LapTimeStdDev:    0.5,
+		PitStops:         1,
+		PitDuration:      60,
+		RaceLength:       30 * time.Minute,
+		SpeedmapInterval: 15,
+		Seed:             1,
+		StartTime:        time.Now(),
+	}
+}
+
+const chunkSize = 10 // speedmap chunk size in meters
+
+var classNames = []string{"GT3", "LMP2", "GTE", "GT4", "TCR", "MX5"}
+var carNames = []string{"Porsche 911 GT3 R", "Dallara P217", "Ferrari 488 GTE", "BMW M4 GT4", "Audi RS 3 LMS", "Mazda MX-5 Cup"}
+
+// class k is slower than the fastest class by this factor per class
+const classPaceStep = 0.06
+
+type carSim struct {
+	idx       int
+	num       string
+	class     int
+	team      string
+	drivers   []string
+	iRatings  []int
+	driver    int
+	skill     float64 // lap time multiplier of this car
+	state     string
+	lc        int     // laps completed
+	trackPos  float64 // 0..1
+	lapTime   float64 // target lap time of the current lap
+	lapStart  float64 // session time when the current lap started
+	sector    int     // current sector
+	secStart  float64 // session time when the current sector started
+	sectors   []interface{}
+	last      float64
+	best      float64
+	pitstops  int
+	stintLap  int
+	pitLaps   map[int]bool
+	pitRemain float64
+	lastCross float64 // session time of the last line crossing
+	finished  bool
+}
+
+// Generator produces a race as a sequence of full states (one per second)
+// with interleaved speedmap messages
+type Generator struct {
+	p           Params
+	rnd         *rand.Rand
+	cars        []*carSim
+	sectorStart []float64
+	sessionTime float64
+	checkered   bool
+	maxTime     float64
+}
+
+// New creates a generator for the given parameters. Invalid values are replaced by defaults.
+func New(p Params) *Generator {
+	d := DefaultParams()
+	if p.Cars <= 0 {
+		p.Cars = d.Cars
+	}
+	if p.Classes <= 0 {
+		p.Classes = d.Classes
+	}
+	if p.Classes > len(classNames) {
+		p.Classes = len(classNames)
+	}
+	if p.TrackLength <= 0 {
+		p.TrackLength = d.TrackLength
+	}
+	if p.Sectors <= 0 {
+		p.Sectors = d.Sectors
+	}
+	if p.LapTime <= 0 {
+		p.LapTime = d.LapTime
+	}
+	if p.LapTimeStdDev < 0 {
+		p.LapTimeStdDev = 0
+	}
+	if p.PitDuration <= 0 {
+		p.PitDuration = d.PitDuration
+	}
+	if p.RaceLength <= 0 {
+		p.RaceLength = d.RaceLength
+	}
+	if p.SpeedmapInterval <= 0 {
+		p.SpeedmapInterval = d.SpeedmapInterval
+	}
+	if p.StartTime.IsZero() {
+		p.StartTime = d.StartTime
+	}
+
+	g := &Generator{p: p, rnd: rand.New(rand.NewSource(p.Seed))}
+	g.sectorStart = make([]float64, p.Sectors)
+	for i := range g.sectorStart {
+		g.sectorStart[i] = float64(i) / float64(p.Sectors)
+	}
+	// give the slowest car enough time to finish its last lap
+	g.maxTime = p.RaceLength.Seconds() + 3*p.LapTime*(1+classPaceStep*float64(p.Classes))
+
+	expectedLaps := int(p.RaceLength.Seconds() / p.LapTime)
+	for i := 0; i < p.Cars; i++ {
+		c := &carSim{
+			idx:     i,
+			num:     fmt.Sprintf("%d", i+1),
+			class:   i % p.Classes,
+			team:    fmt.Sprintf("Team %d", i+1),
+			drivers: []string{fmt.Sprintf("Driver %d-A", i+1), fmt.Sprintf("Driver %d-B", i+1)},
+			skill:   1 + math.Abs(g.rnd.NormFloat64())*0.01,
+			state:   "RUN",
+			sectors: make([]interface{}, p.Sectors),
+			pitLaps: map[int]bool{},
+		}
+		for range c.drivers {
+			c.iRatings = append(c.iRatings, 1500+g.rnd.Intn(3500))
+		}
+		for s := 1; s <= p.PitStops; s++ {
+			// spread the stops evenly with some variation between the cars
+			lap := s*expectedLaps/(p.PitStops+1) + g.rnd.Intn(3) - 1
+			if lap > 0 {
+				c.pitLaps[lap] = true
+			}
+		}
+		// the grid is staggered a little, the first car is at the line.
+		// Large fields wrap around, so the position stays within 0..1.
+		c.trackPos = 1 - math.Mod(float64(i)*0.002, 1)
+		if c.trackPos >= 1 {
+			c.trackPos = 0
+		}
+		c.lc = -1
+		if c.trackPos == 0 {
+			c.lc = 0
+		}
+		c.lapTime = g.sampleLapTime(c)
+		g.cars = append(g.cars, c)
+	}
+	return g
+}
+
+func (g *Generator) classLapTime(class int) float64 {
+	return g.p.LapTime * (1 + classPaceStep*float64(class))
+}
+
+func (g *Generator) sampleLapTime(c *carSim) float64 {
+	return g.classLapTime(c.class)*c.skill + g.rnd.NormFloat64()*g.p.LapTimeStdDev
+}
+
+// Params returns the effective parameters used by the generator
+func (g *Generator) Params() Params {
+	return g.p
+}
+
+// Manifests returns the manifests matching the generated payloads
+func (g *Generator) Manifests() internal.Manifests {
+	car := []string{"state", "carIdx", "carNum", "userName", "teamName", "car", "carClass",
+		"pos", "pic", "lap", "lc", "gap", "interval", "trackPos", "speed", "dist",
+		"pitstops", "stintLap", "last", "best"}
+	for i := 1; i <= g.p.Sectors; i++ {
+		car = append(car, fmt.Sprintf("s%d", i))
+	}
+	return internal.Manifests{
+		Car: car,
+		Session: []string{"sessionNum", "sessionTime", "timeRemain", "lapsRemain", "flagState",
+			"timeOfDay", "airTemp", "airDensity", "airPressure", "trackTemp", "windDir", "windVel"},
+		Message: []string{"type", "subType", "carIdx", "carNum", "carClass", "msg"},
+		Pit:     []string{},
+	}
+}
+
+// TrackInfo returns the synthetic track
+func (g *Generator) TrackInfo() *internal.TrackInfo {
+	t := internal.TrackInfo{
+		TrackId:               0,
+		TrackDisplayName:      "Synthetic Raceway",
+		TrackDisplayShortName: "Synthetic",
+		TrackConfigName:       fmt.Sprintf("%.0fm", g.p.TrackLength),
+		TrackLength:           g.p.TrackLength,
+		Sectors:               g.sectors(),
+	}
+	t.Pit.Entry = 0.97
+	t.Pit.Exit = 0.05
+	t.Pit.LaneLength = 0.08 * g.p.TrackLength
+	return &t
+}
+
+func (g *Generator) sectors() []internal.Sector {
+	ret := make([]internal.Sector, len(g.sectorStart))
+	for i, s := range g.sectorStart {
+		ret[i] = internal.Sector{SectorNum: i, SectorStartPct: s}
+	}
+	return ret
+}
+
+// Info returns the event info of the synthetic race
+func (g *Generator) Info() internal.EventInfo {
+	t := g.TrackInfo()
+	return internal.EventInfo{
+		RaceloggerVersion:     "0.6.0",
+		Name:                  fmt.Sprintf("synthetic-%s", g.p.StartTime.Format("20060102-150405")),
+		Description:           fmt.Sprintf("synthetic race (seed %d)", g.p.Seed),
+		EventTime:             g.p.StartTime.UTC().Format("2006-01-02T15:04:05"),
+		TrackId:               t.TrackId,
+		TrackDisplayName:      t.TrackDisplayName,
+		TrackDisplayShortName: t.TrackDisplayShortName,
+		TrackConfigName:       t.TrackConfigName,
+		TrackLength:           t.TrackLength,
+		TrackPitSpeed:         80,
+		MultiClass:            g.p.Classes > 1,
+		TeamRacing:            1,
+		NumCarTypes:           g.p.Classes,
+		NumCarClasses:         g.p.Classes,
+		SpeedmapInterval:      g.p.SpeedmapInterval,
+		Sectors:               t.Sectors,
+		Sessions: []internal.EventSession{
+			{Num: 0, Laps: -1, Name: "RACE", Time: int(g.p.RaceLength.Seconds()), Type: "Race"},
+		},
+	}
+}
+
+// Event returns an event as if the synthetic race was already recorded
+func (g *Generator) Event() *internal.Event {
+	info := g.Info()
+	return &internal.Event{
+		EventKey:    "",
+		Name:        info.Name,
+		Description: info.Description,
+		RecordDate:  g.p.StartTime.UTC().Format("2006-01-02T15:04:05Z"),
+		Data: internal.Data{
+			Info: info,
+			ReplayInfo: internal.ReplayInfo{
+				MinTimestamp:   float64(g.p.StartTime.Unix()),
+				MinSessionTime: 0,
+				MaxSessionTime: g.p.RaceLength.Seconds(),
+			},
+			Manifests: g.Manifests(),
+		},
+	}
+}
+
+// RegisterMessage returns the message used to register the synthetic race with a provider
+func (g *Generator) RegisterMessage(eventKey string) internal.RegisterMessage {
+	return internal.RegisterMessage{
+		EventKey:   eventKey,
+		Manifests:  g.Manifests(),
+		Info:       g.Info(),
+		TrackInfo:  *g.TrackInfo(),
+		RecordDate: float64(g.p.StartTime.Unix()),
+	}
+}
+
+// CarData returns the car, class and entry information of the field
+func (g *Generator) CarData() *internal.EventCarMessage {
+	cars := internal.EventCars{}
+	for k := 0; k < g.p.Classes; k++ {
+		cars.CarClasses = append(cars.CarClasses, internal.CarClass{Id: 4000 + k, Name: classNames[k]})
+		cars.Cars = append(cars.Cars, internal.Car{
+			Name:         carNames[k],
+			NameShort:    classNames[k],
+			CarClassName: classNames[k],
+			CarId:        100 + k,
+			CarClassId:   4000 + k,
+			FuelPct:      1,
+			DryTireSets:  0,
+		})
+	}
+	for _, c := range g.cars {
+		e := internal.EventEntry{}
+		e.Car.Name = carNames[c.class]
+		e.Car.CarId = 100 + c.class
+		e.Car.CarIdx = c.idx
+		e.Car.CarClassId = 4000 + c.class
+		e.Car.CarNumber = c.num
+		e.Car.CarNumberRaw = c.idx + 1
+		e.Team.Id = 1000 + c.idx
+		e.Team.Name = c.team
+		e.Team.CarIdx = c.idx
+		for i, name := range c.drivers {
+			ir := c.iRatings[i]
+			d := struct {
+				Id          int    `json:"id"`
+				Name        string `json:"name"`
+				CarIdx      int    `json:"carIdx"`
+				IRating     int    `json:"iRating"`
+				Initials    string `json:"initials"`
+				LicLevel    int    `json:"licLevel"`
+				LicString   string `json:"licString"`
+				LicSubLevel int    `json:"licSubLevel"`
+				AbbrevName  string `json:"abbrevName"`
+			}{
+				Id:          100000 + c.idx*10 + i,
+				Name:        name,
+				CarIdx:      c.idx,
+				IRating:     ir,
+				Initials:    fmt.Sprintf("D%c", 'A'+i),
+				LicLevel:    20,
+				LicString:   fmt.Sprintf("A %d.%02d", 1+ir/1000, ir%100),
+				LicSubLevel: 100 + ir%400,
+				AbbrevName:  name,
+			}
+			e.Drivers = append(e.Drivers, d)
+		}
+		cars.Entries = append(cars.Entries, e)
+	}
+	return &internal.EventCarMessage{Type: 1, Timestamp: float64(g.p.StartTime.Unix()), Payload: cars}
+}
+
+// Next advances the race by one second.
+// It returns the resulting full state and, if due, a speedmap message.
+// ok is false once all cars have finished the race.
+func (g *Generator) Next() (state internal.State, speedmap *internal.SpeedmapMessage, ok bool) {
+	if g.done() {
+		return internal.State{}, nil, false
+	}
+	const dt = 1.0
+	g.sessionTime += dt
+	messages := [][]interface{}{}
+	raceOver := g.sessionTime >= g.p.RaceLength.Seconds()
+
+	for _, c := range g.cars {
+		if c.finished {
+			continue
+		}
+		if c.pitRemain > 0 {
+			c.pitRemain -= dt
+			if c.pitRemain <= 0 {
+				c.state = "RUN"
+				c.stintLap = 0
+				c.driver = (c.driver + 1) % len(c.drivers)
+				messages = append(messages, g.message(c, "Pits", "Exit", fmt.Sprintf("#%s exited the pits", c.num)))
+			}
+			continue
+		}
+		c.trackPos += dt / c.lapTime
+		for c.sector < len(g.sectorStart)-1 && c.trackPos >= g.sectorStart[c.sector+1] {
+			c.sector++
+			g.completeSector(c, c.sector-1, c.trackPos-g.sectorStart[c.sector])
+		}
+		if c.trackPos < 1 {
+			continue
+		}
+		overshoot := (c.trackPos - 1) * c.lapTime
+		c.lastCross = g.sessionTime - overshoot
+		if c.lc >= 0 {
+			g.completeSector(c, len(g.sectorStart)-1, c.trackPos-1)
+			c.last = c.lastCross - c.lapStart
+			if c.best == 0 || c.last < c.best {
+				c.best = c.last
+			}
+			c.stintLap++
+		}
+		c.lc++
+		c.lapStart = c.lastCross
+		c.secStart = c.lastCross
+		c.sector = 0
+		c.trackPos -= 1
+		c.lapTime = g.sampleLapTime(c)
+		if raceOver && (g.checkered || c == g.leader()) {
+			if !g.checkered {
+				messages = append(messages, g.message(c, "Timing", "RaceControl", "Checkered flag"))
+			}
+			g.checkered = true
+			c.finished = true
+			c.state = "FIN"
+			c.trackPos = 0
+			continue
+		}
+		if c.pitLaps[c.lc] {
+			c.pitRemain = g.p.PitDuration
+			c.pitstops++
+			c.state = "PIT"
+			c.trackPos = 0
+			messages = append(messages, g.message(c, "Pits", "Enter", fmt.Sprintf("#%s entered the pits", c.num)))
+		}
+	}
+
+	state = internal.State{
+		Type:      1,
+		Timestamp: g.timestamp(),
+		Payload: internal.Payload{
+			Cars:     g.carRows(),
+			Session:  g.sessionRow(),
+			Messages: messages,
+		},
+	}
+	if int(g.sessionTime)%g.p.SpeedmapInterval == 0 {
+		speedmap = g.speedmap()
+	}
+	return state, speedmap, true
+}
+
+func (g *Generator) done() bool {
+	if g.sessionTime >= g.maxTime {
+		return true
+	}
+	for _, c := range g.cars {
+		if !c.finished {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Generator) timestamp() float64 {
+	return float64(g.p.StartTime.Unix()) + g.sessionTime
+}
+
+// completeSector records the time of sector s. overshoot is the fraction of a lap
+// the car already traveled beyond the sector end
+func (g *Generator) completeSector(c *carSim, s int, overshoot float64) {
+	end := g.sessionTime - overshoot*c.lapTime
+	if c.lc >= 0 {
+		c.sectors[s] = round3(end - c.secStart)
+	}
+	c.secStart = end
+}
+
+func (g *Generator) message(c *carSim, msgType, subType, msg string) []interface{} {
+	return []interface{}{msgType, subType, c.idx, c.num, classNames[c.class], msg}
+}
+
+// standings returns the cars ordered by race position
+func (g *Generator) standings() []*carSim {
+	ret := make([]*carSim, len(g.cars))
+	copy(ret, g.cars)
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.lc != b.lc {
+			return a.lc > b.lc
+		}
+		if a.finished || b.finished {
+			if a.finished != b.finished {
+				return a.finished
+			}
+			return a.lastCross < b.lastCross
+		}
+		return a.trackPos > b.trackPos
+	})
+	return ret
+}
+
+func (g *Generator) leader() *carSim {
+	return g.standings()[0]
+}
+
+func (g *Generator) carRows() [][]interface{} {
+	standings := g.standings()
+	leader := standings[0]
+	pos := make(map[int]int, len(standings))
+	pic := make(map[int]int, len(standings))
+	interval := make(map[int]float64, len(standings))
+	gap := make(map[int]float64, len(standings))
+	classCount := make(map[int]int)
+	for i, c := range standings {
+		pos[c.idx] = i + 1
+		classCount[c.class]++
+		pic[c.idx] = classCount[c.class]
+		gap[c.idx] = round3(g.distance(leader, c) * c.lapTime)
+		if i > 0 {
+			interval[c.idx] = round3(g.distance(standings[i-1], c) * c.lapTime)
+		}
+	}
+	rows := make([][]interface{}, len(g.cars))
+	for i, c := range g.cars {
+		speed := 0.0
+		switch c.state {
+		case "RUN":
+			speed = g.p.TrackLength / c.lapTime * 3.6 * trackProfile(c.trackPos)
+		case "PIT":
+			speed = 0
+		}
+		lap := c.lc + 1
+		if c.finished {
+			lap = c.lc
+		}
+		row := []interface{}{
+			c.state, c.idx, c.num, c.drivers[c.driver], c.team, carNames[c.class], classNames[c.class],
+			pos[c.idx], pic[c.idx], lap, c.lc, gap[c.idx], interval[c.idx],
+			round3(c.trackPos), round3(speed), round3(float64(c.lc) + c.trackPos),
+			c.pitstops, c.stintLap, round3(c.last), round3(c.best),
+		}
+		row = append(row, c.sectors...)
+		rows[i] = row
+	}
+	return rows
+}
+
+// distance returns how many laps car b is behind car a
+func (g *Generator) distance(a, b *carSim) float64 {
+	return math.Max(0, float64(a.lc)+a.trackPos-float64(b.lc)-b.trackPos)
+}
+
+func (g *Generator) timeOfDay() float64 {
+	h, m, s := g.p.StartTime.Clock()
+	return math.Mod(float64(h*3600+m*60+s)+g.sessionTime, 86400)
+}
+
+func (g *Generator) trackTemp() float64 {
+	// warmest at 15:00, coldest at 03:00
+	return round3(28 + 10*math.Sin(2*math.Pi*(g.timeOfDay()-9*3600)/86400))
+}
+
+func (g *Generator) sessionRow() []interface{} {
+	flag := "GREEN"
+	if g.checkered {
+		flag = "CHECKERED"
+	}
+	timeRemain := math.Max(0, g.p.RaceLength.Seconds()-g.sessionTime)
+	airTemp := round3(g.trackTemp() - 8)
+	return []interface{}{
+		0, g.sessionTime, timeRemain, -1, flag,
+		g.timeOfDay(), airTemp, 1.2, 29.9, g.trackTemp(), 0.0, 2.0,
+	}
+}
+
+// trackProfile returns a speed factor for a track position which makes up
+// the "corners" and "straights" of the synthetic track. The average is 1.
+func trackProfile(pos float64) float64 {
+	return 1 + 0.25*math.Sin(2*math.Pi*3*pos) + 0.1*math.Cos(2*math.Pi*7*pos)
+}
+
+func (g *Generator) speedmap() *internal.SpeedmapMessage {
+	payload := internal.SpeedmapPayload{
+		ChunkSize:   chunkSize,
+		CurrentPos:  round3(g.leader().trackPos * g.p.TrackLength),
+		TrackLength: g.p.TrackLength,
+		TrackTemp:   g.trackTemp(),
+		SessionTime: g.sessionTime,
+		TimeOfDay:   g.timeOfDay(),
+	}
+	payload.Data = map[string]struct {
+		ChunkSpeeds []float64 `json:"chunkSpeeds"`
+		Laptime     float64   `json:"laptime"`
+	}{}
+	numChunks := int(math.Ceil(g.p.TrackLength / chunkSize))
+	for k := 0; k < g.p.Classes; k++ {
+		sum, n := 0.0, 0
+		for _, c := range g.cars {
+			if c.class == k && c.last > 0 {
+				sum += c.last
+				n++
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		laptime := sum / float64(n)
+		avgSpeed := g.p.TrackLength / laptime * 3.6
+		speeds := make([]float64, numChunks)
+		for i := range speeds {
+			speeds[i] = round3(avgSpeed * trackProfile(float64(i)/float64(numChunks)))
+		}
+		payload.Data[classNames[k]] = struct {
+			ChunkSpeeds []float64 `json:"chunkSpeeds"`
+			Laptime     float64   `json:"laptime"`
+		}{ChunkSpeeds: speeds, Laptime: round3(laptime)}
+	}
+	return &internal.SpeedmapMessage{Type: 1, Timestamp: g.timestamp(), Payload: payload}
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
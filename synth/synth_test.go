@@ -0,0 +1,95 @@
+package synth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testParams() Params {
+	p := DefaultParams()
+	p.Cars = 6
+	p.Classes = 2
+	p.RaceLength = 10 * time.Minute
+	p.LapTime = 60
+	p.PitStops = 1
+	p.PitDuration = 20
+	p.StartTime = time.Date(2022, 8, 20, 8, 0, 0, 0, time.UTC)
+	return p
+}
+
+func TestGeneratorConsistentWithManifests(t *testing.T) {
+	g := New(testParams())
+	manifests := g.Manifests()
+	states, speedmaps := 0, 0
+	finished := map[interface{}]bool{}
+	lastTimestamp := 0.0
+	for {
+		s, sm, ok := g.Next()
+		if !ok {
+			break
+		}
+		states++
+		if sm != nil {
+			speedmaps++
+		}
+		if s.Timestamp <= lastTimestamp {
+			t.Fatalf("timestamps not increasing: %v after %v", s.Timestamp, lastTimestamp)
+		}
+		lastTimestamp = s.Timestamp
+		if len(s.Payload.Session) != len(manifests.Session) {
+			t.Fatalf("session row has %d columns, manifest %d", len(s.Payload.Session), len(manifests.Session))
+		}
+		if len(s.Payload.Cars) != 6 {
+			t.Fatalf("expected 6 cars, got %d", len(s.Payload.Cars))
+		}
+		for _, row := range s.Payload.Cars {
+			if len(row) != len(manifests.Car) {
+				t.Fatalf("car row has %d columns, manifest %d", len(row), len(manifests.Car))
+			}
+			if row[0] == "FIN" {
+				finished[row[1]] = true
+			}
+		}
+		for _, msg := range s.Payload.Messages {
+			if len(msg) != len(manifests.Message) {
+				t.Fatalf("message has %d columns, manifest %d", len(msg), len(manifests.Message))
+			}
+		}
+	}
+	if states < 600 {
+		t.Errorf("expected at least 600 states, got %d", states)
+	}
+	if speedmaps == 0 {
+		t.Errorf("expected speedmaps")
+	}
+	if len(finished) != 6 {
+		t.Errorf("expected all cars to finish, got %d", len(finished))
+	}
+}
+
+func TestGeneratorDeterministic(t *testing.T) {
+	a := New(testParams())
+	b := New(testParams())
+	for i := 0; i < 300; i++ {
+		sa, _, _ := a.Next()
+		sb, _, _ := b.Next()
+		if !reflect.DeepEqual(sa, sb) {
+			t.Fatalf("state %d differs for same seed", i)
+		}
+	}
+	if !reflect.DeepEqual(a.CarData(), b.CarData()) {
+		t.Errorf("car data differs for same seed")
+	}
+}
+
+func TestGeneratorLargeField(t *testing.T) {
+	p := testParams()
+	p.Cars = 600
+	g := New(p)
+	for i, c := range g.cars {
+		if c.trackPos < 0 || c.trackPos >= 1 {
+			t.Fatalf("car %d: track position %v outside 0..1", i, c.trackPos)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"racelogctl/internal"
+)
+
+// maxIndex limits the growth of arrays by deltas in non-strict mode
+const maxIndex = 1 << 16
+
+// Errors wrapped by PatchError
+var (
+	ErrMalformedDelta = errors.New("malformed delta")
+	ErrOutOfRange     = errors.New("index out of range")
+)
+
+// PatchError describes a delta which could not be applied
+type PatchError struct {
+	Target string      // cars or session
+	Pos    int         // position of the delta in the patch data
+	Delta  interface{} // the delta
+	Err    error       // ErrMalformedDelta or ErrOutOfRange
+	Detail string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("%s delta %d %v: %v: %s", e.Target, e.Pos, e.Delta, e.Err, e.Detail)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// ToInt converts an index value. Supported are the integer types, integral floats and json.Number.
+func ToInt(src interface{}) (int, error) {
+	var f float64
+	switch v := src.(type) {
+	case int:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		f = float64(v)
+	case uint:
+		f = float64(v)
+	case uint8:
+		return int(v), nil
+	case uint16:
+		return int(v), nil
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			f = float64(i)
+		} else if fv, err := v.Float64(); err == nil {
+			f = fv
+		} else {
+			return 0, fmt.Errorf("invalid number %q", string(v))
+		}
+	default:
+		return 0, fmt.Errorf("unsupported index type %T", src)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, fmt.Errorf("%v is not a valid index", src)
+	}
+	return int(f), nil
+}
+
+// Patcher applies delta states.
+//
+// In strict mode deltas must reference columns within the manifests and rows of the
+// previous state. Otherwise the arrays grow as needed (up to a sane limit).
+// Invalid deltas are skipped; the first problem is returned as *PatchError.
+type Patcher struct {
+	Strict    bool
+	Manifests internal.Manifests
+}
+
+// index checks an index of a delta. The caller completes the returned error.
+func index(value interface{}, limit int) (int, *PatchError) {
+	idx, err := ToInt(value)
+	if err != nil {
+		return 0, &PatchError{Err: ErrMalformedDelta, Detail: err.Error()}
+	}
+	if idx < 0 || idx >= limit {
+		return 0, &PatchError{Err: ErrOutOfRange, Detail: fmt.Sprintf("%d not in [0,%d)", idx, limit)}
+	}
+	return idx, nil
+}
+
+// PatchCars applies the car deltas [[row, col, value],...] to a copy of src.
+// As before all rows are grown to the width required by the deltas, so the state stays
+// rectangular. Rows which are neither grown nor changed are shared with src, so rows of
+// states must not be modified in place.
+func (p Patcher) PatchCars(src [][]interface{}, patchData [][]interface{}) ([][]interface{}, error) {
+	rowLimit, colLimit := maxIndex, maxIndex
+	if p.Strict {
+		rowLimit, colLimit = len(src), len(p.Manifests.Car)
+	}
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	type carDelta struct {
+		row, col int
+		value    interface{}
+	}
+	valid := make([]carDelta, 0, len(patchData))
+	maxCol, maxRow := -1, -1
+	for pos, delta := range patchData {
+		if len(delta) != 3 {
+			fail(&PatchError{Target: "cars", Pos: pos, Delta: delta, Err: ErrMalformedDelta, Detail: "expected [row, col, value]"})
+			continue
+		}
+		row, err := index(delta[0], rowLimit)
+		if err != nil {
+			err.Target, err.Pos, err.Delta = "cars", pos, delta
+			fail(err)
+			continue
+		}
+		col, err := index(delta[1], colLimit)
+		if err != nil {
+			err.Target, err.Pos, err.Delta = "cars", pos, delta
+			fail(err)
+			continue
+		}
+		maxCol, maxRow = max(maxCol, col), max(maxRow, row)
+		valid = append(valid, carDelta{row, col, delta[2]})
+	}
+	// rows are shared with src and copied on first write
+	workCopy := make([][]interface{}, len(src), max(len(src), maxRow+1))
+	copy(workCopy, src)
+	owned := make([]bool, len(src), cap(workCopy))
+	for len(workCopy) <= maxRow {
+		workCopy = append(workCopy, nil)
+		owned = append(owned, false)
+	}
+	// all rows are grown to a common width
+	for i, row := range workCopy {
+		if len(row) <= maxCol {
+			tmp := make([]interface{}, maxCol+1)
+			copy(tmp, row)
+			workCopy[i] = tmp
+			owned[i] = true
+		}
+	}
+	for _, d := range valid {
+		if !owned[d.row] {
+			tmp := make([]interface{}, max(len(workCopy[d.row]), maxCol+1))
+			copy(tmp, workCopy[d.row])
+			workCopy[d.row] = tmp
+			owned[d.row] = true
+		}
+		workCopy[d.row][d.col] = d.value
+	}
+	return workCopy, firstErr
+}
+
+// PatchSession applies the session deltas [[col, value],...] to a copy of src
+func (p Patcher) PatchSession(src []interface{}, patchData []interface{}) ([]interface{}, error) {
+	colLimit := maxIndex
+	if p.Strict {
+		colLimit = len(p.Manifests.Session)
+	}
+	var firstErr error
+	workCopy := DuplicateArray(src)
+	for pos, d := range patchData {
+		delta, ok := d.([]interface{})
+		if !ok || len(delta) != 2 {
+			if firstErr == nil {
+				firstErr = &PatchError{Target: "session", Pos: pos, Delta: d, Err: ErrMalformedDelta, Detail: "expected [col, value]"}
+			}
+			continue
+		}
+		col, err := index(delta[0], colLimit)
+		if err != nil {
+			if firstErr == nil {
+				err.Target, err.Pos, err.Delta = "session", pos, delta
+				firstErr = err
+			}
+			continue
+		}
+		if len(workCopy) <= col {
+			tmp := make([]interface{}, col+1)
+			copy(tmp, workCopy)
+			workCopy = tmp
+		}
+		workCopy[col] = delta[1]
+	}
+	return workCopy, firstErr
+}
+
+// Process returns the next complete state. state contains the previous (complete) data,
+// incoming the data received from the server. An unknown state type is reported as
+// ErrMalformedDelta and the previous state is returned.
+func (p Patcher) Process(state, incoming internal.State) (internal.State, error) {
+	s := internal.State{}
+	switch incoming.Type {
+	case 1:
+		return incoming, nil
+	case 2:
+		s.Type = 1
+		s.Timestamp = incoming.Timestamp
+		cars, carErr := p.PatchCars(state.Payload.Cars, incoming.Payload.Cars)
+		session, sessionErr := p.PatchSession(state.Payload.Session, incoming.Payload.Session)
+		s.Payload.Cars = cars
+		s.Payload.Session = session
+		if len(incoming.Payload.Messages) > 0 {
+			s.Payload.Messages = incoming.Payload.Messages // messages don't have delta processing by design
+		} else {
+			s.Payload.Messages = [][]interface{}{}
+		}
+		if carErr != nil {
+			return s, carErr
+		}
+		return s, sessionErr
+	}
+	return state, &PatchError{Target: "state", Delta: incoming.Type, Err: ErrMalformedDelta, Detail: "unknown state type"}
+}
@@ -0,0 +1,323 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"racelogctl/internal"
+	"reflect"
+	"testing"
+)
+
+func TestToInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    int
+		wantErr bool
+	}{
+		{name: "int", src: 3, want: 3},
+		{name: "int64", src: int64(4), want: 4},
+		{name: "uint8", src: uint8(5), want: 5},
+		{name: "float64", src: float64(6), want: 6},
+		{name: "float32", src: float32(7), want: 7},
+		{name: "json.Number", src: json.Number("8"), want: 8},
+		{name: "json.Number float", src: json.Number("9.0"), want: 9},
+		{name: "negative", src: -1, want: -1},
+		{name: "fraction", src: 1.5, wantErr: true},
+		{name: "NaN", src: math.NaN(), wantErr: true},
+		{name: "Inf", src: math.Inf(1), wantErr: true},
+		{name: "huge", src: float64(1 << 40), wantErr: true},
+		{name: "bad json.Number", src: json.Number("x"), wantErr: true},
+		{name: "string", src: "1", wantErr: true},
+		{name: "nil", src: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToInt(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToInt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ToInt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetIntValueNoPanic(t *testing.T) {
+	if got := GetIntValue("x"); got != 0 {
+		t.Errorf("GetIntValue() = %v, want 0", got)
+	}
+}
+
+func TestPatchCarsGrowsFullRow(t *testing.T) {
+	// previously a column index equal to the row length was not handled
+	src := [][]interface{}{{1, 2}}
+	got := PatchCars(src, [][]interface{}{{0, 2, 3}, {1, 0, 4}})
+	want := [][]interface{}{{1, 2, 3}, {4, nil, nil}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PatchCars() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(src, [][]interface{}{{1, 2}}) {
+		t.Errorf("PatchCars() modified src: %v", src)
+	}
+	gotSession := PatchSession([]interface{}{1, 2}, []interface{}{[]interface{}{2, 3}})
+	if !reflect.DeepEqual(gotSession, []interface{}{1, 2, 3}) {
+		t.Errorf("PatchSession() = %v", gotSession)
+	}
+}
+
+func TestPatcherErrors(t *testing.T) {
+	manifests := internal.Manifests{Car: []string{"a", "b"}, Session: []string{"x"}}
+	src := [][]interface{}{{1, 2}}
+	tests := []struct {
+		name    string
+		strict  bool
+		patch   [][]interface{}
+		want    [][]interface{}
+		wantErr error
+	}{
+		{name: "valid", strict: true, patch: [][]interface{}{{0, 1, 5}}, want: [][]interface{}{{1, 5}}},
+		{name: "json.Number", strict: true, patch: [][]interface{}{{json.Number("0"), json.Number("1"), 5}}, want: [][]interface{}{{1, 5}}},
+		{name: "short delta", patch: [][]interface{}{{0, 1}, {0, 0, 7}}, want: [][]interface{}{{7, 2}}, wantErr: ErrMalformedDelta},
+		{name: "bad index type", patch: [][]interface{}{{"0", 1, 5}}, want: [][]interface{}{{1, 2}}, wantErr: ErrMalformedDelta},
+		{name: "negative index", patch: [][]interface{}{{0, -1, 5}}, want: [][]interface{}{{1, 2}}, wantErr: ErrOutOfRange},
+		{name: "huge index", patch: [][]interface{}{{maxIndex, 0, 5}}, want: [][]interface{}{{1, 2}}, wantErr: ErrOutOfRange},
+		{name: "unknown row strict", strict: true, patch: [][]interface{}{{1, 0, 5}}, want: [][]interface{}{{1, 2}}, wantErr: ErrOutOfRange},
+		{name: "unknown row", patch: [][]interface{}{{1, 0, 5}}, want: [][]interface{}{{1, 2}, {5}}},
+		{name: "column beyond manifest strict", strict: true, patch: [][]interface{}{{0, 2, 5}}, want: [][]interface{}{{1, 2}}, wantErr: ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Patcher{Strict: tt.strict, Manifests: manifests}.PatchCars(src, tt.patch)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("PatchCars() error = %v, want %v", err, tt.wantErr)
+			}
+			var pe *PatchError
+			if err != nil && !errors.As(err, &pe) {
+				t.Errorf("PatchCars() error %T is not a *PatchError", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PatchCars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	_, err := Patcher{Strict: true, Manifests: manifests}.PatchSession([]interface{}{1}, []interface{}{[]interface{}{1, 2}})
+	if !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("PatchSession() error = %v, want %v", err, ErrOutOfRange)
+	}
+	_, err = Patcher{}.PatchSession([]interface{}{1}, []interface{}{3})
+	if !errors.Is(err, ErrMalformedDelta) {
+		t.Errorf("PatchSession() error = %v, want %v", err, ErrMalformedDelta)
+	}
+	prev := internal.State{Type: 1, Timestamp: 1, Payload: internal.Payload{Cars: src, Session: []interface{}{1}}}
+	got, err := Patcher{}.Process(prev, internal.State{Type: 3, Timestamp: 2})
+	if !errors.Is(err, ErrMalformedDelta) || !reflect.DeepEqual(got, prev) {
+		t.Errorf("Process() unknown type = %v, %v", got, err)
+	}
+}
+
+func TestPatchCarsGrowsUntouchedRows(t *testing.T) {
+	// rows without deltas are widened, too (as the exporters expect rectangular states)
+	src := [][]interface{}{{1, 2}, {3, 4, 5}}
+	got := PatchCars(src, [][]interface{}{{1, 3, 6}})
+	want := [][]interface{}{{1, 2, nil, nil}, {3, 4, 5, 6}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PatchCars() = %v, want %v", got, want)
+	}
+}
+
+// randomState creates a state with the given dimensions. Values are chosen from a
+// small set so that successive states share some of them.
+func randomState(r *rand.Rand, rows, cols, sessionCols int) internal.State {
+	value := func() interface{} {
+		switch r.Intn(4) {
+		case 0:
+			return float64(r.Intn(10))
+		case 1:
+			return r.Float64()
+		case 2:
+			return []string{"RUN", "PIT", "OUT"}[r.Intn(3)]
+		default:
+			return nil
+		}
+	}
+	s := internal.State{Type: 1, Timestamp: r.Float64() * 1000, Payload: internal.Payload{
+		Cars: make([][]interface{}, rows), Session: make([]interface{}, sessionCols), Messages: [][]interface{}{},
+	}}
+	for i := range s.Payload.Cars {
+		s.Payload.Cars[i] = make([]interface{}, cols)
+		for j := range s.Payload.Cars[i] {
+			s.Payload.Cars[i][j] = value()
+		}
+	}
+	for j := range s.Payload.Session {
+		s.Payload.Session[j] = value()
+	}
+	return s
+}
+
+// roundTrip sends the state through JSON encoding like the data received from the server
+// deltaState returns the state to be sent to transform prev into next. If the shape of
+// the car rows changed a complete state (type 1) is returned, otherwise a delta state (type 2).
+func deltaState(prev, next internal.State) internal.State {
+	if len(prev.Payload.Cars) != len(next.Payload.Cars) || len(prev.Payload.Session) > len(next.Payload.Session) {
+		return next
+	}
+	for i := range prev.Payload.Cars {
+		if len(prev.Payload.Cars[i]) > len(next.Payload.Cars[i]) {
+			return next
+		}
+	}
+	ret := internal.State{Type: 2, Timestamp: next.Timestamp, Payload: internal.Payload{
+		Cars: [][]interface{}{}, Session: []interface{}{}, Messages: next.Payload.Messages,
+	}}
+	for row, values := range next.Payload.Cars {
+		for col, v := range values {
+			if col >= len(prev.Payload.Cars[row]) || !reflect.DeepEqual(prev.Payload.Cars[row][col], v) {
+				ret.Payload.Cars = append(ret.Payload.Cars, []interface{}{row, col, v})
+			}
+		}
+	}
+	for col, v := range next.Payload.Session {
+		if col >= len(prev.Payload.Session) || !reflect.DeepEqual(prev.Payload.Session[col], v) {
+			ret.Payload.Session = append(ret.Payload.Session, []interface{}{col, v})
+		}
+	}
+	return ret
+}
+
+func roundTrip(t *testing.T, s internal.State) internal.State {
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ConvertJsonToGo(data)
+}
+
+func FuzzDeltaRoundTrip(f *testing.F) {
+	f.Add(int64(1), uint8(3), uint8(5), uint8(4), uint8(3))
+	f.Add(int64(42), uint8(0), uint8(0), uint8(0), uint8(1))
+	f.Add(int64(7), uint8(20), uint8(30), uint8(10), uint8(5))
+	f.Fuzz(func(t *testing.T, seed int64, rows, cols, sessionCols, steps uint8) {
+		r := rand.New(rand.NewSource(seed))
+		n, c, sc := int(rows%32), int(cols%48), int(sessionCols%24)
+		manifests := internal.Manifests{Car: make([]string, c), Session: make([]string, sc)}
+		patcher := Patcher{Strict: true, Manifests: manifests}
+
+		current := roundTrip(t, randomState(r, n, c, sc))
+		for i := 0; i < int(steps%8); i++ {
+			next := roundTrip(t, randomState(r, n, c, sc))
+			delta := roundTrip(t, deltaState(current, next))
+			got, err := patcher.Process(current, delta)
+			if err != nil {
+				t.Fatalf("Process() step %d: %v", i, err)
+			}
+			if !reflect.DeepEqual(got.Payload.Cars, next.Payload.Cars) ||
+				!reflect.DeepEqual(got.Payload.Session, next.Payload.Session) {
+				t.Fatalf("Process() step %d = %v, want %v", i, got.Payload, next.Payload)
+			}
+			current = got
+		}
+	})
+}
+
+func FuzzPatchMalformed(f *testing.F) {
+	f.Add([]byte(`{"type":2,"payload":{"cars":[[0,1,2]],"session":[[0,1]]}}`))
+	f.Add([]byte(`{"type":2,"payload":{"cars":[[0,1],[-1,0,1],[1.5,0,0]],"session":[1,[2],["a",1]]}}`))
+	f.Add([]byte(`{"type":2,"payload":{"cars":[[100000,0,1]],"session":[[1e300,1]]}}`))
+	f.Add([]byte(`{"type":3,"payload":{"cars":[[0,1,2]]}}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var incoming internal.State
+		if err := json.Unmarshal(data, &incoming); err != nil {
+			return
+		}
+		manifests := internal.Manifests{Car: make([]string, 4), Session: make([]string, 3)}
+		prev := internal.State{Type: 1, Payload: internal.Payload{
+			Cars:    [][]interface{}{{1, 2, 3, 4}, {5, 6, 7, 8}},
+			Session: []interface{}{1, 2, 3},
+		}}
+		got, err := Patcher{Strict: true, Manifests: manifests}.Process(prev, incoming)
+		if incoming.Type != 1 && incoming.Type != 2 && !errors.Is(err, ErrMalformedDelta) {
+			t.Fatalf("Process() type %d: error = %v, want %v", incoming.Type, err, ErrMalformedDelta)
+		}
+		if incoming.Type != 2 {
+			return
+		}
+		if len(got.Payload.Cars) != 2 || len(got.Payload.Session) != 3 {
+			t.Fatalf("strict Process() changed dimensions: %v", got.Payload)
+		}
+		for _, row := range got.Payload.Cars {
+			if len(row) != 4 {
+				t.Fatalf("strict Process() changed dimensions: %v", got.Payload)
+			}
+		}
+		// must not panic
+		ProcessDeltaStates(prev, incoming)
+	})
+}
+
+func TestPatchCarsSharesUnchangedRows(t *testing.T) {
+	src := [][]interface{}{{1, 2}, {3, 4}, {5, 6}}
+	got := PatchCars(src, [][]interface{}{{1, 0, 30}})
+	if &got[0][0] != &src[0][0] || &got[2][0] != &src[2][0] {
+		t.Error("PatchCars() copied unchanged rows")
+	}
+	if &got[1][0] == &src[1][0] || src[1][0] != 3 || got[1][0] != 30 {
+		t.Errorf("PatchCars() changed row not copied: src %v, got %v", src, got)
+	}
+}
+
+// patchCarsCopyAll is the former implementation copying every row for reference in benchmarks
+func patchCarsCopyAll(src [][]interface{}, patchData [][]interface{}) [][]interface{} {
+	workCopy := make([][]interface{}, len(src))
+	for i := range src {
+		workCopy[i] = DuplicateArray(src[i])
+	}
+	for _, delta := range patchData {
+		row, col := GetIntValue(delta[0]), GetIntValue(delta[1])
+		workCopy[row][col] = delta[2]
+	}
+	return workCopy
+}
+
+// raceDeltas creates a 64 car state and deltas in which every 4th car changes a few columns
+// (cars on track update their positions, cars in the pit are mostly unchanged)
+func raceDeltas() ([][]interface{}, [][][]interface{}) {
+	r := rand.New(rand.NewSource(1))
+	s := randomState(r, 64, 40, 20)
+	deltas := make([][][]interface{}, 100)
+	for i := range deltas {
+		for row := i % 4; row < 64; row += 4 {
+			for _, col := range []int{3, 7, 8, 12, 20} {
+				deltas[i] = append(deltas[i], []interface{}{float64(row), float64(col), r.Float64()})
+			}
+		}
+	}
+	return s.Payload.Cars, deltas
+}
+
+func BenchmarkPatchCars(b *testing.B) {
+	cars, deltas := raceDeltas()
+	b.Run("copy-on-write", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			current := cars
+			for _, d := range deltas {
+				current = PatchCars(current, d)
+			}
+		}
+	})
+	b.Run("copy-all", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			current := cars
+			for _, d := range deltas {
+				current = patchCarsCopyAll(current, d)
+			}
+		}
+	})
+}
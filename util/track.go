@@ -0,0 +1,40 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"racelogctl/internal"
+)
+
+// DiffTracks returns a description of each difference between the track infos
+func DiffTracks(a, b *internal.TrackInfo) []string {
+	ret := []string{}
+	diffString := func(name, x, y string) {
+		if x != y {
+			ret = append(ret, fmt.Sprintf("%s: %q != %q", name, x, y))
+		}
+	}
+	diffFloat := func(name string, x, y float64) {
+		if math.Abs(x-y) > 1e-6 {
+			ret = append(ret, fmt.Sprintf("%s: %v != %v", name, x, y))
+		}
+	}
+	if a.TrackId != b.TrackId {
+		ret = append(ret, fmt.Sprintf("trackId: %d != %d", a.TrackId, b.TrackId))
+	}
+	diffString("trackDisplayName", a.TrackDisplayName, b.TrackDisplayName)
+	diffString("trackDisplayShortName", a.TrackDisplayShortName, b.TrackDisplayShortName)
+	diffString("trackConfigName", a.TrackConfigName, b.TrackConfigName)
+	diffFloat("trackLength", a.TrackLength, b.TrackLength)
+	diffFloat("trackPitSpeed", a.TrackPitSpeed, b.TrackPitSpeed)
+	diffFloat("pit.entry", a.Pit.Entry, b.Pit.Entry)
+	diffFloat("pit.exit", a.Pit.Exit, b.Pit.Exit)
+	diffFloat("pit.laneLength", a.Pit.LaneLength, b.Pit.LaneLength)
+	if len(a.Sectors) != len(b.Sectors) {
+		ret = append(ret, fmt.Sprintf("sectors: %d != %d", len(a.Sectors), len(b.Sectors)))
+	}
+	for i := 0; i < len(a.Sectors) && i < len(b.Sectors); i++ {
+		diffFloat(fmt.Sprintf("sectors[%d].SectorStartPct", i), a.Sectors[i].SectorStartPct, b.Sectors[i].SectorStartPct)
+	}
+	return ret
+}
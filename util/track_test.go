@@ -0,0 +1,31 @@
+package util
+
+import (
+	"racelogctl/internal"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTracks(t *testing.T) {
+	a := &internal.TrackInfo{TrackId: 168, TrackDisplayName: "Suzuka", TrackLength: 5750,
+		Sectors: []internal.Sector{{SectorNum: 0, SectorStartPct: 0}, {SectorNum: 1, SectorStartPct: 0.5}}}
+	a.Pit.Entry = 0.99
+	b := *a
+	b.Sectors = append([]internal.Sector{}, a.Sectors...)
+	if diff := DiffTracks(a, &b); len(diff) != 0 {
+		t.Errorf("expected no differences, got %v", diff)
+	}
+	b.TrackLength = 5800
+	b.Pit.Entry = 0.98
+	b.Sectors[1].SectorStartPct = 0.6
+	b.Sectors = append(b.Sectors, internal.Sector{SectorNum: 2, SectorStartPct: 0.8})
+	want := []string{
+		"trackLength: 5750 != 5800",
+		"pit.entry: 0.99 != 0.98",
+		"sectors: 2 != 3",
+		"sectors[1].SectorStartPct: 0.5 != 0.6",
+	}
+	if diff := DiffTracks(a, &b); !reflect.DeepEqual(diff, want) {
+		t.Errorf("got %v, want %v", diff, want)
+	}
+}
@@ -0,0 +1,341 @@
+// Package verify checks the archived data of an event for inconsistencies.
+package verify
+
+import (
+	"fmt"
+	"math"
+	"racelogctl/internal"
+	"racelogctl/util"
+
+	"github.com/blang/semver/v4"
+)
+
+// Severity of a finding
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Checks reported in findings
+const (
+	CheckTimestampOrder    = "timestamp-order"     // state timestamps not increasing
+	CheckDuplicate         = "duplicate-timestamp" // two states with the same timestamp
+	CheckGap               = "gap"                 // no state for more than Options.MaxGap seconds
+	CheckDeltaMalformed    = "delta-malformed"     // delta entry with wrong structure
+	CheckDeltaOutOfRange   = "delta-out-of-range"  // delta referencing rows/cols outside the manifest
+	CheckCarRowLength      = "car-row-length"      // car row length differs from the car manifest
+	CheckSessionRowLength  = "session-row-length"  // session row length differs from the session manifest
+	CheckSessionTime       = "session-time"        // session time going backwards within a session
+	CheckReplayInfo        = "replay-info"         // ReplayInfo doesn't match the data
+	CheckSpeedmapOrder     = "speedmap-order"      // speedmap timestamps not increasing
+	CheckMissingCarData    = "missing-car-data"    // no car data although the racelogger provides it
+	CheckMissingSpeedmaps  = "missing-speedmaps"   // no speedmaps although the racelogger provides them
+	CheckUnreachable       = "unreachable"         // event data could not be fetched
+	CheckNoData            = "no-data"             // no states at all
+	CheckMissingFirstState = "missing-full-state"  // delta state without preceding full state
+)
+
+// carDataVersions are the racelogger versions providing car data and speedmaps
+var carDataVersions = semver.MustParseRange(">=0.4.4")
+
+// Finding describes a single problem
+type Finding struct {
+	Severity  string  `json:"severity"`
+	Check     string  `json:"check"`
+	Timestamp float64 `json:"timestamp,omitempty"`
+	Message   string  `json:"message"`
+}
+
+// Report is the result of a verification
+type Report struct {
+	EventId        int            `json:"eventId"`
+	EventKey       string         `json:"eventKey"`
+	Name           string         `json:"name"`
+	States         int            `json:"states"`
+	Speedmaps      int            `json:"speedmaps"`
+	DeltaChecks    bool           `json:"deltaChecks"` // false if only reconstructed states were checked
+	FirstTimestamp float64        `json:"firstTimestamp"`
+	LastTimestamp  float64        `json:"lastTimestamp"`
+	Counts         map[string]int `json:"counts"`   // number of findings by check (including suppressed ones)
+	Findings       []Finding      `json:"findings"` // at most Options.MaxFindings per check
+}
+
+// Errors returns the number of findings with severity error
+func (r *Report) Errors() int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+// Options of the verification
+type Options struct {
+	MaxGap          float64 // report gaps between states longer than this (seconds)
+	ReplayTolerance float64 // allowed difference between ReplayInfo and data (seconds)
+	MaxFindings     int     // maximum number of findings per check in the report (<= 0: unlimited)
+}
+
+// DefaultOptions returns the default options
+func DefaultOptions() Options {
+	return Options{MaxGap: 10, ReplayTolerance: 2, MaxFindings: 100}
+}
+
+// Verifier checks the data of an event. States and speedmaps are passed in order of retrieval.
+type Verifier struct {
+	event           *internal.Event
+	opts            Options
+	report          Report
+	prev            internal.State // last reconstructed state
+	havePrev        bool
+	sessionNumIdx   int
+	sessionTimeIdx  int
+	lastSessionNum  float64
+	lastSessionTime float64
+	minSessionTime  float64
+	maxSessionTime  float64
+	lastSpeedmap    float64
+	fetchFailed     bool // some data could not be fetched, missing data is not reported
+}
+
+// New creates a verifier for the event
+func New(event *internal.Event, opts Options) *Verifier {
+	v := &Verifier{
+		event: event,
+		opts:  opts,
+		report: Report{
+			EventId: int(event.Id), EventKey: event.EventKey, Name: event.Name,
+			Counts: map[string]int{}, Findings: []Finding{},
+		},
+		sessionNumIdx:   indexOf(event.Data.Manifests.Session, "sessionNum"),
+		sessionTimeIdx:  indexOf(event.Data.Manifests.Session, "sessionTime"),
+		lastSessionTime: math.Inf(-1),
+		minSessionTime:  math.Inf(1),
+		maxSessionTime:  math.Inf(-1),
+	}
+	return v
+}
+
+// Unreachable returns the report of an event which could not be fetched at all
+func Unreachable(eventId int, err error) *Report {
+	return &Report{
+		EventId: eventId,
+		Counts:  map[string]int{CheckUnreachable: 1},
+		Findings: []Finding{{
+			Severity: SeverityError, Check: CheckUnreachable, Message: fmt.Sprintf("error fetching event: %v", err),
+		}},
+	}
+}
+
+func indexOf(list []string, item string) int {
+	for i, v := range list {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
+
+func (v *Verifier) add(severity, check string, timestamp float64, format string, args ...interface{}) {
+	v.report.Counts[check]++
+	if v.opts.MaxFindings > 0 && v.report.Counts[check] > v.opts.MaxFindings {
+		return
+	}
+	v.report.Findings = append(v.report.Findings, Finding{
+		Severity: severity, Check: check, Timestamp: timestamp, Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// AddRawState checks a state as sent by the server (complete or delta) and reconstructs it.
+// Invalid deltas are reported and ignored for the reconstruction.
+func (v *Verifier) AddRawState(s internal.State) {
+	v.report.DeltaChecks = true
+	if s.Type == 2 {
+		if !v.havePrev {
+			v.add(SeverityError, CheckMissingFirstState, s.Timestamp, "delta state without preceding full state")
+		}
+		s.Payload.Cars = v.validCarDeltas(s)
+		s.Payload.Session = v.validSessionDeltas(s)
+	}
+	v.AddState(util.ProcessDeltaStates(v.prev, s))
+}
+
+func (v *Verifier) validCarDeltas(s internal.State) [][]interface{} {
+	numCols := len(v.event.Data.Manifests.Car)
+	numRows := len(v.prev.Payload.Cars)
+	ret := make([][]interface{}, 0, len(s.Payload.Cars))
+	for _, delta := range s.Payload.Cars {
+		if len(delta) != 3 || !isIndex(delta[0]) || !isIndex(delta[1]) {
+			v.add(SeverityError, CheckDeltaMalformed, s.Timestamp, "car delta %v is not [row, col, value]", delta)
+			continue
+		}
+		row, col := util.GetIntValue(delta[0]), util.GetIntValue(delta[1])
+		if col >= numCols {
+			v.add(SeverityError, CheckDeltaOutOfRange, s.Timestamp, "car delta %v: col %d outside of manifest (%d columns)", delta, col, numCols)
+			continue
+		}
+		if row >= numRows {
+			v.add(SeverityError, CheckDeltaOutOfRange, s.Timestamp, "car delta %v: row %d outside of previous state (%d rows)", delta, row, numRows)
+			continue
+		}
+		ret = append(ret, delta)
+	}
+	return ret
+}
+
+func (v *Verifier) validSessionDeltas(s internal.State) []interface{} {
+	numCols := len(v.event.Data.Manifests.Session)
+	ret := make([]interface{}, 0, len(s.Payload.Session))
+	for _, d := range s.Payload.Session {
+		delta, ok := d.([]interface{})
+		if !ok || len(delta) != 2 || !isIndex(delta[0]) {
+			v.add(SeverityError, CheckDeltaMalformed, s.Timestamp, "session delta %v is not [col, value]", d)
+			continue
+		}
+		if col := util.GetIntValue(delta[0]); col >= numCols {
+			v.add(SeverityError, CheckDeltaOutOfRange, s.Timestamp, "session delta %v: col %d outside of manifest (%d columns)", delta, col, numCols)
+			continue
+		}
+		ret = append(ret, delta)
+	}
+	return ret
+}
+
+// isIndex returns true if the value is a non-negative integral number
+func isIndex(value interface{}) bool {
+	n, err := util.ToInt(value)
+	return err == nil && n >= 0
+}
+
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// AddState checks a reconstructed (complete) state
+func (v *Verifier) AddState(s internal.State) {
+	r := &v.report
+	if r.States == 0 {
+		r.FirstTimestamp = s.Timestamp
+	} else {
+		switch {
+		case s.Timestamp < v.prev.Timestamp:
+			v.add(SeverityError, CheckTimestampOrder, s.Timestamp, "timestamp %.3f before previous %.3f", s.Timestamp, v.prev.Timestamp)
+		case s.Timestamp == v.prev.Timestamp:
+			v.add(SeverityWarning, CheckDuplicate, s.Timestamp, "duplicate timestamp %.3f", s.Timestamp)
+		case v.opts.MaxGap > 0 && s.Timestamp-v.prev.Timestamp > v.opts.MaxGap:
+			v.add(SeverityWarning, CheckGap, s.Timestamp, "no data for %.1fs after %.3f", s.Timestamp-v.prev.Timestamp, v.prev.Timestamp)
+		}
+	}
+	r.States++
+	if s.Timestamp > r.LastTimestamp {
+		r.LastTimestamp = s.Timestamp
+	}
+
+	manifests := v.event.Data.Manifests
+	for i, row := range s.Payload.Cars {
+		if len(row) != len(manifests.Car) {
+			v.add(SeverityError, CheckCarRowLength, s.Timestamp, "car row %d has %d values, manifest has %d columns", i, len(row), len(manifests.Car))
+		}
+	}
+	if len(s.Payload.Session) != len(manifests.Session) {
+		v.add(SeverityError, CheckSessionRowLength, s.Timestamp, "session row has %d values, manifest has %d columns", len(s.Payload.Session), len(manifests.Session))
+	}
+	v.checkSessionTime(s)
+	v.prev = s
+	v.havePrev = true
+}
+
+func (v *Verifier) checkSessionTime(s internal.State) {
+	if v.sessionTimeIdx < 0 || v.sessionTimeIdx >= len(s.Payload.Session) {
+		return
+	}
+	st, ok := number(s.Payload.Session[v.sessionTimeIdx])
+	if !ok {
+		return
+	}
+	sessionNum := v.lastSessionNum
+	if v.sessionNumIdx >= 0 && v.sessionNumIdx < len(s.Payload.Session) {
+		if n, ok := number(s.Payload.Session[v.sessionNumIdx]); ok {
+			sessionNum = n
+		}
+	}
+	if sessionNum == v.lastSessionNum && st < v.lastSessionTime {
+		v.add(SeverityWarning, CheckSessionTime, s.Timestamp, "session time %.3f before previous %.3f", st, v.lastSessionTime)
+	}
+	v.lastSessionNum = sessionNum
+	v.lastSessionTime = st
+	v.minSessionTime = math.Min(v.minSessionTime, st)
+	v.maxSessionTime = math.Max(v.maxSessionTime, st)
+}
+
+// AddSpeedmap checks a speedmap message
+func (v *Verifier) AddSpeedmap(s *internal.SpeedmapMessage) {
+	if v.report.Speedmaps > 0 && s.Timestamp <= v.lastSpeedmap {
+		v.add(SeverityError, CheckSpeedmapOrder, s.Timestamp, "speedmap timestamp %.3f not after previous %.3f", s.Timestamp, v.lastSpeedmap)
+	}
+	v.lastSpeedmap = s.Timestamp
+	v.report.Speedmaps++
+}
+
+// FetchFailed records that the data described by what (e.g. "states") could not be fetched
+func (v *Verifier) FetchFailed(what string, err error) {
+	v.fetchFailed = true
+	v.add(SeverityError, CheckUnreachable, 0, "error fetching %s: %v", what, err)
+}
+
+// raceloggerVersion returns the racelogger version of the event and if it provides car data and speedmaps
+func (v *Verifier) raceloggerVersion() (semver.Version, bool) {
+	version, err := semver.ParseTolerant(util.GetEventRaceloggerVersion(v.event))
+	return version, err == nil && carDataVersions(version)
+}
+
+// SetCarData checks the car data of the event. err is the error returned when requesting the car data.
+func (v *Verifier) SetCarData(carData *internal.EventCarMessage, err error) {
+	version, ok := v.raceloggerVersion()
+	if !ok {
+		return
+	}
+	if err != nil || carData == nil || len(carData.Payload.Entries) == 0 {
+		msg := "no entries"
+		if err != nil {
+			msg = err.Error()
+		}
+		v.add(SeverityError, CheckMissingCarData, 0, "racelogger %s should provide car data: %s", version, msg)
+	}
+}
+
+// Report finishes the verification and returns the report
+func (v *Verifier) Report() *Report {
+	r := &v.report
+	if r.States == 0 {
+		if !v.fetchFailed {
+			v.add(SeverityError, CheckNoData, 0, "event has no states")
+		}
+		return r
+	}
+	if version, ok := v.raceloggerVersion(); ok && r.Speedmaps == 0 && !v.fetchFailed {
+		v.add(SeverityError, CheckMissingSpeedmaps, 0, "racelogger %s should provide speedmaps", version)
+	}
+	replay := v.event.Data.ReplayInfo
+	tolerance := v.opts.ReplayTolerance
+	if math.Abs(replay.MinTimestamp-r.FirstTimestamp) > tolerance {
+		v.add(SeverityWarning, CheckReplayInfo, 0, "minTimestamp %.3f, first state at %.3f", replay.MinTimestamp, r.FirstTimestamp)
+	}
+	if !math.IsInf(v.minSessionTime, 1) {
+		if math.Abs(replay.MinSessionTime-v.minSessionTime) > tolerance {
+			v.add(SeverityWarning, CheckReplayInfo, 0, "minSessionTime %.3f, data starts at %.3f", replay.MinSessionTime, v.minSessionTime)
+		}
+		if math.Abs(replay.MaxSessionTime-v.maxSessionTime) > tolerance {
+			v.add(SeverityWarning, CheckReplayInfo, 0, "maxSessionTime %.3f, data ends at %.3f", replay.MaxSessionTime, v.maxSessionTime)
+		}
+	}
+	return r
+}
@@ -0,0 +1,179 @@
+package verify
+
+import (
+	"errors"
+	"racelogctl/internal"
+	"racelogctl/synth"
+	"testing"
+	"time"
+)
+
+func testEvent() (*internal.Event, []internal.State, []*internal.SpeedmapMessage, *internal.EventCarMessage) {
+	p := synth.DefaultParams()
+	p.Cars = 4
+	p.RaceLength = 5 * time.Minute
+	p.LapTime = 60
+	p.StartTime = time.Date(2022, 8, 20, 8, 0, 0, 0, time.UTC)
+	g := synth.New(p)
+	states := []internal.State{}
+	speedmaps := []*internal.SpeedmapMessage{}
+	for {
+		s, sm, ok := g.Next()
+		if !ok {
+			break
+		}
+		states = append(states, s)
+		if sm != nil {
+			speedmaps = append(speedmaps, sm)
+		}
+	}
+	return g.Event(), states, speedmaps, g.CarData()
+}
+
+func checks(r *Report, severity string) map[string]int {
+	ret := map[string]int{}
+	for _, f := range r.Findings {
+		if f.Severity == severity {
+			ret[f.Check]++
+		}
+	}
+	return ret
+}
+
+func TestValidEvent(t *testing.T) {
+	event, states, speedmaps, carData := testEvent()
+	event.Data.ReplayInfo.MinTimestamp = states[0].Timestamp
+	v := New(event, DefaultOptions())
+	for _, s := range states {
+		v.AddState(s)
+	}
+	for _, s := range speedmaps {
+		v.AddSpeedmap(s)
+	}
+	v.SetCarData(carData, nil)
+	r := v.Report()
+	if r.Errors() != 0 || len(checks(r, SeverityWarning)) > 1 {
+		t.Errorf("unexpected findings %+v", r.Findings)
+	}
+	if r.States != len(states) || r.Speedmaps != len(speedmaps) || r.DeltaChecks {
+		t.Errorf("unexpected report %+v", r)
+	}
+}
+
+func TestRawDeltas(t *testing.T) {
+	event := &internal.Event{}
+	event.Data.Manifests = internal.Manifests{Car: []string{"carIdx", "lap"}, Session: []string{"sessionNum", "sessionTime"}}
+	v := New(event, DefaultOptions())
+	v.AddRawState(internal.State{Type: 2, Timestamp: 1, Payload: internal.Payload{Cars: [][]interface{}{}}})
+	v.AddRawState(internal.State{Type: 1, Timestamp: 2, Payload: internal.Payload{
+		Cars:    [][]interface{}{{0.0, 1.0}, {1.0, 1.0}},
+		Session: []interface{}{0.0, 100.0},
+	}})
+	v.AddRawState(internal.State{Type: 2, Timestamp: 3, Payload: internal.Payload{
+		Cars:    [][]interface{}{{1.0, 1.0, 2.0}, {2.0, 1.0, 2.0}, {0.0, 5.0, 1.0}, {0.0, 1.0}, {-1.0, 0.0, 1.0}},
+		Session: []interface{}{[]interface{}{1.0, 99.0}, []interface{}{3.0, 1.0}, "bad"},
+	}})
+	v.AddRawState(internal.State{Type: 2, Timestamp: 2.5, Payload: internal.Payload{}})
+	v.AddRawState(internal.State{Type: 2, Timestamp: 30, Payload: internal.Payload{}})
+	r := v.Report()
+	errors := checks(r, SeverityError)
+	warnings := checks(r, SeverityWarning)
+	want := map[string]int{
+		CheckMissingFirstState: 1,
+		CheckDeltaOutOfRange:   3, // row 2, col 5, session col 3
+		CheckDeltaMalformed:    3, // 2 values, negative row, no list
+		CheckTimestampOrder:    1,
+		CheckSessionRowLength:  1, // the first delta state without full state
+	}
+	for check, n := range want {
+		if errors[check] != n {
+			t.Errorf("%s: got %d errors, want %d (%+v)", check, errors[check], n, r.Findings)
+		}
+	}
+	if warnings[CheckSessionTime] != 1 || warnings[CheckGap] != 1 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+	if !r.DeltaChecks || r.States != 5 {
+		t.Errorf("unexpected report %+v", r)
+	}
+}
+
+func TestRowLengthAndReplayInfo(t *testing.T) {
+	event, states, _, _ := testEvent()
+	event.Data.ReplayInfo.MaxSessionTime = 10000
+	states[3].Payload.Cars[0] = states[3].Payload.Cars[0][:3]
+	v := New(event, DefaultOptions())
+	for _, s := range states {
+		v.AddState(s)
+	}
+	r := v.Report()
+	if checks(r, SeverityError)[CheckCarRowLength] != 1 {
+		t.Errorf("expected car row length error: %+v", r.Findings)
+	}
+	if checks(r, SeverityWarning)[CheckReplayInfo] == 0 {
+		t.Errorf("expected replay info warning: %+v", r.Findings)
+	}
+}
+
+func TestMissingCarData(t *testing.T) {
+	event, _, _, _ := testEvent()
+	for version, want := range map[string]int{"0.4.3": 0, "0.4.4": 1, "v0.6.0": 1, "": 0} {
+		event.Data.Info.RaceloggerVersion = version
+		v := New(event, DefaultOptions())
+		v.SetCarData(&internal.EventCarMessage{}, nil)
+		if got := v.report.Counts[CheckMissingCarData]; got != want {
+			t.Errorf("version %q: got %d findings, want %d", version, got, want)
+		}
+	}
+}
+
+func TestMissingSpeedmaps(t *testing.T) {
+	event, states, speedmaps, _ := testEvent()
+	for version, want := range map[string]int{"0.4.3": 0, "0.4.4": 1, "": 0} {
+		event.Data.Info.RaceloggerVersion = version
+		v := New(event, DefaultOptions())
+		for _, s := range states {
+			v.AddState(s)
+		}
+		if got := v.Report().Counts[CheckMissingSpeedmaps]; got != want {
+			t.Errorf("version %q: got %d findings, want %d", version, got, want)
+		}
+	}
+	v := New(event, DefaultOptions())
+	for _, s := range states {
+		v.AddState(s)
+	}
+	v.AddSpeedmap(speedmaps[0])
+	if got := v.Report().Counts[CheckMissingSpeedmaps]; got != 0 {
+		t.Errorf("got %d findings with speedmaps", got)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	r := Unreachable(7, errors.New("timeout"))
+	if r.EventId != 7 || r.Errors() != 1 || r.Findings[0].Check != CheckUnreachable {
+		t.Errorf("unexpected report %+v", r)
+	}
+	event, _, _, _ := testEvent()
+	event.Data.Info.RaceloggerVersion = "0.4.4"
+	v := New(event, DefaultOptions())
+	v.FetchFailed("states", errors.New("timeout"))
+	r = v.Report()
+	if r.Counts[CheckUnreachable] != 1 || r.Counts[CheckNoData] != 0 || r.Counts[CheckMissingSpeedmaps] != 0 {
+		t.Errorf("unexpected counts %v", r.Counts)
+	}
+}
+
+func TestMaxFindings(t *testing.T) {
+	event := &internal.Event{}
+	opts := DefaultOptions()
+	opts.MaxFindings = 2
+	v := New(event, opts)
+	for i := 0; i < 5; i++ {
+		v.AddState(internal.State{Timestamp: 1})
+	}
+	r := v.Report()
+	if r.Counts[CheckDuplicate] != 4 || checks(r, SeverityWarning)[CheckDuplicate] != 2 {
+		t.Errorf("unexpected counts %v / findings %+v", r.Counts, r.Findings)
+	}
+}
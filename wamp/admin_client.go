@@ -3,8 +3,6 @@ package wamp
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
 	"racelogctl/internal"
 
 	"github.com/gammazero/nexus/v3/client"
@@ -20,19 +18,12 @@ type AdminClient struct {
 	client *client.Client
 }
 
-func NewAdminClient(url string, realm string, ticket string) *AdminClient {
-	logger := log.New(os.Stdout, "", 0)
-
-	cfg := client.Config{
-		Realm:        realm,
-		Logger:       logger,
-		HelloDetails: wamp.Dict{"authid": "admin"}, // TODO
-		AuthHandlers: map[string]client.AuthFunc{
-			"ticket": func(*wamp.Challenge) (string, wamp.Dict) { return ticket, wamp.Dict{} },
-		}}
-
-	ret := &AdminClient{client: GetClientWithConfigNew(url, &cfg)}
-	return ret
+// NewAdminClient connects with the credentials. The authid defaults to "admin".
+func NewAdminClient(url string, realm string, credentials Credentials) *AdminClient {
+	if credentials.AuthId == "" {
+		credentials.AuthId = "admin"
+	}
+	return &AdminClient{client: authenticatedClient(url, realm, credentials)}
 }
 
 func (ac *AdminClient) Close() {
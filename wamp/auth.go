@@ -0,0 +1,157 @@
+package wamp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/wamp"
+	"github.com/gammazero/nexus/v3/wamp/crsign"
+)
+
+// Supported authentication methods
+const (
+	AuthTicket    = "ticket"
+	AuthWampCra   = "wampcra"
+	AuthAnonymous = "anonymous"
+)
+
+// AuthMethods lists the supported authentication methods
+var AuthMethods = []string{AuthTicket, AuthWampCra, AuthAnonymous}
+
+// Credentials describe how a client authenticates. The secret is taken from the first
+// non-empty source of Password, PasswordFile and PasswordCmd.
+type Credentials struct {
+	AuthId       string `mapstructure:"authid"`
+	AuthMethod   string `mapstructure:"authmethod"` // ticket (default), wampcra or anonymous
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"password_file"` // file containing the secret
+	PasswordCmd  string `mapstructure:"password_cmd"`  // command printing the secret, e.g. "pass show racelog/admin"
+}
+
+// Merge returns c with the empty attributes taken from other
+func (c Credentials) Merge(other Credentials) Credentials {
+	first := func(a, b string) string {
+		if a != "" {
+			return a
+		}
+		return b
+	}
+	ret := Credentials{
+		AuthId:     first(c.AuthId, other.AuthId),
+		AuthMethod: first(c.AuthMethod, other.AuthMethod),
+	}
+	// the secret sources are taken as a whole, so a password file of the config
+	// does not override a password given on the command line
+	if c.Password != "" || c.PasswordFile != "" || c.PasswordCmd != "" {
+		ret.Password, ret.PasswordFile, ret.PasswordCmd = c.Password, c.PasswordFile, c.PasswordCmd
+	} else {
+		ret.Password, ret.PasswordFile, ret.PasswordCmd = other.Password, other.PasswordFile, other.PasswordCmd
+	}
+	return ret
+}
+
+// Secret returns the secret from the first configured source
+func (c Credentials) Secret() (string, error) {
+	switch {
+	case c.Password != "":
+		return c.Password, nil
+	case c.PasswordFile != "":
+		return readPasswordFile(c.PasswordFile)
+	case c.PasswordCmd != "":
+		return runPasswordCmd(c.PasswordCmd)
+	}
+	return "", nil
+}
+
+func readPasswordFile(filename string) (string, error) {
+	if strings.HasPrefix(filename, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			filename = home + filename[1:]
+		}
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", fmt.Errorf("password file: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		log.Printf("Warning: password file %s is accessible by other users (mode %v)\n", filename, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("password file: %w", err)
+	}
+	// only the first line is used
+	secret, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimRight(secret, "\r"), nil
+}
+
+func runPasswordCmd(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password command: %w", err)
+	}
+	secret, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimRight(secret, "\r"), nil
+}
+
+// apply configures the authentication of the client config
+func (c Credentials) apply(cfg *client.Config) error {
+	method := strings.ToLower(c.AuthMethod)
+	if method == "" {
+		method = AuthTicket
+	}
+	cfg.HelloDetails = wamp.Dict{}
+	if c.AuthId != "" {
+		cfg.HelloDetails["authid"] = c.AuthId
+	}
+	switch method {
+	case AuthAnonymous:
+		cfg.HelloDetails["authmethods"] = wamp.List{AuthAnonymous}
+		return nil
+	case AuthTicket, AuthWampCra:
+	default:
+		return fmt.Errorf("unknown authmethod %q (use one of %s)", c.AuthMethod, strings.Join(AuthMethods, ", "))
+	}
+	secret, err := c.Secret()
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return errors.New("no password given for authmethod " + method)
+	}
+	if method == AuthTicket {
+		cfg.AuthHandlers = map[string]client.AuthFunc{
+			AuthTicket: func(*wamp.Challenge) (string, wamp.Dict) { return secret, wamp.Dict{} },
+		}
+	} else {
+		cfg.AuthHandlers = map[string]client.AuthFunc{
+			AuthWampCra: func(c *wamp.Challenge) (string, wamp.Dict) {
+				return crsign.RespondChallenge(secret, c, nil), wamp.Dict{}
+			},
+		}
+	}
+	return nil
+}
+
+// authenticatedClient connects a client with the credentials
+func authenticatedClient(url, realm string, credentials Credentials) *client.Client {
+	logger := log.New(os.Stdout, "", 0)
+	cfg := client.Config{Realm: realm, Logger: logger}
+	if err := credentials.apply(&cfg); err != nil {
+		logger.Fatal(err)
+	}
+	return GetClientWithConfigNew(url, &cfg)
+}
@@ -0,0 +1,144 @@
+package wamp
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/router/auth"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestCredentialsMerge(t *testing.T) {
+	flags := Credentials{AuthMethod: "wampcra", Password: "flag"}
+	config := Credentials{AuthId: "admin2", AuthMethod: "ticket", PasswordFile: "/secret"}
+	got := flags.Merge(config)
+	want := Credentials{AuthId: "admin2", AuthMethod: "wampcra", Password: "flag"}
+	if got != want {
+		t.Errorf("Merge() = %+v, want %+v", got, want)
+	}
+	got = Credentials{}.Merge(config)
+	if got != config {
+		t.Errorf("Merge() = %+v, want %+v", got, config)
+	}
+}
+
+func TestCredentialsSecret(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pw")
+	os.WriteFile(file, []byte("fromFile\nsecond line\n"), 0o600)
+	tests := []struct {
+		name    string
+		c       Credentials
+		want    string
+		wantErr bool
+	}{
+		{name: "password", c: Credentials{Password: "pw", PasswordFile: file}, want: "pw"},
+		{name: "file", c: Credentials{PasswordFile: file}, want: "fromFile"},
+		{name: "missing file", c: Credentials{PasswordFile: filepath.Join(dir, "missing")}, wantErr: true},
+		{name: "command", c: Credentials{PasswordCmd: "echo fromCmd"}, want: "fromCmd"},
+		{name: "failing command", c: Credentials{PasswordCmd: "exit 3"}, wantErr: true},
+		{name: "none", c: Credentials{}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" && tt.c.PasswordCmd != "" {
+				t.Skip("requires sh")
+			}
+			got, err := tt.c.Secret()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Secret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Secret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialsApply(t *testing.T) {
+	var cfg client.Config
+	if err := (Credentials{AuthMethod: "anonymous"}).apply(&cfg); err != nil || cfg.AuthHandlers != nil {
+		t.Errorf("apply(anonymous) = %v, handlers %v", err, cfg.AuthHandlers)
+	}
+	if err := (Credentials{AuthId: "x", Password: "pw"}).apply(&cfg); err != nil || cfg.AuthHandlers[AuthTicket] == nil || cfg.HelloDetails["authid"] != "x" {
+		t.Errorf("apply(ticket) = %v, config %+v", err, cfg)
+	}
+	if err := (Credentials{}).apply(&cfg); err == nil {
+		t.Error("apply() without password expected error")
+	}
+	if err := (Credentials{AuthMethod: "cryptosign", Password: "pw"}).apply(&cfg); err == nil {
+		t.Error("apply(cryptosign) expected error")
+	}
+}
+
+// testKeyStore holds the passwords of the test users
+type testKeyStore map[string]string
+
+func (ks testKeyStore) AuthKey(authid, authmethod string) ([]byte, error) {
+	if pw, ok := ks[authid]; ok {
+		return []byte(pw), nil
+	}
+	return nil, errors.New("unknown user")
+}
+func (ks testKeyStore) PasswordInfo(authid string) (string, int, int) { return "", 0, 0 }
+func (ks testKeyStore) AuthRole(authid string) (string, error)        { return authid, nil }
+func (ks testKeyStore) Provider() string                              { return "test" }
+
+func TestConnectAuthenticated(t *testing.T) {
+	keys := testKeyStore{"admin": "adminSecret", "dataprovider": "dpSecret"}
+	r, err := router.NewRouter(&router.Config{
+		RealmConfigs: []*router.RealmConfig{{
+			URI:            wamp.URI("racelog"),
+			AnonymousAuth:  true,
+			Authenticators: []auth.Authenticator{auth.NewTicketAuthenticator(keys, 0), auth.NewCRAuthenticator(keys, 0)},
+		}},
+	}, log.New(os.Stderr, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	socket := filepath.Join(t.TempDir(), "raw.sock")
+	closer, err := router.NewRawSocketServer(r).ListenAndServe("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	tests := []struct {
+		name     string
+		c        Credentials
+		wantRole string
+		wantErr  bool
+	}{
+		{name: "ticket", c: Credentials{AuthId: "admin", Password: "adminSecret"}, wantRole: "admin"},
+		{name: "wampcra", c: Credentials{AuthId: "dataprovider", AuthMethod: "wampcra", Password: "dpSecret"}, wantRole: "dataprovider"},
+		{name: "anonymous", c: Credentials{AuthMethod: "anonymous"}, wantRole: "anonymous"},
+		{name: "wrong ticket", c: Credentials{AuthId: "admin", Password: "wrong"}, wantErr: true},
+		{name: "wrong wampcra", c: Credentials{AuthId: "admin", AuthMethod: "wampcra", Password: "wrong"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := client.Config{Realm: "racelog", Logger: log.New(os.Stderr, "", 0)}
+			if err := tt.c.apply(&cfg); err != nil {
+				t.Fatal(err)
+			}
+			c, err := connect("unix://"+socket, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if c == nil {
+				return
+			}
+			defer c.Close()
+			if role, _ := wamp.AsString(c.RealmDetails()["authrole"]); role != tt.wantRole {
+				t.Errorf("authrole = %s, want %s", role, tt.wantRole)
+			}
+		})
+	}
+}
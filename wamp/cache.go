@@ -0,0 +1,237 @@
+package wamp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"racelogctl/cache"
+	"racelogctl/internal"
+	"sort"
+)
+
+// ErrOffline is returned if data is requested in offline mode which is not available in the cache
+var ErrOffline = errors.New("not available in offline mode")
+
+var dataCache *cache.Cache // if set, archived event data is read from and stored in this cache
+var offline bool           // if true, no server connection is made. Data is served from the cache only.
+
+// UseCache configures the cache for all public clients created afterwards.
+// In offline mode no server connections are made and only cached data is available.
+func UseCache(c *cache.Cache, offlineMode bool) {
+	dataCache = c
+	offline = offlineMode
+}
+
+// cachedData holds the states and speedmaps of an event loaded from the cache
+type cachedData struct {
+	states    []internal.State
+	speedmaps []*internal.SpeedmapMessage
+	loaded    map[string]bool // items already read from the cache (even if not cached)
+}
+
+func offlineError(what string, id int) error {
+	return fmt.Errorf("%s %d: %w", what, id, ErrOffline)
+}
+
+// GetEvent returns the event info. Finished events are cached.
+func (pc *PublicClient) GetEvent(eventId int) (*internal.Event, error) {
+	if dataCache != nil {
+		var e internal.Event
+		if ok, err := dataCache.Get(pc.url, eventId, cache.ItemEvent, &e); ok {
+			return &e, nil
+		} else if err != nil {
+			log.Printf("Ignoring cache: %v\n", err)
+		}
+	}
+	if pc.client == nil {
+		return nil, offlineError("event", eventId)
+	}
+	e, err := pc.fetchEvent(eventId)
+	if err == nil && dataCache != nil && pc.isFinished(e) {
+		if err := dataCache.Put(pc.url, eventId, cache.ItemEvent, e); err != nil {
+			log.Printf("Error writing cache: %v\n", err)
+		}
+		dataCache.SetName(pc.url, eventId, e.Name)
+	}
+	return e, err
+}
+
+// isFinished returns true if the event is not recorded at the moment
+func (pc *PublicClient) isFinished(e *internal.Event) bool {
+	providers, err := pc.ProviderList()
+	if err != nil {
+		return false
+	}
+	for _, p := range providers {
+		if p.EventKey == e.EventKey {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheable returns true if the data of the event may be stored in the cache
+func (pc *PublicClient) cacheable(eventId int) bool {
+	if dataCache == nil {
+		return false
+	}
+	if !dataCache.Has(pc.url, eventId, cache.ItemEvent) {
+		pc.GetEvent(eventId) // caches the event if it is finished
+	}
+	return dataCache.Has(pc.url, eventId, cache.ItemEvent)
+}
+
+// GetTrack returns the track info. Tracks are cached.
+func (pc *PublicClient) GetTrack(id int) (*internal.TrackInfo, error) {
+	var cached *internal.TrackInfo
+	if dataCache != nil {
+		var t internal.TrackInfo
+		if ok, expired, _ := dataCache.GetTrack(pc.url, id, &t); ok {
+			if !expired || pc.client == nil {
+				return &t, nil
+			}
+			cached = &t
+		}
+	}
+	if pc.client == nil {
+		return nil, offlineError("track", id)
+	}
+	t, err := pc.fetchTrack(id)
+	if err != nil && cached != nil {
+		log.Printf("Error refreshing track %d, using cached data: %v\n", id, err)
+		return cached, nil
+	}
+	if err == nil && dataCache != nil {
+		if err := dataCache.PutTrack(pc.url, id, t); err != nil {
+			log.Printf("Error writing cache: %v\n", err)
+		}
+	}
+	return t, err
+}
+
+// GetEventList returns the events of the server. In offline mode the cached events are returned.
+func (pc *PublicClient) GetEventList() ([]*internal.Event, error) {
+	if pc.client != nil {
+		return pc.fetchEventList()
+	}
+	if dataCache == nil {
+		return nil, ErrOffline
+	}
+	entries, err := dataCache.List(pc.url)
+	if err != nil {
+		return nil, err
+	}
+	ret := []*internal.Event{}
+	for _, entry := range entries {
+		var e internal.Event
+		if ok, _ := dataCache.Get(pc.url, entry.EventId, cache.ItemEvent, &e); ok {
+			ret = append(ret, &e)
+		}
+	}
+	return ret, nil
+}
+
+// GetCarData returns the car data of the event. The data of finished events is cached.
+func (pc *PublicClient) GetCarData(eventId int) (*internal.EventCarMessage, error) {
+	if dataCache != nil {
+		var c internal.EventCarMessage
+		if ok, _ := dataCache.Get(pc.url, eventId, cache.ItemCars, &c); ok {
+			return &c, nil
+		}
+	}
+	if pc.client == nil {
+		return nil, offlineError("car data of event", eventId)
+	}
+	c, err := pc.fetchCarData(eventId)
+	if err == nil && pc.cacheable(eventId) {
+		if err := dataCache.Put(pc.url, eventId, cache.ItemCars, c); err != nil {
+			log.Printf("Error writing cache: %v\n", err)
+		}
+	}
+	return c, err
+}
+
+// cachedStates returns the cached states of the event (nil if not cached).
+// The cache file is read on first use.
+func (pc *PublicClient) cachedStates(eventId int) []internal.State {
+	return cachedItems(pc, eventId, cache.ItemStates, func(d *cachedData) *[]internal.State { return &d.states })
+}
+
+// cachedSpeedmaps returns the cached speedmaps of the event (nil if not cached).
+// The cache file is read on first use.
+func (pc *PublicClient) cachedSpeedmaps(eventId int) []*internal.SpeedmapMessage {
+	return cachedItems(pc, eventId, cache.ItemSpeedmaps, func(d *cachedData) *[]*internal.SpeedmapMessage { return &d.speedmaps })
+}
+
+func cachedItems[T any](pc *PublicClient, eventId int, item string, field func(*cachedData) *[]T) []T {
+	if dataCache == nil {
+		return nil
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	data, ok := pc.cached[eventId]
+	if !ok {
+		data = &cachedData{loaded: map[string]bool{}}
+		pc.cached[eventId] = data
+	}
+	items := field(data)
+	if !data.loaded[item] {
+		data.loaded[item] = true
+		values, ok, err := cache.GetItems[T](dataCache, pc.url, eventId, item)
+		if err != nil {
+			log.Printf("Ignoring cache: %v\n", err)
+		}
+		if ok {
+			*items = values
+		}
+	}
+	return *items
+}
+
+// cachedPage returns up to num items with a timestamp >= start
+func cachedPage[T any](items []T, timestamp func(T) float64, start float64, num int) []T {
+	idx := sort.Search(len(items), func(i int) bool { return timestamp(items[i]) >= start })
+	end := idx + num
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[idx:end]
+}
+
+func (pc *PublicClient) getStates(id int, start float64, num int) ([]internal.State, error) {
+	if states := pc.cachedStates(id); states != nil {
+		return cachedPage(states, func(s internal.State) float64 { return s.Timestamp }, start, num), nil
+	}
+	if pc.client == nil {
+		return nil, offlineError("states of event", id)
+	}
+	return pc.fetchStates(id, start, num)
+}
+
+func (pc *PublicClient) GetSpeedmaps(id int, start float64, num int) ([]*internal.SpeedmapMessage, error) {
+	if speedmaps := pc.cachedSpeedmaps(id); speedmaps != nil {
+		return cachedPage(speedmaps, func(s *internal.SpeedmapMessage) float64 { return s.Timestamp }, start, num), nil
+	}
+	if pc.client == nil {
+		return nil, offlineError("speedmaps of event", id)
+	}
+	return pc.fetchSpeedmaps(id, start, num)
+}
+
+// cacheWriter returns a writer which stores the items of an iteration in the cache.
+// Returns nil if the event is not cacheable or the iteration does not start at the beginning of the event.
+func cacheWriter[T any](pc *PublicClient, eventId int, start float64, item string) *cache.ItemWriter[T] {
+	if dataCache == nil || pc.client == nil || !pc.cacheable(eventId) {
+		return nil
+	}
+	var e internal.Event
+	if ok, _ := dataCache.Get(pc.url, eventId, cache.ItemEvent, &e); !ok || start > e.Data.ReplayInfo.MinTimestamp {
+		return nil
+	}
+	w, err := cache.NewItemWriter[T](dataCache, pc.url, eventId, item)
+	if err != nil {
+		log.Printf("Error writing cache: %v\n", err)
+		return nil
+	}
+	return w
+}
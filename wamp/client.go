@@ -1,7 +1,6 @@
 package wamp
 
 import (
-	"context"
 	"log"
 	"os"
 
@@ -11,9 +10,12 @@ import (
 var logger = log.New(os.Stdout, "client", 0)
 
 func GetClientWithConfigNew(url string, cfg *client.Config) *client.Client {
+	if cfg.Serialization == 0 {
+		cfg.Serialization = serialization
+	}
 
 	// Connect wampClient session.
-	wampClient, err := client.ConnectNet(context.Background(), url, *cfg)
+	wampClient, err := connect(url, *cfg)
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -3,8 +3,6 @@ package wamp
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
 	"racelogctl/internal"
 
 	"github.com/gammazero/nexus/v3/client"
@@ -19,19 +17,12 @@ type DataProviderClient struct {
 	client *client.Client
 }
 
-func NewDataProviderClient(url string, realm string, ticket string) *DataProviderClient {
-	logger := log.New(os.Stdout, "", 0)
-
-	cfg := client.Config{
-		Realm:        realm,
-		Logger:       logger,
-		HelloDetails: wamp.Dict{"authid": "dataprovider"}, // TODO
-		AuthHandlers: map[string]client.AuthFunc{
-			"ticket": func(*wamp.Challenge) (string, wamp.Dict) { return ticket, wamp.Dict{} },
-		}}
-
-	ret := &DataProviderClient{client: GetClientWithConfigNew(url, &cfg)}
-	return ret
+// NewDataProviderClient connects with the credentials. The authid defaults to "dataprovider".
+func NewDataProviderClient(url string, realm string, credentials Credentials) *DataProviderClient {
+	if credentials.AuthId == "" {
+		credentials.AuthId = "dataprovider"
+	}
+	return &DataProviderClient{client: authenticatedClient(url, realm, credentials)}
 }
 
 func (dpc *DataProviderClient) Close() {
@@ -53,51 +44,38 @@ func (dpc *DataProviderClient) UnregisterProvider(eventKey string) error {
 	return err
 }
 
-// recieves data via channel and publishes it on the racelog.public.live.state.<eventKey> topic
-func (dpc *DataProviderClient) PublishStateFromChannel(eventKey string, rcv chan internal.State) {
-
-	go func() {
-		for {
-			s, more := <-rcv
-			err := dpc.client.Publish(fmt.Sprintf("racelog.public.live.state.%s", eventKey), nil, wamp.List{s}, nil)
-			if err != nil {
-				log.Fatal(err)
-			}
-			// fmt.Printf("chanValue: %v more: %v\n", s.Timestamp, more)
-			// time.Sleep(100 * time.Millisecond)
-			if !more {
-				fmt.Println("closed channel signaled")
-				return
-			}
-		}
-	}()
-
+// recieves data via channel and publishes it on the racelog.public.live.state.<eventKey> topic.
+// The returned channel receives the first publish error (if any) and is closed after rcv was
+// closed and all received data is published. After an error the remaining data is discarded.
+func (dpc *DataProviderClient) PublishStateFromChannel(eventKey string, rcv chan internal.State) <-chan error {
+	return publishFromChannel(dpc.client, fmt.Sprintf("racelog.public.live.state.%s", eventKey), rcv)
 }
 
-func (dpc *DataProviderClient) PublishCarData(eventKey string, carData *internal.EventCarMessage) {
-
-	err := dpc.client.Publish(fmt.Sprintf("racelog.public.live.cardata.%s", eventKey), nil, wamp.List{carData}, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
+func (dpc *DataProviderClient) PublishCarData(eventKey string, carData *internal.EventCarMessage) error {
+	return dpc.client.Publish(fmt.Sprintf("racelog.public.live.cardata.%s", eventKey), nil, wamp.List{carData}, nil)
+}
 
+// recieves data via channel and publishes it on the racelog.public.live.speedmap.<eventKey> topic.
+// The returned channel behaves like the one of PublishStateFromChannel.
+func (dpc *DataProviderClient) PublishSpeedmapDataFromChannel(eventKey string, rcv chan internal.SpeedmapMessage) <-chan error {
+	return publishFromChannel(dpc.client, fmt.Sprintf("racelog.public.live.speedmap.%s", eventKey), rcv)
 }
-func (dpc *DataProviderClient) PublishSpeedmapDataFromChannel(eventKey string, rcv chan internal.SpeedmapMessage) {
 
+// publishFromChannel publishes the received data on the topic until rcv is closed.
+// The rest of the data is still received after an error, so the sender is not blocked.
+func publishFromChannel[T any](c *client.Client, topic string, rcv <-chan T) <-chan error {
+	done := make(chan error, 1)
 	go func() {
-		for {
-			s, more := <-rcv
-			err := dpc.client.Publish(fmt.Sprintf("racelog.public.live.speedmap.%s", eventKey), nil, wamp.List{s}, nil)
-			if err != nil {
-				log.Fatal(err)
-			}
-			// fmt.Printf("chanValue: %v more: %v\n", s.Timestamp, more)
-			// time.Sleep(100 * time.Millisecond)
-			if !more {
-				fmt.Println("closed channel signaled")
-				return
+		defer close(done)
+		var err error
+		for s := range rcv {
+			if err == nil {
+				err = c.Publish(topic, nil, wamp.List{s}, nil)
 			}
 		}
+		if err != nil {
+			done <- err
+		}
 	}()
-
+	return done
 }
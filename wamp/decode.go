@@ -0,0 +1,184 @@
+package wamp
+
+import (
+	"encoding/json"
+	"fmt"
+	"racelogctl/internal"
+	"reflect"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// decodeState converts a state as received by the WAMP client (dict or map) into an internal.State
+// without a JSON round trip. Values are normalized as encoding/json would do: numbers become
+// float64, lists []interface{} and dicts map[string]interface{}.
+func decodeState(v interface{}) (internal.State, error) {
+	s := internal.State{}
+	d, ok := asDict(v)
+	if !ok {
+		return s, fmt.Errorf("state: expected dict, got %T", v)
+	}
+	if t, ok := d["type"]; ok {
+		f, ok := toFloat(t)
+		if !ok {
+			return s, fmt.Errorf("state: invalid type %v", t)
+		}
+		s.Type = int(f)
+	}
+	if ts, ok := d["timestamp"]; ok && ts != nil {
+		f, ok := toFloat(ts)
+		if !ok {
+			return s, fmt.Errorf("state: invalid timestamp %v", ts)
+		}
+		s.Timestamp = f
+	}
+	p, ok := d["payload"]
+	if !ok || p == nil {
+		return s, nil
+	}
+	payload, ok := asDict(p)
+	if !ok {
+		return s, fmt.Errorf("state: expected payload dict, got %T", p)
+	}
+	var err error
+	if s.Payload.Cars, err = decodeRows(payload["cars"]); err != nil {
+		return s, fmt.Errorf("state: cars: %w", err)
+	}
+	if s.Payload.Messages, err = decodeRows(payload["messages"]); err != nil {
+		return s, fmt.Errorf("state: messages: %w", err)
+	}
+	if session, ok := payload["session"]; ok && session != nil {
+		list, ok := wamp.AsList(session)
+		if !ok {
+			return s, fmt.Errorf("state: session: expected list, got %T", session)
+		}
+		s.Payload.Session = normalizeList(list)
+	}
+	return s, nil
+}
+
+// convert decodes a value received by the WAMP client into target using the json struct tags.
+// The value is normalized first, so the type differences of the serializers (e.g. uint64
+// instead of float64 with msgpack) do not matter.
+func convert(v interface{}, target interface{}) error {
+	jsonData, err := json.Marshal(normalize(v))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, target)
+}
+
+func decodeRows(v interface{}) ([][]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	list, ok := wamp.AsList(v)
+	if !ok {
+		return nil, fmt.Errorf("expected list, got %T", v)
+	}
+	ret := make([][]interface{}, len(list))
+	for i, item := range list {
+		if item == nil {
+			continue
+		}
+		row, ok := wamp.AsList(item)
+		if !ok {
+			return nil, fmt.Errorf("row %d: expected list, got %T", i, item)
+		}
+		ret[i] = normalizeList(row)
+	}
+	return ret, nil
+}
+
+func asDict(v interface{}) (map[string]interface{}, bool) {
+	switch d := v.(type) {
+	case wamp.Dict:
+		return d, true
+	case map[string]interface{}:
+		return d, true
+	}
+	return wamp.AsDict(v)
+}
+
+func normalizeList(list []interface{}) []interface{} {
+	ret := make([]interface{}, len(list))
+	for i, item := range list {
+		ret[i] = normalize(item)
+	}
+	return ret
+}
+
+// normalize converts a decoded value into the representation of encoding/json
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, string, bool, float64:
+		return x
+	case []interface{}:
+		return normalizeList(x)
+	case wamp.List:
+		return normalizeList(x)
+	case map[string]interface{}:
+		return normalizeDict(x)
+	case wamp.Dict:
+		return normalizeDict(x)
+	case []byte:
+		return string(x)
+	}
+	if f, ok := toFloat(v); ok {
+		return f
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		ret := make([]interface{}, rv.Len())
+		for i := range ret {
+			ret[i] = normalize(rv.Index(i).Interface())
+		}
+		return ret
+	case reflect.Map:
+		if d, ok := wamp.AsDict(v); ok {
+			return normalizeDict(d)
+		}
+	case reflect.String:
+		return rv.String()
+	}
+	return v
+}
+
+func normalizeDict(d map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(d))
+	for k, item := range d {
+		ret[k] = normalize(item)
+	}
+	return ret
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,116 @@
+package wamp
+
+import (
+	"encoding/json"
+	"fmt"
+	"racelogctl/internal"
+	"reflect"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+// decodeStateJSON is the former decoding via a JSON round trip
+func decodeStateJSON(v interface{}) internal.State {
+	s := internal.State{}
+	jsonData, _ := json.Marshal(v)
+	json.Unmarshal(jsonData, &s)
+	return s
+}
+
+// wampState creates a state as decoded by the msgpack serializer
+func wampState(numCars, numCols int) wamp.Dict {
+	cars := make(wamp.List, numCars)
+	for i := range cars {
+		row := make(wamp.List, numCols)
+		for j := range row {
+			switch j % 4 {
+			case 0:
+				row[j] = int64(i*numCols + j)
+			case 1:
+				row[j] = float64(j) + 0.5
+			case 2:
+				row[j] = "RUN"
+			case 3:
+				row[j] = wamp.List{float32(j), "ob"}
+			}
+		}
+		cars[i] = row
+	}
+	return wamp.Dict{
+		"type":      uint8(1),
+		"timestamp": 1650000000.25,
+		"payload": wamp.Dict{
+			"cars":     cars,
+			"session":  wamp.List{int64(120), 3600.5, "GREEN", nil},
+			"messages": wamp.List{wamp.List{"Timing", "RaceControl", uint16(3), "msg"}},
+		},
+	}
+}
+
+func TestDecodeState(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+	}{
+		{name: "msgpack types", input: wampState(3, 8)},
+		{name: "plain maps", input: map[string]interface{}{
+			"type":    2,
+			"payload": map[string]interface{}{"cars": []interface{}{[]interface{}{0, 1, int32(5)}}, "session": []interface{}{}},
+		}},
+		{name: "nested dict", input: wamp.Dict{
+			"type":    1,
+			"payload": wamp.Dict{"cars": wamp.List{wamp.List{wamp.Dict{"a": int64(1)}, []string{"x", "y"}}, nil}},
+		}},
+		{name: "no payload", input: wamp.Dict{"type": 1, "timestamp": int64(10)}},
+		{name: "empty", input: wamp.Dict{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeState(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decodeStateJSON(tt.input); !reflect.DeepEqual(got, want) {
+				t.Errorf("decodeState() = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeStateErrors(t *testing.T) {
+	inputs := []interface{}{
+		"state",
+		wamp.Dict{"type": "1"},
+		wamp.Dict{"timestamp": "now"},
+		wamp.Dict{"payload": wamp.List{}},
+		wamp.Dict{"payload": wamp.Dict{"cars": "x"}},
+		wamp.Dict{"payload": wamp.Dict{"cars": wamp.List{1}}},
+		wamp.Dict{"payload": wamp.Dict{"session": 1}},
+	}
+	for i, input := range inputs {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			if _, err := decodeState(input); err == nil {
+				t.Errorf("decodeState(%v) expected error", input)
+			}
+		})
+	}
+}
+
+func BenchmarkDecodeState(b *testing.B) {
+	state := wampState(64, 40)
+	b.Run("direct", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := decodeState(state); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decodeStateJSON(state)
+		}
+	})
+}
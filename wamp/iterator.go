@@ -0,0 +1,221 @@
+package wamp
+
+import (
+	"log"
+	"racelogctl/cache"
+	"racelogctl/internal"
+	"time"
+)
+
+// Iterator iterates over the archived states or speedmaps of an event beginning at a timestamp.
+// The data is fetched in pages. The next page is requested in the background while the
+// current page is consumed. The iteration ends with the first empty page or on error.
+//
+//	it := pc.StateIterator(eventId, from, 100)
+//	defer it.Close()
+//	for it.Next() {
+//		process(it.Value())
+//	}
+//	if it.Err() != nil { ... }
+type Iterator[T any] struct {
+	fetch     func(start float64, num int) ([]T, error)
+	timestamp func(T) float64
+	pageSize  int
+	from      float64
+	prefetch  bool
+	observer  func(d time.Duration, n int, err error)
+	pending   chan pageResult[T]
+	page      []T
+	pos       int
+	value     T
+	pages     int
+	err       error
+	finished  bool
+	// if set, called after the first page was fetched to start storing the items in the cache
+	startCache func() *cache.ItemWriter[T]
+	writer     *cache.ItemWriter[T]
+}
+
+type pageResult[T any] struct {
+	items []T
+	err   error
+}
+
+func newIterator[T any](fetch func(start float64, num int) ([]T, error), timestamp func(T) float64, start float64, pageSize int) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch, timestamp: timestamp, from: start, pageSize: pageSize, prefetch: true}
+}
+
+// StateIterator returns an iterator over the states of the event beginning at start.
+// A complete iteration of a finished event is stored in the cache.
+func (pc *PublicClient) StateIterator(eventId int, start float64, pageSize int) *Iterator[internal.State] {
+	it := pc.stateIterator(eventId, start, pageSize)
+	it.startCache = func() *cache.ItemWriter[internal.State] {
+		if pc.cachedStates(eventId) != nil {
+			return nil // served from the cache
+		}
+		return cacheWriter[internal.State](pc, eventId, start, cache.ItemStates)
+	}
+	return it
+}
+
+// stateIterator returns an iterator over the states of the event without storing them in the cache
+func (pc *PublicClient) stateIterator(eventId int, start float64, pageSize int) *Iterator[internal.State] {
+	return newIterator(func(start float64, num int) ([]internal.State, error) {
+		return pc.getStates(eventId, start, num)
+	}, func(s internal.State) float64 { return s.Timestamp }, start, pageSize)
+}
+
+// RawStateIterator returns an iterator over the states of the event as sent by the server.
+// The first state of each page is complete, the following states are deltas.
+// Raw states are not cached, so this requires a connection to the server.
+func (pc *PublicClient) RawStateIterator(eventId int, start float64, pageSize int) *Iterator[internal.State] {
+	return newIterator(func(start float64, num int) ([]internal.State, error) {
+		if pc.client == nil {
+			return nil, offlineError("raw states of event", eventId)
+		}
+		return pc.fetchRawStates(eventId, start, num)
+	}, func(s internal.State) float64 { return s.Timestamp }, start, pageSize)
+}
+
+// SpeedmapIterator returns an iterator over the speedmaps of the event beginning at start
+func (pc *PublicClient) SpeedmapIterator(eventId int, start float64, pageSize int) *Iterator[*internal.SpeedmapMessage] {
+	it := newIterator(func(start float64, num int) ([]*internal.SpeedmapMessage, error) {
+		return pc.GetSpeedmaps(eventId, start, num)
+	}, func(s *internal.SpeedmapMessage) float64 { return s.Timestamp }, start, pageSize)
+	it.startCache = func() *cache.ItemWriter[*internal.SpeedmapMessage] {
+		if pc.cachedSpeedmaps(eventId) != nil {
+			return nil // served from the cache
+		}
+		return cacheWriter[*internal.SpeedmapMessage](pc, eventId, start, cache.ItemSpeedmaps)
+	}
+	return it
+}
+
+// SetObserver sets a function which is called after each page request with its duration,
+// the number of items and the error. Must be called before the iteration starts.
+// Note: the observer is called from a different goroutine.
+func (it *Iterator[T]) SetObserver(observer func(d time.Duration, n int, err error)) *Iterator[T] {
+	it.observer = observer
+	return it
+}
+
+// SetPrefetch enables or disables the background request of the next page (default: enabled).
+// Must be called before the iteration starts.
+func (it *Iterator[T]) SetPrefetch(prefetch bool) *Iterator[T] {
+	it.prefetch = prefetch
+	return it
+}
+
+func (it *Iterator[T]) request(from float64) {
+	ch := make(chan pageResult[T], 1) // buffered, so an abandoned request does not block
+	it.pending = ch
+	go func() {
+		start := time.Now()
+		items, err := it.fetch(from, it.pageSize)
+		if it.observer != nil {
+			it.observer(time.Since(start), len(items), err)
+		}
+		ch <- pageResult[T]{items: items, err: err}
+	}()
+}
+
+// NextPage returns the unconsumed items of the current page or the next page.
+// Returns false if there is no more data or an error occurred.
+func (it *Iterator[T]) NextPage() ([]T, bool) {
+	if it.pos < len(it.page) {
+		ret := it.page[it.pos:]
+		it.pos = len(it.page)
+		return ret, true
+	}
+	if it.finished {
+		return nil, false
+	}
+	if it.pending == nil {
+		it.request(it.from)
+	}
+	res := <-it.pending
+	it.pending = nil
+	it.pages++
+	if it.startCache != nil && res.err == nil {
+		it.writer = it.startCache()
+	}
+	it.startCache = nil
+	if res.err != nil || len(res.items) == 0 {
+		it.err = res.err
+		it.finished = true
+		it.page = nil
+		it.pos = 0
+		it.finishCache()
+		return nil, false
+	}
+	if it.writer != nil {
+		if err := it.writer.Write(res.items...); err != nil {
+			log.Printf("Error writing cache: %v\n", err)
+			it.writer.Abort()
+			it.writer = nil
+		}
+	}
+	it.page = res.items
+	it.pos = len(res.items)
+	it.from = it.timestamp(res.items[len(res.items)-1]) + 0.0001
+	if it.prefetch {
+		it.request(it.from)
+	}
+	return res.items, true
+}
+
+// Next advances to the next item. Returns false if there is no more data or an error occurred.
+func (it *Iterator[T]) Next() bool {
+	if it.pos < len(it.page) {
+		it.value = it.page[it.pos]
+		it.pos++
+		return true
+	}
+	page, ok := it.NextPage()
+	if !ok {
+		return false
+	}
+	it.value = page[0]
+	it.pos = 1
+	return true
+}
+
+// Value returns the current item
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Err returns the error which terminated the iteration
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Pages returns the number of page requests made so far (including the final empty page)
+func (it *Iterator[T]) Pages() int {
+	return it.pages
+}
+
+// finishCache stores the written items in the cache if the iteration completed without error
+func (it *Iterator[T]) finishCache() {
+	if it.writer == nil {
+		return
+	}
+	if it.err != nil || !it.finished {
+		it.writer.Abort()
+	} else if err := it.writer.Commit(); err != nil {
+		log.Printf("Error writing cache: %v\n", err)
+	}
+	it.writer = nil
+}
+
+// Close stops the iteration. A pending page request is abandoned.
+// Items of an incomplete iteration are not cached.
+func (it *Iterator[T]) Close() {
+	if !it.finished {
+		it.finishCache() // aborts, the iteration is incomplete
+	}
+	it.finished = true
+	it.page = nil
+	it.pos = 0
+	it.pending = nil
+}
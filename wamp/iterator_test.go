@@ -0,0 +1,160 @@
+package wamp
+
+import (
+	"errors"
+	"racelogctl/cache"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pagedData simulates the archive: returns up to num items with a value >= start
+func pagedData(data []float64, calls *[]float64) func(start float64, num int) ([]float64, error) {
+	return func(start float64, num int) ([]float64, error) {
+		*calls = append(*calls, start)
+		ret := []float64{}
+		for _, v := range data {
+			if v >= start && len(ret) < num {
+				ret = append(ret, v)
+			}
+		}
+		return ret, nil
+	}
+}
+
+func identity(v float64) float64 { return v }
+
+func TestIteratorNext(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []float64
+		pageSize  int
+		wantPages int
+	}{
+		{name: "empty", data: []float64{}, pageSize: 2, wantPages: 1},
+		{name: "partial last page", data: []float64{1, 2, 3}, pageSize: 2, wantPages: 3},
+		{name: "exact multiple", data: []float64{1, 2, 3, 4}, pageSize: 2, wantPages: 3},
+		{name: "single page", data: []float64{1, 2, 3}, pageSize: 10, wantPages: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, prefetch := range []bool{true, false} {
+				calls := []float64{}
+				it := newIterator(pagedData(tt.data, &calls), identity, 0, tt.pageSize).SetPrefetch(prefetch)
+				got := []float64{}
+				for it.Next() {
+					got = append(got, it.Value())
+				}
+				if it.Err() != nil {
+					t.Errorf("Err() = %v", it.Err())
+				}
+				if !reflect.DeepEqual(got, tt.data) {
+					t.Errorf("prefetch %v: got %v, want %v", prefetch, got, tt.data)
+				}
+				if it.Pages() != tt.wantPages {
+					t.Errorf("prefetch %v: Pages() = %v, want %v", prefetch, it.Pages(), tt.wantPages)
+				}
+				if it.Next() {
+					t.Errorf("Next() after end should return false")
+				}
+			}
+		})
+	}
+}
+
+func TestIteratorNextPage(t *testing.T) {
+	calls := []float64{}
+	it := newIterator(pagedData([]float64{1, 2, 3, 4, 5}, &calls), identity, 0, 2).SetPrefetch(false)
+	if !it.Next() || it.Value() != 1 {
+		t.Fatalf("Next() = %v", it.Value())
+	}
+	// the rest of the current page
+	if page, ok := it.NextPage(); !ok || !reflect.DeepEqual(page, []float64{2}) {
+		t.Errorf("NextPage() = %v, %v", page, ok)
+	}
+	if page, ok := it.NextPage(); !ok || !reflect.DeepEqual(page, []float64{3, 4}) {
+		t.Errorf("NextPage() = %v, %v", page, ok)
+	}
+	if !reflect.DeepEqual(calls, []float64{0, 2.0001}) {
+		t.Errorf("requested pages at %v", calls)
+	}
+}
+
+func TestIteratorError(t *testing.T) {
+	fail := errors.New("fail")
+	n := 0
+	it := newIterator(func(start float64, num int) ([]float64, error) {
+		n++
+		if n > 1 {
+			return nil, fail
+		}
+		return []float64{1, 2}, nil
+	}, identity, 0, 2)
+	observed := 0
+	it.SetObserver(func(d time.Duration, n int, err error) { observed++ })
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if count != 2 || !errors.Is(it.Err(), fail) {
+		t.Errorf("count = %d, Err() = %v", count, it.Err())
+	}
+	if observed != 2 {
+		t.Errorf("observed %d requests, want 2", observed)
+	}
+}
+
+func TestIteratorStreamsToCache(t *testing.T) {
+	c := cache.New(t.TempDir())
+	url := "wss://example.com/ws"
+	data := []float64{1, 2, 3, 4, 5}
+	for _, complete := range []bool{true, false} {
+		calls := []float64{}
+		it := newIterator(pagedData(data, &calls), identity, 0, 2)
+		started := 0
+		it.startCache = func() *cache.ItemWriter[float64] {
+			started++
+			w, err := cache.NewItemWriter[float64](c, url, 1, cache.ItemStates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return w
+		}
+		if started != 0 {
+			t.Fatalf("cache started before the first page")
+		}
+		it.Next()
+		if complete {
+			for it.Next() {
+			}
+		}
+		it.Close()
+		got, ok, _ := cache.GetItems[float64](c, url, 1, cache.ItemStates)
+		if started != 1 || ok != complete {
+			t.Errorf("complete %v: started %d, cached %v", complete, started, ok)
+		}
+		if complete && !reflect.DeepEqual(got, data) {
+			t.Errorf("cached %v, want %v", got, data)
+		}
+		c.Remove(cache.Entry{Meta: cache.Meta{Url: url, EventId: 1}})
+	}
+}
+
+func TestCachedPage(t *testing.T) {
+	data := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		start float64
+		num   int
+		want  []float64
+	}{
+		{start: 0, num: 2, want: []float64{1, 2}},
+		{start: 2.0001, num: 2, want: []float64{3, 4}},
+		{start: 4, num: 10, want: []float64{4, 5}},
+		{start: 6, num: 2, want: []float64{}},
+	}
+	for _, tt := range tests {
+		if got := cachedPage(data, identity, tt.start, tt.num); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cachedPage(%v, %v) = %v, want %v", tt.start, tt.num, got, tt.want)
+		}
+	}
+}
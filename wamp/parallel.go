@@ -0,0 +1,186 @@
+package wamp
+
+import (
+	"log"
+	"racelogctl/cache"
+	"racelogctl/internal"
+	"sort"
+	"sync"
+)
+
+// segment is a part of the timestamp range of an event. End is ignored for the last segment.
+type segment struct {
+	start float64
+	end   float64
+	last  bool
+}
+
+// minSegmentLength is the minimum duration (in seconds) of a segment
+const minSegmentLength = 300.0
+
+// segmentsPerSession is the number of segments per parallel session. Smaller segments
+// balance the work between the sessions if some parts of a race contain more data.
+const segmentsPerSession = 4
+
+// splitRange splits the range start..end into at most n segments of at least minSegmentLength seconds.
+func splitRange(start, end float64, n int) []segment {
+	if n < 1 {
+		n = 1
+	}
+	if max := int((end - start) / minSegmentLength); max < n {
+		n = max
+	}
+	if n < 1 {
+		return []segment{{start: start, last: true}}
+	}
+	size := (end - start) / float64(n)
+	ret := make([]segment, n)
+	for i := range ret {
+		ret[i] = segment{start: start + float64(i)*size, end: start + float64(i+1)*size}
+	}
+	ret[n-1].last = true
+	return ret
+}
+
+// fetchSegments fetches the segments with parallel workers and emits the states in order.
+// Segments are emitted as soon as they and all previous segments are completed.
+func fetchSegments(segments []segment, parallel int, fetch func(seg segment) ([]internal.State, error), emit func(internal.State) error) error {
+	type result struct {
+		states []internal.State
+		err    error
+	}
+	results := make([]chan result, len(segments))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	queue := make(chan int)
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				states, err := fetch(segments[i])
+				results[i] <- result{states: states, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(queue)
+		for i := range segments {
+			select {
+			case queue <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var err error
+	for i := range segments {
+		res := <-results[i]
+		if res.err != nil {
+			err = res.err
+			break
+		}
+		sort.SliceStable(res.states, func(a, b int) bool { return res.states[a].Timestamp < res.states[b].Timestamp })
+		for _, s := range res.states {
+			if err = emit(s); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	// let running requests finish before returning (the sessions are closed by the caller)
+	close(stop)
+	wg.Wait()
+	return err
+}
+
+// fetchSegment fetches the states of the segment. Each segment starts with a full state
+// (the archive returns a keyframe for the first state of a request).
+// The segment is not cached on its own, FetchStatesParallel stores the complete result.
+// Pages are not prefetched: the sessions already work in parallel and a page requested
+// beyond the end of the segment would be wasted.
+func fetchSegment(pc *PublicClient, eventId int, seg segment, pageSize int) ([]internal.State, error) {
+	ret := []internal.State{}
+	it := pc.stateIterator(eventId, seg.start, pageSize).SetPrefetch(false)
+	defer it.Close()
+	for it.Next() {
+		s := it.Value()
+		if !seg.last && s.Timestamp >= seg.end {
+			break
+		}
+		ret = append(ret, s)
+	}
+	return ret, it.Err()
+}
+
+// FetchStatesParallel fetches all states of the event beginning at start using parallel sessions.
+// The range from start to the estimated end of the event is split into segments which are
+// fetched concurrently. The states are passed to emit in the correct order.
+func FetchStatesParallel(url, realm string, event *internal.Event, start float64, pageSize, parallel int, emit func(internal.State) error) (err error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+	info := event.Data.ReplayInfo
+	end := info.MinTimestamp + (info.MaxSessionTime - info.MinSessionTime)
+	segments := splitRange(start, end, parallel*segmentsPerSession)
+
+	first := NewPublicClient(url, realm)
+	if first.cachedStates(int(event.Id)) != nil || first.client == nil {
+		// served from the cache (or not available in offline mode)
+		defer first.Close()
+		it := first.StateIterator(int(event.Id), start, pageSize)
+		for it.Next() {
+			if err := emit(it.Value()); err != nil {
+				return err
+			}
+		}
+		return it.Err()
+	}
+	if w := cacheWriter[internal.State](first, int(event.Id), start, cache.ItemStates); w != nil {
+		next := emit
+		emit = func(s internal.State) error {
+			if w != nil {
+				if err := w.Write(s); err != nil {
+					log.Printf("Error writing cache: %v\n", err)
+					w.Abort()
+					w = nil
+				}
+			}
+			return next(s)
+		}
+		defer func() {
+			if w == nil {
+				return
+			}
+			if err != nil {
+				w.Abort()
+			} else if err := w.Commit(); err != nil {
+				log.Printf("Error writing cache: %v\n", err)
+			}
+		}()
+	}
+
+	clients := make(chan *PublicClient, parallel)
+	clients <- first
+	for i := 1; i < parallel; i++ {
+		clients <- NewPublicClient(url, realm)
+	}
+	defer func() {
+		close(clients)
+		for pc := range clients {
+			pc.Close()
+		}
+	}()
+	err = fetchSegments(segments, parallel, func(seg segment) ([]internal.State, error) {
+		pc := <-clients
+		defer func() { clients <- pc }()
+		return fetchSegment(pc, int(event.Id), seg, pageSize)
+	}, emit)
+	return err
+}
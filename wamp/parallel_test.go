@@ -0,0 +1,131 @@
+package wamp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"racelogctl/cache"
+	"racelogctl/internal"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end float64
+		n          int
+		want       int
+	}{
+		{name: "short event", start: 0, end: 100, n: 8, want: 1},
+		{name: "limited by min length", start: 0, end: 1000, n: 8, want: 3},
+		{name: "full split", start: 0, end: 86400, n: 8, want: 8},
+		{name: "no parallelism", start: 0, end: 86400, n: 0, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitRange(tt.start, tt.end, tt.n)
+			if len(got) != tt.want {
+				t.Fatalf("splitRange() = %v segments, want %v", len(got), tt.want)
+			}
+			if got[0].start != tt.start || !got[len(got)-1].last {
+				t.Errorf("splitRange() = %+v", got)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].start != got[i-1].end {
+					t.Errorf("gap between segment %d and %d: %+v", i-1, i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchSegmentsOrder(t *testing.T) {
+	segments := splitRange(0, 3000, 10)
+	fetch := func(seg segment) ([]internal.State, error) {
+		// later segments complete first
+		time.Sleep(time.Duration(3000-seg.start) * time.Microsecond)
+		return []internal.State{{Timestamp: seg.start + 1}, {Timestamp: seg.start}}, nil
+	}
+	got := []float64{}
+	err := fetchSegments(segments, 4, fetch, func(s internal.State) error {
+		got = append(got, s.Timestamp)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2*len(segments) {
+		t.Fatalf("got %d states, want %d", len(got), 2*len(segments))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Errorf("states not ordered: %v", got)
+			break
+		}
+	}
+}
+
+func TestFetchSegmentsError(t *testing.T) {
+	fail := errors.New("fail")
+	segments := splitRange(0, 3000, 10)
+	err := fetchSegments(segments, 3, func(seg segment) ([]internal.State, error) {
+		if seg.start > 0 {
+			return nil, fail
+		}
+		return []internal.State{{Timestamp: 1}}, nil
+	}, func(s internal.State) error { return nil })
+	if !errors.Is(err, fail) {
+		t.Errorf("fetchSegments() = %v, want %v", err, fail)
+	}
+}
+
+func TestFetchSegmentsWithoutCacheSideEffects(t *testing.T) {
+	c := cache.New(t.TempDir())
+	UseCache(c, true)
+	defer UseCache(nil, false)
+	url := "wss://example.com/ws"
+	states := []internal.State{}
+	for ts := 0.0; ts < 3000; ts += 10 {
+		states = append(states, internal.State{Timestamp: ts})
+	}
+	if err := cache.PutItems(c, url, 1, cache.ItemStates, states); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := c.List(url)
+	statesFile := filepath.Join(c.Dir(), "*", "events", "1", cache.ItemStates)
+	modTime := func() time.Time {
+		files, _ := filepath.Glob(statesFile)
+		if len(files) != 1 {
+			t.Fatalf("cached states not found")
+		}
+		info, _ := os.Stat(files[0])
+		return info.ModTime()
+	}
+	written := modTime()
+
+	pc := &PublicClient{url: url, cached: map[int]*cachedData{}}
+	if pc.stateIterator(1, 0, 10).startCache != nil {
+		t.Fatalf("segment iterator uses the cache hook")
+	}
+	segments := splitRange(0, 3000, 8)
+	got := 0
+	err := fetchSegments(segments, 4, func(seg segment) ([]internal.State, error) {
+		return fetchSegment(pc, 1, seg, 7)
+	}, func(s internal.State) error {
+		got++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != len(states) {
+		t.Errorf("got %d states from %d segments, want %d", got, len(segments), len(states))
+	}
+	// only the access time of the event is updated
+	after, _ := c.List(url)
+	if len(after) != 1 || !reflect.DeepEqual(after[0].Items, before[0].Items) || !modTime().Equal(written) {
+		t.Errorf("cache changed by segments: %+v, before %+v", after, before)
+	}
+}
@@ -2,12 +2,12 @@ package wamp
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"racelogctl/internal"
 	"racelogctl/util"
+	"sync"
 
 	"github.com/gammazero/nexus/v3/client"
 	"github.com/gammazero/nexus/v3/wamp"
@@ -19,23 +19,31 @@ type PublicAccess interface {
 
 type PublicClient struct {
 	client *client.Client
+	url    string
+	cached map[int]*cachedData // loaded cache data by event id
+	mu     sync.Mutex          // protects cached, pages may be requested in the background
 }
 
 func NewPublicClient(url string, realm string) *PublicClient {
+	if offline {
+		return &PublicClient{url: url, cached: map[int]*cachedData{}}
+	}
 	logger := log.New(os.Stdout, "", 0)
-	cfg := client.Config{Realm: realm, Logger: logger}
+	cfg := client.Config{Realm: realm, Logger: logger, Serialization: serialization}
 	// Connect wampClient session.
-	wampClient, err := client.ConnectNet(context.Background(), url, cfg)
+	wampClient, err := connect(url, cfg)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	ret := &PublicClient{client: wampClient}
+	ret := &PublicClient{client: wampClient, url: url, cached: map[int]*cachedData{}}
 	return ret
 }
 
 func (pc *PublicClient) Close() {
-	pc.client.Close()
+	if pc.client != nil {
+		pc.client.Close()
+	}
 }
 
 func (pc *PublicClient) Client() *client.Client {
@@ -43,6 +51,9 @@ func (pc *PublicClient) Client() *client.Client {
 }
 
 func (pc *PublicClient) ProviderList() ([]*internal.ProviderData, error) {
+	if pc.client == nil {
+		return []*internal.ProviderData{}, nil // no live events in offline mode
+	}
 	ctx := context.Background()
 
 	result, err := pc.client.Call(ctx, "racelog.public.list_providers", nil, wamp.List{}, nil, nil)
@@ -60,10 +71,7 @@ func (pc *PublicClient) ProviderList() ([]*internal.ProviderData, error) {
 		for j := range ret {
 			var e internal.ProviderData
 
-			jsonData, _ := json.Marshal(ret[j])
-			// logger.Printf("jsonData: %v", string(jsonData))
-
-			json.Unmarshal(jsonData, &e)
+			convert(ret[j], &e)
 			retEvents = append(retEvents, &e)
 		}
 	}
@@ -72,7 +80,7 @@ func (pc *PublicClient) ProviderList() ([]*internal.ProviderData, error) {
 
 }
 
-func (pc *PublicClient) GetEvent(eventId int) (*internal.Event, error) {
+func (pc *PublicClient) fetchEvent(eventId int) (*internal.Event, error) {
 
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.get_event_info", nil, wamp.List{eventId}, nil, nil)
@@ -89,14 +97,14 @@ func (pc *PublicClient) GetEvent(eventId int) (*internal.Event, error) {
 		return nil, fmt.Errorf("no data for eventId %d", eventId)
 	}
 	var e internal.Event
-	jsonData, _ := json.Marshal(ret)
-	// logger.Printf("jsonData: %v", string(jsonData))
-	json.Unmarshal(jsonData, &e)
+	convert(ret, &e)
 	return &e, nil
 }
 
 func (pc *PublicClient) GetEventByKey(eventKey string) (*internal.Event, error) {
-
+	if pc.client == nil {
+		return nil, ErrOffline
+	}
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.get_event_info_by_key", nil, wamp.List{eventKey}, nil, nil)
 	if err != nil {
@@ -112,13 +120,11 @@ func (pc *PublicClient) GetEventByKey(eventKey string) (*internal.Event, error)
 		return nil, fmt.Errorf("no data for eventKey %s", eventKey)
 	}
 	var e internal.Event
-	jsonData, _ := json.Marshal(ret)
-	// logger.Printf("jsonData: %v", string(jsonData))
-	json.Unmarshal(jsonData, &e)
+	convert(ret, &e)
 	return &e, nil
 }
 
-func (pc *PublicClient) GetTrack(id int) (*internal.TrackInfo, error) {
+func (pc *PublicClient) fetchTrack(id int) (*internal.TrackInfo, error) {
 
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.get_track_info", nil, wamp.List{id}, nil, nil)
@@ -131,13 +137,11 @@ func (pc *PublicClient) GetTrack(id int) (*internal.TrackInfo, error) {
 		return nil, fmt.Errorf("no data for trackId %d", id)
 	}
 	var t internal.TrackInfo
-	jsonData, _ := json.Marshal(ret)
-	// logger.Printf("jsonData: %v", string(jsonData))
-	json.Unmarshal(jsonData, &t)
+	convert(ret, &t)
 	return &t, nil
 }
 
-func (pc *PublicClient) GetEventList() ([]*internal.Event, error) {
+func (pc *PublicClient) fetchEventList() ([]*internal.Event, error) {
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.get_events", nil, nil, nil, nil)
 	if err != nil {
@@ -149,9 +153,7 @@ func (pc *PublicClient) GetEventList() ([]*internal.Event, error) {
 		for j := range ret {
 
 			var e internal.Event
-			jsonData, _ := json.Marshal(ret[j])
-			// logger.Printf("jsonData: %v", string(jsonData))
-			json.Unmarshal(jsonData, &e)
+			convert(ret[j], &e)
 			retEvents = append(retEvents, &e)
 
 		}
@@ -160,34 +162,57 @@ func (pc *PublicClient) GetEventList() ([]*internal.Event, error) {
 }
 
 func (pc *PublicClient) GetStates(id int, start float64, num int) []internal.State {
+	ret, err := pc.getStates(id, start, num)
+	if err != nil {
+		logger.Fatal(err)
+		return nil
+	}
+	return ret
+}
+
+func (pc *PublicClient) fetchStates(id int, start float64, num int) ([]internal.State, error) {
+	raw, err := pc.fetchRawStates(id, start, num)
+	if err != nil {
+		return nil, err
+	}
+	resultStates := make([]internal.State, 0, len(raw))
+	lastState := internal.State{}
+	for _, s := range raw {
+		lastState = util.ProcessDeltaStates(lastState, s)
+		resultStates = append(resultStates, lastState)
+	}
+	return resultStates, nil
+}
+
+// fetchRawStates returns the states as sent by the server (first state of the page is complete, the others are deltas)
+func (pc *PublicClient) fetchRawStates(id int, start float64, num int) ([]internal.State, error) {
 
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.archive.state.delta", nil, wamp.List{id, start, num}, nil, nil)
 	if err != nil {
-		logger.Fatal(err)
-		return nil
+		return nil, err
 	}
 
-	resultStates := make([]internal.State, 0)
 	if len(result.Arguments) == 0 {
-		return resultStates
+		return []internal.State{}, nil
 	}
 	ret, _ := wamp.AsList(result.Arguments[0])
-	lastState := internal.State{}
+	resultStates := make([]internal.State, 0, len(ret))
 	for j := range ret {
-		s := internal.State{Payload: internal.Payload{}}
-		jsonData, _ := json.Marshal(ret[j])
-		// logger.Printf("jsonData: %v", string(jsonData))
-		json.Unmarshal(jsonData, &s)
-		lastState = util.ProcessDeltaStates(lastState, s)
-		resultStates = append(resultStates, lastState)
+		s, err := decodeState(ret[j])
+		if err != nil {
+			return nil, fmt.Errorf("event %d, state %d: %w", id, j, err)
+		}
+		resultStates = append(resultStates, s)
 	}
-	return resultStates
+	return resultStates, nil
 
 }
 
 func (pc *PublicClient) GetLiveAnalysisData(eventKey string) (map[string]interface{}, error) {
-
+	if pc.client == nil {
+		return nil, ErrOffline
+	}
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.live.get_event_analysis", nil, wamp.List{eventKey}, nil, nil)
 	if err != nil {
@@ -199,14 +224,11 @@ func (pc *PublicClient) GetLiveAnalysisData(eventKey string) (map[string]interfa
 		return nil, fmt.Errorf("no data for event %s", eventKey)
 	}
 
-	jsonData, _ := json.Marshal(ret)
-	var retStruct map[string]interface{}
-	json.Unmarshal(jsonData, &retStruct)
-	return retStruct, nil
+	return normalizeDict(ret), nil
 
 }
 
-func (pc *PublicClient) GetCarData(eventId int) (*internal.EventCarMessage, error) {
+func (pc *PublicClient) fetchCarData(eventId int) (*internal.EventCarMessage, error) {
 
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.get_event_cars", nil, wamp.List{eventId}, nil, nil)
@@ -219,27 +241,27 @@ func (pc *PublicClient) GetCarData(eventId int) (*internal.EventCarMessage, erro
 		return nil, fmt.Errorf("no data for event %d", eventId)
 	}
 
-	jsonData, _ := json.Marshal(ret)
 	var retStruct internal.EventCarMessage
-	json.Unmarshal(jsonData, &retStruct)
+	convert(ret, &retStruct)
 	return &retStruct, nil
 
 }
 
-func (pc *PublicClient) GetSpeedmaps(id int, start float64, num int) ([]*internal.SpeedmapMessage, error) {
+func (pc *PublicClient) fetchSpeedmaps(id int, start float64, num int) ([]*internal.SpeedmapMessage, error) {
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.archive.speedmap", nil, wamp.List{id, start, num}, nil, nil)
 	if err != nil {
 		return nil, err
 	}
+	if len(result.Arguments) == 0 {
+		return []*internal.SpeedmapMessage{}, nil
+	}
 
 	ret, _ := wamp.AsList(result.Arguments[0])
 	speedmaps := make([]*internal.SpeedmapMessage, 0)
 	for j := range ret {
 		var s internal.SpeedmapMessage
-		jsonData, _ := json.Marshal(ret[j])
-		// logger.Printf("jsonData: %v", string(jsonData))
-		json.Unmarshal(jsonData, &s)
+		convert(ret[j], &s)
 
 		speedmaps = append(speedmaps, &s)
 	}
@@ -248,7 +270,9 @@ func (pc *PublicClient) GetSpeedmaps(id int, start float64, num int) ([]*interna
 }
 
 func (pc *PublicClient) GetEventAvgLaps(id int, interval int) ([]*internal.AverageLapTime, error) {
-
+	if pc.client == nil {
+		return nil, ErrOffline
+	}
 	ctx := context.Background()
 	result, err := pc.client.Call(ctx, "racelog.public.archive.avglap_over_time", nil, wamp.List{id, interval}, nil, nil)
 	if err != nil {
@@ -259,8 +283,7 @@ func (pc *PublicClient) GetEventAvgLaps(id int, interval int) ([]*internal.Avera
 	ret := make([]*internal.AverageLapTime, 0)
 	for _, item := range work {
 		alt := internal.AverageLapTime{}
-		jsonData, _ := json.Marshal(item)
-		json.Unmarshal(jsonData, &alt)
+		convert(item, &alt)
 		ret = append(ret, &alt)
 	}
 	return ret, nil
@@ -0,0 +1,31 @@
+package wamp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/transport/serialize"
+)
+
+// serializers maps the names accepted by UseSerializer to the nexus serializations
+var serializers = map[string]serialize.Serialization{
+	"json":    serialize.JSON,
+	"msgpack": serialize.MSGPACK,
+	"cbor":    serialize.CBOR,
+}
+
+// SerializerNames lists the names accepted by UseSerializer
+var SerializerNames = []string{"json", "msgpack", "cbor"}
+
+var serialization = serialize.JSON // used by all clients created afterwards
+
+// UseSerializer configures the serialization (json, msgpack or cbor) for all clients created afterwards.
+// msgpack and cbor reduce the size of bulk transfers like states and speedmaps.
+func UseSerializer(name string) error {
+	s, ok := serializers[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown serializer %q (use one of %s)", name, strings.Join(SerializerNames, ", "))
+	}
+	serialization = s
+	return nil
+}
@@ -0,0 +1,92 @@
+package wamp
+
+import (
+	"racelogctl/internal"
+	"reflect"
+	"testing"
+
+	"github.com/gammazero/nexus/v3/transport/serialize"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+type dataSerializer interface {
+	SerializeDataItem(item interface{}) ([]byte, error)
+	DeserializeDataItem(data []byte, v interface{}) error
+}
+
+var testSerializers = map[string]dataSerializer{
+	"json":    &serialize.JSONSerializer{},
+	"msgpack": &serialize.MessagePackSerializer{},
+	"cbor":    &serialize.CBORSerializer{},
+}
+
+// transfer returns the value as received by a client using the serializer
+func transfer(t *testing.T, s dataSerializer, v interface{}) interface{} {
+	data, err := s.SerializeDataItem(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ret interface{}
+	if err := s.DeserializeDataItem(data, &ret); err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+func TestUseSerializer(t *testing.T) {
+	defer UseSerializer("json")
+	for name, want := range map[string]serialize.Serialization{"msgpack": serialize.MSGPACK, "CBOR": serialize.CBOR, "json": serialize.JSON} {
+		if err := UseSerializer(name); err != nil || serialization != want {
+			t.Errorf("UseSerializer(%s) = %v, serialization %v", name, err, serialization)
+		}
+	}
+	if err := UseSerializer("xml"); err == nil {
+		t.Error("UseSerializer(xml) expected error")
+	}
+}
+
+func TestDecodeStateSerializers(t *testing.T) {
+	want := decodeStateJSON(wampState(4, 12))
+	for name, s := range testSerializers {
+		t.Run(name, func(t *testing.T) {
+			got, err := decodeState(transfer(t, s, wampState(4, 12)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decodeState() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestConvertSerializers(t *testing.T) {
+	speedmap := wamp.Dict{
+		"type":      uint8(1),
+		"timestamp": 1650000000.5,
+		"payload": wamp.Dict{
+			"data":        wamp.Dict{"GT3": wamp.Dict{"chunkSpeeds": wamp.List{int64(150), 160.5}, "laptime": int64(98)}},
+			"chunkSize":   uint16(10),
+			"trackLength": 5000.0,
+			"sessionTime": int64(3600),
+		},
+	}
+	var want internal.SpeedmapMessage
+	if err := convert(speedmap, &want); err != nil {
+		t.Fatal(err)
+	}
+	if want.Payload.ChunkSize != 10 || want.Payload.Data["GT3"].Laptime != 98 {
+		t.Fatalf("convert() = %+v", want)
+	}
+	for name, s := range testSerializers {
+		t.Run(name, func(t *testing.T) {
+			var got internal.SpeedmapMessage
+			if err := convert(transfer(t, s, speedmap), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("convert() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package wamp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gammazero/nexus/v3/client"
+)
+
+// TLSOptions configure the TLS connections (wss://, https://, tcps://)
+type TLSOptions struct {
+	CAFile             string // PEM file with the CA certificates used to verify the server
+	CertFile           string // PEM file with the client certificate
+	KeyFile            string // PEM file with the key of the client certificate
+	InsecureSkipVerify bool   // do not verify the server certificate
+}
+
+var tlsOptions TLSOptions // used by all clients created afterwards
+
+// UseTLS configures the TLS options for all clients created afterwards.
+// The files are checked when the connection is made.
+func UseTLS(opts TLSOptions) {
+	tlsOptions = opts
+}
+
+func (o TLSOptions) isSet() bool {
+	return o != TLSOptions{}
+}
+
+// config returns the tls.Config for the options
+func (o TLSOptions) config() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, errors.New("client certificate requires both cert and key file")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// isTLS returns true if the scheme uses TLS
+func isTLS(scheme string) bool {
+	switch scheme {
+	case "wss", "https", "tcps", "tcp4s", "tcp6s":
+		return true
+	}
+	return false
+}
+
+// parseUnixWebsocket splits a ws+unix URL into the socket path and the websocket URL.
+// The form is ws+unix://<socket path>[:<http path>], e.g. ws+unix:///run/crossbar.sock:/ws
+func parseUnixWebsocket(routerURL string) (socket, wsURL string, err error) {
+	rest := strings.TrimPrefix(routerURL, "ws+unix://")
+	socket, path := rest, "/"
+	if idx := strings.LastIndex(rest, ":/"); idx >= 0 {
+		socket, path = rest[:idx], rest[idx+1:]
+	}
+	if socket == "" {
+		return "", "", fmt.Errorf("invalid url %s: missing socket path", routerURL)
+	}
+	return socket, "ws://localhost" + path, nil
+}
+
+// connect connects to the WAMP router. Supported are the URL forms of nexus
+// (ws://, wss://, tcp://, tcps://, unix://) and websockets over unix sockets (ws+unix://).
+// TLS options are applied to wss:// and tcps:// URLs only, so plain connections (e.g. to a
+// source server given by url) still work if TLS options are configured for the main server.
+func connect(routerURL string, cfg client.Config) (*client.Client, error) {
+	u, err := url.Parse(routerURL)
+	if err != nil {
+		return nil, err
+	}
+	if tlsOptions.isSet() && isTLS(u.Scheme) {
+		if cfg.TlsCfg, err = tlsOptions.config(); err != nil {
+			return nil, err
+		}
+	}
+	if u.Scheme == "ws+unix" {
+		socket, wsURL, err := parseUnixWebsocket(routerURL)
+		if err != nil {
+			return nil, err
+		}
+		cfg.WsCfg.Dial = func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		}
+		routerURL = wsURL
+	}
+	return client.ConnectNet(context.Background(), routerURL, cfg)
+}
@@ -0,0 +1,173 @@
+package wamp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gammazero/nexus/v3/client"
+	"github.com/gammazero/nexus/v3/router"
+	"github.com/gammazero/nexus/v3/wamp"
+)
+
+func TestParseUnixWebsocket(t *testing.T) {
+	tests := []struct {
+		url, socket, wsURL string
+		wantErr            bool
+	}{
+		{url: "ws+unix:///run/crossbar.sock", socket: "/run/crossbar.sock", wsURL: "ws://localhost/"},
+		{url: "ws+unix:///run/crossbar.sock:/ws", socket: "/run/crossbar.sock", wsURL: "ws://localhost/ws"},
+		{url: "ws+unix://relative.sock:/a/b", socket: "relative.sock", wsURL: "ws://localhost/a/b"},
+		{url: "ws+unix://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			socket, wsURL, err := parseUnixWebsocket(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUnixWebsocket() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if socket != tt.socket || wsURL != tt.wsURL {
+				t.Errorf("parseUnixWebsocket() = %s, %s, want %s, %s", socket, wsURL, tt.socket, tt.wsURL)
+			}
+		})
+	}
+}
+
+func TestTLSOptionsConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir)
+	if _, err := (TLSOptions{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}).config(); err != nil {
+		t.Errorf("config() error = %v", err)
+	}
+	invalid := []TLSOptions{
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CAFile: keyFile},
+		{CertFile: certFile},
+		{CertFile: keyFile, KeyFile: certFile},
+	}
+	for _, o := range invalid {
+		if _, err := o.config(); err == nil {
+			t.Errorf("config(%+v) expected error", o)
+		}
+	}
+}
+
+// writeCertificate creates a self-signed certificate for localhost
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	return certFile, keyFile
+}
+
+func testRouter(t *testing.T) router.Router {
+	r, err := router.NewRouter(&router.Config{
+		RealmConfigs: []*router.RealmConfig{{URI: wamp.URI("racelog"), AnonymousAuth: true}},
+	}, log.New(os.Stderr, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestConnect(t *testing.T) {
+	r := testRouter(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir)
+
+	rawUnix := filepath.Join(dir, "raw.sock")
+	closer, err := router.NewRawSocketServer(r).ListenAndServe("unix", rawUnix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	wsUnix := filepath.Join(dir, "ws.sock")
+	l, err := net.Listen("unix", wsUnix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/ws", router.NewWebsocketServer(r))
+	go http.Serve(l, mux)
+	defer l.Close()
+
+	closer, err = router.NewRawSocketServer(r).ListenAndServeTLS("tcp", "127.0.0.1:0", nil, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	tcpsAddr := closer.(net.Listener).Addr().String()
+
+	closer, err = router.NewWebsocketServer(r).ListenAndServeTLS("127.0.0.1:0", nil, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	wssAddr := closer.(net.Listener).Addr().String()
+
+	defer UseTLS(TLSOptions{})
+	tests := []struct {
+		name    string
+		url     string
+		tls     TLSOptions
+		wantErr bool
+	}{
+		{name: "unix", url: "unix://" + rawUnix},
+		{name: "ws+unix", url: "ws+unix://" + wsUnix + ":/ws"},
+		{name: "tcps with CA", url: "tcps://" + tcpsAddr, tls: TLSOptions{CAFile: certFile}},
+		{name: "wss with CA", url: "wss://" + wssAddr, tls: TLSOptions{CAFile: certFile}},
+		{name: "wss insecure", url: "wss://" + wssAddr, tls: TLSOptions{InsecureSkipVerify: true}},
+		{name: "wss unknown CA", url: "wss://" + wssAddr, wantErr: true},
+		{name: "TLS options ignored for unix", url: "unix://" + rawUnix, tls: TLSOptions{CAFile: "missing.pem"}},
+		{name: "TLS options ignored for ws+unix", url: "ws+unix://" + wsUnix + ":/ws", tls: TLSOptions{InsecureSkipVerify: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UseTLS(tt.tls)
+			c, err := connect(tt.url, client.Config{Realm: "racelog", Serialization: serialization})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if c != nil {
+				c.Close()
+			}
+		})
+	}
+}